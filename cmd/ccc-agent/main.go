@@ -0,0 +1,86 @@
+// Command ccc-agent probes endpoints from a second vantage point, such as
+// another uplink or a partner building, and reports to a ccc-api server
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jonsson/ccc/internal/agent"
+	"github.com/jonsson/ccc/internal/monitor"
+)
+
+// Config holds agent configuration
+type Config struct {
+	Server       string // Base URL of the ccc-api server
+	Key          string // Agent key issued by POST /api/admin/agents
+	Privileged   bool
+	ProbeWorkers int                      // Endpoints probed at once
+	Thresholds   monitor.StatusThresholds // Consecutive probes required to change status
+}
+
+func main() {
+	cfg := parseConfig()
+	if cfg.Server == "" || cfg.Key == "" {
+		log.Fatal("--server and --key (or CCC_AGENT_SERVER and CCC_AGENT_KEY) are required")
+	}
+
+	pinger := monitor.NewPinger(5*time.Second, cfg.Privileged)
+	a := agent.New(agent.NewClient(cfg.Server, cfg.Key), pinger, cfg.ProbeWorkers, cfg.Thresholds)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	log.Printf("Starting probe agent for %s (workers: %d)", cfg.Server, cfg.ProbeWorkers)
+	a.Run(ctx)
+	log.Println("Probe agent stopped")
+}
+
+func parseConfig() Config {
+	cfg := Config{}
+
+	flag.StringVar(&cfg.Server, "server", getEnv("CCC_AGENT_SERVER", ""), "Base URL of the ccc-api server (e.g. https://ccc.example.org)")
+	flag.StringVar(&cfg.Key, "key", getEnv("CCC_AGENT_KEY", ""), "Agent key issued by the server")
+	flag.BoolVar(&cfg.Privileged, "privileged", getEnvBool("CCC_PRIVILEGED", false), "Use privileged (raw socket) ICMP")
+	flag.IntVar(&cfg.ProbeWorkers, "probe-workers", getEnvInt("CCC_PROBE_WORKERS", monitor.DefaultProbeWorkers), "Endpoints probed at once")
+	defaultThresholds := monitor.DefaultStatusThresholds()
+	flag.IntVar(&cfg.Thresholds.FailThreshold, "fail-threshold", getEnvInt("CCC_FAIL_THRESHOLD", defaultThresholds.FailThreshold), "Consecutive failed probes before an endpoint is reported down")
+	flag.IntVar(&cfg.Thresholds.RecoverThreshold, "recover-threshold", getEnvInt("CCC_RECOVER_THRESHOLD", defaultThresholds.RecoverThreshold), "Consecutive successful probes before a down endpoint is reported up")
+	cfg.Thresholds.DegradedLossPct = defaultThresholds.DegradedLossPct
+
+	flag.Parse()
+	return cfg
+}
+
+func getEnv(key, defaultVal string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return defaultVal
+}
+
+func getEnvInt(key string, defaultVal int) int {
+	if val := os.Getenv(key); val != "" {
+		result := 0
+		for _, c := range val {
+			if c < '0' || c > '9' {
+				return defaultVal
+			}
+			result = result*10 + int(c-'0')
+		}
+		return result
+	}
+	return defaultVal
+}
+
+func getEnvBool(key string, defaultVal bool) bool {
+	if val := os.Getenv(key); val != "" {
+		return val == "true" || val == "1" || val == "yes"
+	}
+	return defaultVal
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jonsson/ccc/internal/storage"
+)
+
+// runBackupCommand handles "backup <file>": a consistent snapshot that is
+// safe to take while the server is running
+func runBackupCommand(dbPath string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ccc-api [flags] backup <file>")
+	}
+
+	db, err := storage.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.Backup(args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Backed up %s to %s.\n", dbPath, args[0])
+	return nil
+}
+
+// runRestoreCommand handles "restore <file>". The backup is validated
+// (integrity and schema version) before it replaces the database.
+func runRestoreCommand(dbPath string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: ccc-api [flags] restore <file>\n\nStop the server before restoring.")
+	}
+
+	version, err := storage.ValidateBackup(args[0])
+	if err != nil {
+		return fmt.Errorf("refusing to restore: %w", err)
+	}
+
+	previous, err := storage.Restore(args[0], dbPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Restored %s from %s (schema version %d).\n", dbPath, args[0], version)
+	if previous != "" {
+		fmt.Printf("The previous database was saved as %s.\n", previous)
+	}
+	return nil
+}
@@ -16,8 +16,11 @@ import (
 
 	"github.com/jonsson/ccc/internal/api"
 	"github.com/jonsson/ccc/internal/isp"
+	"github.com/jonsson/ccc/internal/models"
 	"github.com/jonsson/ccc/internal/monitor"
+	"github.com/jonsson/ccc/internal/notify"
 	"github.com/jonsson/ccc/internal/storage"
+	"github.com/jonsson/ccc/internal/stream"
 )
 
 //go:embed static
@@ -28,54 +31,106 @@ type Config struct {
 	DBPath        string
 	ListenAddr    string
 	PingInterval  time.Duration
+	RecheckInterval time.Duration // First backoff for re-probing a failed endpoint
+	ProbeWorkers  int             // Endpoints probed at once
 	ExpireDays    int
 	Privileged    bool
-	SetPassword   string   // If set, just set the password and exit
+	SetPassword   string   // If set, just set the "admin" account password and exit
 	TrustedProxies []string // IPs/CIDRs trusted to set X-Forwarded-For
 	CORSOrigin    string   // Allowed CORS origin (empty = same-origin only)
 	ISPConfigPath string   // Path to ISP config JSON file
+	ASNDBPaths    []string // Local IP-to-ASN prefix dumps for offline lookups
+	CymruFallback bool     // Fall back to Team Cymru DNS when a prefix DB is configured
+	HopRefresh    time.Duration // How often monitored hops are re-discovered
+	ProbeStrategy string        // Default probe specs, tried in order
+	Retention     storage.HistoryRetention // Uptime history retention per tier
+	Thresholds    monitor.StatusThresholds // Consecutive probes required to change status
+	MetricsListen string   // Separate listen address for /metrics (empty = main listener)
+	MetricsToken  string   // Bearer token required for /metrics (empty = open)
+	SessionTTL    time.Duration // Lifetime of admin login sessions
+	AutoMigrate   bool          // Apply pending schema migrations at startup
+	BackupDir      string        // Directory for rotating backups (empty = disabled)
+	BackupInterval time.Duration // Time between rotating backups
+	BackupKeep     int           // Number of rotating backups kept
 }
 
 func main() {
 	cfg := parseConfig()
 
+	// Commands that work on the database file itself, without migrating it
+	fileCommands := map[string]func(string, []string) error{
+		"migrate": runMigrateCommand,
+		"backup":  runBackupCommand,
+		"restore": runRestoreCommand,
+	}
+	if args := flag.Args(); len(args) > 0 && fileCommands[args[0]] != nil {
+		if err := fileCommands[args[0]](cfg.DBPath, args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Initialize database (needed for both server and password setting)
-	db, err := storage.New(cfg.DBPath)
+	db, err := openDatabase(cfg)
 	if err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 	defer db.Close()
+	db.SetHistoryRetention(cfg.Retention)
 
 	// Handle set-password command
 	if cfg.SetPassword != "" {
-		if err := db.SetAdminPassword(cfg.SetPassword); err != nil {
+		if err := setDefaultAdminPassword(db, cfg.SetPassword); err != nil {
 			log.Fatalf("Failed to set admin password: %v", err)
 		}
-		fmt.Println("Admin password set successfully.")
+		fmt.Printf("Password for owner account %q set successfully.\n", storage.DefaultAdminUsername)
+		return
+	}
+
+	// Handle subcommands
+	if args := flag.Args(); len(args) > 0 {
+		if args[0] != "user" {
+			log.Fatalf("Unknown command %q (available: user, migrate, backup, restore)", args[0])
+		}
+		if err := runUserCommand(db, args[1:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
 		return
 	}
 
-	// Check if admin password is configured
-	hasPassword, err := db.HasAdminPassword()
+	// Check if any admin account is configured
+	hasUsers, err := db.HasAdminUsers()
 	if err != nil {
-		log.Fatalf("Failed to check admin password: %v", err)
+		log.Fatalf("Failed to check admin users: %v", err)
 	}
-	if !hasPassword {
-		log.Println("WARNING: No admin password set. Run with --set-password <password> to set one.")
+	if !hasUsers {
+		log.Println("WARNING: No admin users. Run 'ccc-api user create <username> owner' to add one.")
 	}
 
 	log.Printf("CCC API Server v%s", api.Version)
 	log.Printf("Database: %s", cfg.DBPath)
 	log.Printf("Listen address: %s", cfg.ListenAddr)
 
-	// Initialize ISP classifier
+	// Initialize ISP classifier; its ASN mappings are loaded by the handler below
 	classifier := isp.NewClassifier()
-	if cfg.ISPConfigPath != "" {
-		if err := classifier.LoadConfig(cfg.ISPConfigPath); err != nil {
-			log.Fatalf("Failed to load ISP config: %v", err)
+
+	// Offline ASN resolution from local prefix dumps, optionally backed by Cymru DNS
+	if len(cfg.ASNDBPaths) > 0 {
+		var resolvers []isp.ASNResolver
+		for _, path := range cfg.ASNDBPaths {
+			prefixDB, err := isp.LoadPrefixDB(path)
+			if err != nil {
+				log.Fatalf("Failed to load ASN prefix database: %v", err)
+			}
+			log.Printf("Loaded ASN prefix database %s: %d prefixes", path, prefixDB.Len())
+			resolvers = append(resolvers, prefixDB)
 		}
-	} else {
-		log.Println("WARNING: No ISP config file specified. Using fallback ASN org names.")
+		if cfg.CymruFallback {
+			resolvers = append(resolvers, isp.NewCymruResolver())
+		}
+		classifier.SetResolvers(resolvers...)
 	}
 
 	// Initialize pinger
@@ -83,10 +138,54 @@ func main() {
 
 	// Initialize scheduler
 	scheduler := monitor.NewScheduler(db, pinger, cfg.PingInterval, cfg.ExpireDays)
+	scheduler.SetStatusThresholds(cfg.Thresholds)
+	scheduler.SetRecheckInterval(cfg.RecheckInterval)
+	scheduler.SetProbeWorkers(cfg.ProbeWorkers)
+	strategy, err := models.ParseProbeStrategy(cfg.ProbeStrategy)
+	if err != nil {
+		log.Fatalf("Invalid --probe-strategy: %v", err)
+	}
+	scheduler.SetProbeStrategy(strategy)
+	if cfg.BackupDir != "" {
+		scheduler.SetBackups(cfg.BackupDir, cfg.BackupInterval, cfg.BackupKeep)
+		log.Printf("Backing up database to %s every %s (keeping %d)", cfg.BackupDir, cfg.BackupInterval, cfg.BackupKeep)
+	}
+
+	// Traceroute needs a raw ICMP socket, so hop fallback requires privileged mode
+	if cfg.Privileged {
+		scheduler.SetTracer(monitor.NewTracer(2*time.Second, 30), cfg.HopRefresh)
+	} else {
+		log.Println("Hop discovery disabled (requires --privileged)")
+	}
+
+	// Incident alerts go to the notifiers configured via /api/admin/notifiers
+	notifier := notify.NewManager(db)
+	scheduler.SetNotifier(notifier)
 
 	// Setup HTTP server
 	handler := api.NewHandler(db, cfg.DBPath, classifier)
 	handler.SetMetricsProvider(scheduler) // Connect handler with scheduler for metrics
+	handler.SetNotifyManager(notifier)
+	handler.SetMetricsExport(cfg.MetricsToken, cfg.MetricsListen != "")
+	handler.SetSessionTTL(cfg.SessionTTL)
+	handler.SetBackups(cfg.BackupDir, cfg.BackupKeep)
+	handler.SetDefaultProbeStrategy(models.FormatProbeStrategy(strategy))
+
+	// ISP definitions come from --isp-config if set, otherwise from the database
+	// where /api/admin/isps manages them. Either way SIGHUP reloads them. A file
+	// is copied into the database while it has none, ready for database mode.
+	handler.SetISPConfigFile(cfg.ISPConfigPath)
+	if err := handler.ReloadISPConfig(); err != nil {
+		log.Fatalf("Failed to load ISP config: %v", err)
+	}
+	if classifier.Config().Len() == 0 {
+		log.Println("WARNING: No ISPs configured. Add them at /api/admin/isps; until then fallback ASN org names are used.")
+	}
+
+	// Live updates for /api/stream, published by the scheduler
+	broker := stream.NewBroker(0)
+	handler.SetBroker(broker)
+	scheduler.SetBroker(broker, handler.DashboardSnapshot)
 	mux := http.NewServeMux()
 
 	// Try to get embedded static files
@@ -135,11 +234,43 @@ func main() {
 		IdleTimeout:  60 * time.Second,
 	}
 
+	// Optional dedicated listener for Prometheus scrapes
+	var metricsServer *http.Server
+	if cfg.MetricsListen != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.HandleFunc("GET /metrics", handler.Metrics)
+		metricsServer = &http.Server{
+			Addr:         cfg.MetricsListen,
+			Handler:      metricsMux,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+		go func() {
+			log.Printf("Serving metrics on %s", cfg.MetricsListen)
+			if err := metricsServer.ListenAndServe(); err != http.ErrServerClosed {
+				log.Fatalf("Metrics server error: %v", err)
+			}
+		}()
+	}
+
 	// Start monitoring in background
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	notifier.Start(ctx)
 	scheduler.Start(ctx)
 
+	// Reload ISP definitions on SIGHUP, keeping the current ones if that fails
+	go func() {
+		hupCh := make(chan os.Signal, 1)
+		signal.Notify(hupCh, syscall.SIGHUP)
+		for range hupCh {
+			log.Println("Received SIGHUP, reloading ISP config")
+			if err := handler.ReloadISPConfig(); err != nil {
+				log.Printf("Failed to reload ISP config: %v", err)
+			}
+		}
+	}()
+
 	// Handle shutdown gracefully
 	go func() {
 		sigCh := make(chan os.Signal, 1)
@@ -149,6 +280,7 @@ func main() {
 		log.Println("Shutting down...")
 		cancel()
 		scheduler.Stop()
+		notifier.Stop()
 
 		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer shutdownCancel()
@@ -156,6 +288,9 @@ func main() {
 		if err := server.Shutdown(shutdownCtx); err != nil {
 			log.Printf("HTTP server shutdown error: %v", err)
 		}
+		if metricsServer != nil {
+			metricsServer.Shutdown(shutdownCtx)
+		}
 	}()
 
 	// Start HTTP server
@@ -171,16 +306,39 @@ func parseConfig() Config {
 	cfg := Config{}
 
 	var trustedProxies string
+	var asnDBPaths string
 
 	flag.StringVar(&cfg.DBPath, "db", getEnv("CCC_DB_PATH", "./ccc.db"), "Database file path")
 	flag.StringVar(&cfg.ListenAddr, "listen", getEnv("CCC_LISTEN_ADDR", ":8080"), "Listen address")
 	flag.DurationVar(&cfg.PingInterval, "ping-interval", getEnvDuration("CCC_PING_INTERVAL", 60*time.Second), "Ping interval")
+	flag.DurationVar(&cfg.RecheckInterval, "recheck-interval", getEnvDuration("CCC_RECHECK_INTERVAL", monitor.DefaultRecheckInterval), "Delay before re-probing an endpoint whose probe failed (doubles up to the ping interval)")
+	flag.IntVar(&cfg.ProbeWorkers, "probe-workers", getEnvInt("CCC_PROBE_WORKERS", monitor.DefaultProbeWorkers), "Endpoints probed at once")
 	flag.IntVar(&cfg.ExpireDays, "expire-days", getEnvInt("CCC_EXPIRE_DAYS", 3), "Days before endpoint expiry")
 	flag.BoolVar(&cfg.Privileged, "privileged", getEnvBool("CCC_PRIVILEGED", false), "Use privileged (raw socket) ICMP")
-	flag.StringVar(&cfg.SetPassword, "set-password", "", "Set admin password and exit")
+	flag.StringVar(&cfg.SetPassword, "set-password", "", "Set the password of the \"admin\" owner account and exit")
 	flag.StringVar(&trustedProxies, "trusted-proxies", getEnv("CCC_TRUSTED_PROXIES", ""), "Comma-separated list of trusted proxy IPs/CIDRs (e.g., 127.0.0.1,::1,10.0.0.0/8)")
 	flag.StringVar(&cfg.CORSOrigin, "cors-origin", getEnv("CCC_CORS_ORIGIN", ""), "Allowed CORS origin (empty = same-origin only)")
 	flag.StringVar(&cfg.ISPConfigPath, "isp-config", getEnv("CCC_ISP_CONFIG", ""), "Path to ISP config JSON file")
+	flag.StringVar(&asnDBPaths, "asn-db", getEnv("CCC_ASN_DB", ""), "Comma-separated IP-to-ASN prefix files (pfx2as or iptoasn TSV, optionally .gz)")
+	flag.BoolVar(&cfg.CymruFallback, "asn-cymru-fallback", getEnvBool("CCC_ASN_CYMRU_FALLBACK", false), "Fall back to Team Cymru DNS for addresses missing from --asn-db")
+	defaultThresholds := monitor.DefaultStatusThresholds()
+	flag.IntVar(&cfg.Thresholds.FailThreshold, "fail-threshold", getEnvInt("CCC_FAIL_THRESHOLD", defaultThresholds.FailThreshold), "Consecutive failed probes before an endpoint is marked down")
+	flag.IntVar(&cfg.Thresholds.RecoverThreshold, "recover-threshold", getEnvInt("CCC_RECOVER_THRESHOLD", defaultThresholds.RecoverThreshold), "Consecutive successful probes before a down endpoint is marked up")
+	cfg.Thresholds.DegradedLossPct = defaultThresholds.DegradedLossPct
+	defaultRetention := storage.DefaultHistoryRetention()
+	flag.DurationVar(&cfg.Retention.Raw, "retention-raw", getEnvDuration("CCC_RETENTION_RAW", defaultRetention.Raw), "Retention for raw per-cycle uptime snapshots")
+	flag.DurationVar(&cfg.Retention.FiveMin, "retention-5m", getEnvDuration("CCC_RETENTION_5M", defaultRetention.FiveMin), "Retention for 5-minute uptime buckets")
+	flag.DurationVar(&cfg.Retention.Hourly, "retention-1h", getEnvDuration("CCC_RETENTION_1H", defaultRetention.Hourly), "Retention for hourly uptime buckets")
+	flag.DurationVar(&cfg.Retention.Daily, "retention-1d", getEnvDuration("CCC_RETENTION_1D", defaultRetention.Daily), "Retention for daily uptime buckets")
+	flag.StringVar(&cfg.MetricsListen, "metrics-listen", getEnv("CCC_METRICS_LISTEN", ""), "Separate listen address for /metrics (empty = serve on the main listener)")
+	flag.StringVar(&cfg.MetricsToken, "metrics-token", getEnv("CCC_METRICS_TOKEN", ""), "Bearer token required to scrape /metrics")
+	flag.BoolVar(&cfg.AutoMigrate, "auto-migrate", getEnvBool("CCC_AUTO_MIGRATE", true), "Apply pending schema migrations at startup (otherwise run 'ccc-api migrate up')")
+	flag.StringVar(&cfg.BackupDir, "backup-dir", getEnv("CCC_BACKUP_DIR", ""), "Directory for rotating database backups (empty = disabled)")
+	flag.DurationVar(&cfg.BackupInterval, "backup-interval", getEnvDuration("CCC_BACKUP_INTERVAL", 24*time.Hour), "Time between rotating backups")
+	flag.IntVar(&cfg.BackupKeep, "backup-keep", getEnvInt("CCC_BACKUP_KEEP", 7), "Number of rotating backups to keep")
+	flag.DurationVar(&cfg.SessionTTL, "session-ttl", getEnvDuration("CCC_SESSION_TTL", 12*time.Hour), "Lifetime of admin login sessions")
+	flag.StringVar(&cfg.ProbeStrategy, "probe-strategy", getEnv("CCC_PROBE_STRATEGY", models.DefaultProbeStrategy), "Comma-separated probes tried in order for endpoints without their own (icmp, tcp:PORT, udp:PORT, http[:PORT/PATH], https[:PORT/PATH])")
+	flag.DurationVar(&cfg.HopRefresh, "hop-refresh", getEnvDuration("CCC_HOP_REFRESH", 6*time.Hour), "How often to re-discover monitored hops")
 
 	flag.Parse()
 
@@ -194,6 +352,13 @@ func parseConfig() Config {
 		}
 	}
 
+	for _, p := range strings.Split(asnDBPaths, ",") {
+		p = strings.TrimSpace(p)
+		if p != "" {
+			cfg.ASNDBPaths = append(cfg.ASNDBPaths, p)
+		}
+	}
+
 	return cfg
 }
 
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jonsson/ccc/internal/storage"
+)
+
+const migrateUsage = `Usage: ccc-api [flags] migrate <command>
+
+Commands:
+  status   List schema migrations and whether each has been applied
+  up       Apply all pending migrations`
+
+// runMigrateCommand handles the "migrate" subcommands. The database is
+// opened without migrating it so that status reflects the schema on disk.
+func runMigrateCommand(dbPath string, args []string) error {
+	if len(args) != 1 || (args[0] != "status" && args[0] != "up") {
+		return fmt.Errorf("invalid command\n\n%s", migrateUsage)
+	}
+
+	db, err := storage.Open(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if args[0] == "up" {
+		applied, err := db.Migrate()
+		if err != nil {
+			return err
+		}
+		version, err := db.SchemaVersion()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s); schema is at version %d.\n", applied, version)
+		return nil
+	}
+
+	status, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+	pending := 0
+	for _, m := range status {
+		applied := "pending"
+		if m.AppliedAt != nil {
+			applied = m.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		} else {
+			pending++
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", m.Version, m.Name, applied)
+	}
+	tw.Flush()
+	fmt.Printf("\n%d pending migration(s).\n", pending)
+	return nil
+}
+
+// openDatabase opens the database for the server and the user commands,
+// applying pending migrations unless --auto-migrate=false
+func openDatabase(cfg Config) (*storage.DB, error) {
+	if cfg.AutoMigrate {
+		return storage.New(cfg.DBPath)
+	}
+
+	db, err := storage.Open(cfg.DBPath)
+	if err != nil {
+		return nil, err
+	}
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if pending > 0 {
+		db.Close()
+		return nil, fmt.Errorf("database has %d pending migration(s); run 'ccc-api migrate up' first", pending)
+	}
+	return db, nil
+}
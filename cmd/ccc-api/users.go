@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/storage"
+)
+
+const minPasswordLength = 8
+
+const userUsage = `Usage: ccc-api [flags] user <command>
+
+Commands:
+  create <username> [viewer|operator|owner]   Create a user (default role: viewer)
+  disable <username>                          Disable a user and end their sessions
+  enable <username>                           Re-enable a disabled user
+  role <username> <viewer|operator|owner>     Change a user's role
+  passwd <username>                           Set a new password
+  list                                        List all users
+
+Passwords are read from standard input.`
+
+// runUserCommand handles the "user" subcommands for managing admin accounts
+func runUserCommand(db *storage.DB, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing command\n\n%s", userUsage)
+	}
+
+	switch cmd, args := args[0], args[1:]; {
+	case cmd == "create" && (len(args) == 1 || len(args) == 2):
+		role := models.RoleViewer
+		if len(args) == 2 {
+			role = models.AdminRole(args[1])
+		}
+		if !role.Valid() {
+			return fmt.Errorf("invalid role %q (use viewer, operator or owner)", role)
+		}
+		password, err := readPassword(args[0])
+		if err != nil {
+			return err
+		}
+		user, err := db.CreateAdminUser(args[0], password, role)
+		if err != nil {
+			return err
+		}
+		auditUserChange(db, models.AuditUserCreate, user.Username, nil, map[string]interface{}{"role": user.Role})
+		fmt.Printf("Created %s user %s.\n", user.Role, user.Username)
+
+	case (cmd == "disable" || cmd == "enable") && len(args) == 1:
+		existing, err := db.GetAdminUser(args[0])
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return fmt.Errorf("user %q not found", args[0])
+		}
+		if _, err := db.SetAdminUserDisabled(args[0], cmd == "disable"); err != nil {
+			return err
+		}
+		auditUserChange(db, models.AuditUserUpdate, existing.Username,
+			map[string]bool{"disabled": existing.Disabled}, map[string]bool{"disabled": cmd == "disable"})
+		fmt.Printf("User %s %sd.\n", args[0], cmd)
+
+	case cmd == "role" && len(args) == 2:
+		role := models.AdminRole(args[1])
+		if !role.Valid() {
+			return fmt.Errorf("invalid role %q (use viewer, operator or owner)", role)
+		}
+		existing, err := db.GetAdminUser(args[0])
+		if err != nil {
+			return err
+		}
+		if existing == nil {
+			return fmt.Errorf("user %q not found", args[0])
+		}
+		if _, err := db.SetAdminUserRole(args[0], role); err != nil {
+			return err
+		}
+		auditUserChange(db, models.AuditUserUpdate, existing.Username,
+			map[string]interface{}{"role": existing.Role}, map[string]interface{}{"role": role})
+		fmt.Printf("User %s is now %s.\n", args[0], role)
+
+	case cmd == "passwd" && len(args) == 1:
+		password, err := readPassword(args[0])
+		if err != nil {
+			return err
+		}
+		found, err := db.SetAdminUserPassword(args[0], password)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("user %q not found", args[0])
+		}
+		auditUserChange(db, models.AuditUserUpdate, args[0], nil, map[string]string{"password": "changed"})
+		fmt.Printf("Password for %s changed.\n", args[0])
+
+	case cmd == "list" && len(args) == 0:
+		users, err := db.ListAdminUsers()
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "USERNAME\tROLE\tSTATUS\tLAST LOGIN")
+		for _, u := range users {
+			status, lastLogin := "active", "-"
+			if u.Disabled {
+				status = "disabled"
+			}
+			if u.LastLogin != nil {
+				lastLogin = u.LastLogin.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", u.Username, u.Role, status, lastLogin)
+		}
+		tw.Flush()
+
+	default:
+		return fmt.Errorf("invalid command\n\n%s", userUsage)
+	}
+	return nil
+}
+
+// auditUserChange records an account change made from the command line
+func auditUserChange(db *storage.DB, action, username string, before, after interface{}) {
+	changes, _ := models.AuditDiff(before, after)
+	err := db.RecordAudit(models.AuditEntry{
+		Actor:   models.AuditActorCLI,
+		Action:  action,
+		Target:  "user:" + username,
+		Changes: changes,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+	}
+}
+
+// setDefaultAdminPassword implements --set-password: it sets the password of
+// the "admin" owner account, creating the account if needed
+func setDefaultAdminPassword(db *storage.DB, password string) error {
+	found, err := db.SetAdminUserPassword(storage.DefaultAdminUsername, password)
+	if err != nil || found {
+		return err
+	}
+	_, err = db.CreateAdminUser(storage.DefaultAdminUsername, password, models.RoleOwner)
+	return err
+}
+
+// readPassword reads a password line from standard input
+func readPassword(username string) (string, error) {
+	fmt.Fprintf(os.Stderr, "Password for %s: ", username)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if len(password) < minPasswordLength {
+		return "", fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	}
+	return password, nil
+}
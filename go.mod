@@ -6,11 +6,11 @@ require (
 	github.com/mattn/go-sqlite3 v1.14.33
 	github.com/prometheus-community/pro-bing v0.7.0
 	golang.org/x/crypto v0.36.0
+	golang.org/x/net v0.38.0
 )
 
 require (
 	github.com/google/uuid v1.6.0 // indirect
-	golang.org/x/net v0.38.0 // indirect
 	golang.org/x/sync v0.13.0 // indirect
 	golang.org/x/sys v0.31.0 // indirect
 )
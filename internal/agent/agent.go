@@ -0,0 +1,169 @@
+// Package agent probes endpoints from a remote vantage point, such as a
+// second uplink or a partner building, and reports the results to the
+// ccc-api server that assigned them
+package agent
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/monitor"
+)
+
+// retryDelay is how long the agent waits after failing to reach the server
+const retryDelay = 30 * time.Second
+
+// Agent runs probe cycles over the endpoints the server assigns to it
+type Agent struct {
+	client   *Client
+	probers  *monitor.Probers
+	workers  int
+	status   *monitor.StatusTracker
+	statuses map[string]models.EndpointStatus // Endpoint ID -> status after damping
+}
+
+// New creates an agent that probes with the given pinger, workers at a
+// time, damping status changes like the server does
+func New(client *Client, pinger *monitor.Pinger, workers int, thresholds monitor.StatusThresholds) *Agent {
+	return &Agent{
+		client:   client,
+		probers:  monitor.NewProbers(pinger),
+		workers:  max(workers, 1),
+		status:   monitor.NewStatusTracker(thresholds),
+		statuses: make(map[string]models.EndpointStatus),
+	}
+}
+
+// Run probes the assigned endpoints once per the server's ping interval
+// until ctx is cancelled
+func (a *Agent) Run(ctx context.Context) {
+	for {
+		start := time.Now()
+		wait := retryDelay
+		if interval, err := a.runCycle(ctx); err != nil {
+			log.Printf("Probe cycle failed: %v", err)
+		} else {
+			wait = interval - time.Since(start)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(max(wait, 0)):
+		}
+	}
+}
+
+// runCycle fetches the assignment, probes every endpoint in it and reports
+// the results. Returns the server's ping interval.
+func (a *Agent) runCycle(ctx context.Context) (time.Duration, error) {
+	assignment, err := a.client.Assignment(ctx)
+	if err != nil {
+		return 0, err
+	}
+	interval := time.Duration(assignment.IntervalSeconds) * time.Second
+
+	defaults, err := models.ParseProbeStrategy(strings.Join(assignment.DefaultProbeStrategy, ","))
+	if err != nil {
+		log.Printf("Invalid default probe strategy from server, using ICMP: %v", err)
+		defaults = nil
+	}
+
+	results := a.probeAll(assignment.Endpoints, defaults)
+
+	up := 0
+	for _, r := range results {
+		if r.Status.IsReachable() {
+			up++
+		}
+	}
+	log.Printf("Probed %d endpoints as %s: %d up, %d not", len(results), assignment.Agent, up, len(results)-up)
+
+	if err := a.client.Report(ctx, results); err != nil {
+		return 0, err
+	}
+	return interval, nil
+}
+
+// probeAll probes the endpoints with a pool of workers
+func (a *Agent) probeAll(endpoints []models.AgentEndpoint, defaults []models.ProbeSpec) []models.AgentResult {
+	jobs := make(chan models.AgentEndpoint)
+	results := make(chan models.AgentResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < min(a.workers, len(endpoints)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ep := range jobs {
+				results <- a.probe(ep, defaults)
+			}
+		}()
+	}
+	go func() {
+		for _, ep := range endpoints {
+			jobs <- ep
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var collected []models.AgentResult
+	for r := range results {
+		collected = append(collected, r)
+	}
+
+	// Workers read the previous statuses, so they are replaced only now.
+	// Endpoints that are no longer assigned are forgotten.
+	keep := make(map[string]bool, len(collected))
+	a.statuses = make(map[string]models.EndpointStatus, len(collected))
+	for _, r := range collected {
+		keep[r.EndpointID] = true
+		a.statuses[r.EndpointID] = r.Status
+	}
+	a.status.Prune(keep)
+	return collected
+}
+
+// probe checks one endpoint with its probe strategy, trying the secondary
+// address of a dual-stack endpoint if the primary does not answer
+func (a *Agent) probe(ep models.AgentEndpoint, defaults []models.ProbeSpec) models.AgentResult {
+	strategy := defaults
+	if len(ep.ProbeStrategy) > 0 {
+		parsed, err := models.ParseProbeStrategy(strings.Join(ep.ProbeStrategy, ","))
+		if err != nil {
+			log.Printf("Invalid probe strategy for %s, using default: %v", ep.ID, err)
+		} else {
+			strategy = parsed
+		}
+	}
+
+	result, spec := a.probers.Run(strategy, ep.IP)
+	if !result.Success && ep.SecondaryIP != "" {
+		if secondary, secondarySpec := a.probers.Run(strategy, ep.SecondaryIP); secondary.Success {
+			result, spec = secondary, secondarySpec
+		}
+	}
+
+	current, ok := a.statuses[ep.ID]
+	if !ok {
+		current = models.StatusUnknown
+	}
+	r := models.AgentResult{
+		EndpointID: ep.ID,
+		Status:     a.status.Observe(ep.ID, current, result),
+		Success:    result.Success,
+		LossPct:    result.Loss,
+		Method:     spec.String(),
+		CheckedAt:  time.Now(),
+	}
+	if result.Success {
+		r.RTTMs = float64(result.RTT) / float64(time.Millisecond)
+	}
+	return r
+}
@@ -0,0 +1,162 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/monitor"
+)
+
+// fakeServer stands in for the agent API of a ccc-api server
+type fakeServer struct {
+	assignment models.AgentAssignment
+	mu         sync.Mutex
+	reports    []models.AgentReport
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer test-key" {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid agent key"})
+		return
+	}
+	switch r.URL.Path {
+	case "/api/agent/endpoints":
+		json.NewEncoder(w).Encode(f.assignment)
+	case "/api/agent/results":
+		var report models.AgentReport
+		json.NewDecoder(r.Body).Decode(&report)
+		f.mu.Lock()
+		f.reports = append(f.reports, report)
+		f.mu.Unlock()
+		w.Write([]byte(`{}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeServer) lastReport(t *testing.T) map[string]models.AgentResult {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.reports) == 0 {
+		t.Fatal("no report received")
+	}
+	results := make(map[string]models.AgentResult)
+	for _, r := range f.reports[len(f.reports)-1].Results {
+		results[r.EndpointID] = r
+	}
+	return results
+}
+
+// loopbackPorts returns a TCP port that accepts connections and a UDP port
+// that never answers
+func loopbackPorts(t *testing.T) (tcpPort, silentUDPPort int) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { udp.Close() })
+	return ln.Addr().(*net.TCPAddr).Port, udp.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestRunCycle(t *testing.T) {
+	tcpPort, silentPort := loopbackPorts(t)
+	fake := &fakeServer{assignment: models.AgentAssignment{
+		Agent:                "north",
+		IntervalSeconds:      30,
+		DefaultProbeStrategy: []string{"tcp:" + strconv.Itoa(tcpPort)},
+		Endpoints: []models.AgentEndpoint{
+			{ID: "open", IP: "127.0.0.1"},
+			{ID: "silent", IP: "127.0.0.1", ProbeStrategy: []string{"udp:" + strconv.Itoa(silentPort)}},
+		},
+	}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	thresholds := monitor.StatusThresholds{FailThreshold: 2, RecoverThreshold: 2, DegradedLossPct: 50}
+	a := New(NewClient(srv.URL+"/", "test-key"), monitor.NewPinger(300*time.Millisecond, false), 4, thresholds)
+
+	interval, err := a.runCycle(context.Background())
+	if err != nil {
+		t.Fatalf("runCycle: %v", err)
+	}
+	if interval != 30*time.Second {
+		t.Errorf("interval = %s, want 30s", interval)
+	}
+
+	results := fake.lastReport(t)
+	open := results["open"]
+	if !open.Success || open.Status != models.StatusUp || open.Method != "tcp:"+strconv.Itoa(tcpPort) {
+		t.Errorf("open endpoint result = %+v", open)
+	}
+	if open.CheckedAt.IsZero() {
+		t.Error("result has no check time")
+	}
+	// One failure is below the fail threshold
+	if silent := results["silent"]; silent.Success || silent.Status != models.StatusUnknown {
+		t.Errorf("silent endpoint after one cycle = %+v", silent)
+	}
+
+	if _, err := a.runCycle(context.Background()); err != nil {
+		t.Fatalf("second runCycle: %v", err)
+	}
+	if silent := fake.lastReport(t)["silent"]; silent.Status != models.StatusDown {
+		t.Errorf("silent endpoint after two cycles = %+v", silent)
+	}
+}
+
+func TestClientRejectedKey(t *testing.T) {
+	srv := httptest.NewServer(&fakeServer{})
+	defer srv.Close()
+
+	_, err := NewClient(srv.URL, "wrong").Assignment(context.Background())
+	if err == nil {
+		t.Fatal("expected an error for a wrong key")
+	}
+	if want := "server returned 401 Unauthorized: Invalid agent key"; !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %q, want it to contain %q", err, want)
+	}
+}
+
+func TestClientReportBatches(t *testing.T) {
+	fake := &fakeServer{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	results := make([]models.AgentResult, reportBatch*2+1)
+	if err := NewClient(srv.URL, "test-key").Report(context.Background(), results); err != nil {
+		t.Fatal(err)
+	}
+	if len(fake.reports) != 3 {
+		t.Fatalf("sent %d requests, want 3", len(fake.reports))
+	}
+	if n := len(fake.reports[2].Results); n != 1 {
+		t.Errorf("last batch has %d results, want 1", n)
+	}
+}
@@ -0,0 +1,98 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// reportBatch is the most results sent in one request, keeping request
+// bodies well under the server's size limit
+const reportBatch = 1000
+
+// Client talks to the agent API of a ccc-api server
+type Client struct {
+	server string
+	key    string
+	http   *http.Client
+}
+
+// NewClient creates a client for the server at the given base URL,
+// authenticating with the agent's key
+func NewClient(server, key string) *Client {
+	return &Client{
+		server: strings.TrimRight(server, "/"),
+		key:    key,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Assignment fetches the endpoints this agent probes
+func (c *Client) Assignment(ctx context.Context) (*models.AgentAssignment, error) {
+	var assignment models.AgentAssignment
+	if err := c.do(ctx, http.MethodGet, "/api/agent/endpoints", nil, &assignment); err != nil {
+		return nil, fmt.Errorf("failed to fetch assignment: %w", err)
+	}
+	return &assignment, nil
+}
+
+// Report sends probe results to the server
+func (c *Client) Report(ctx context.Context, results []models.AgentResult) error {
+	for start := 0; start < len(results); start += reportBatch {
+		report := models.AgentReport{Results: results[start:min(start+reportBatch, len(results))]}
+		if err := c.do(ctx, http.MethodPost, "/api/agent/results", report, nil); err != nil {
+			return fmt.Errorf("failed to report results: %w", err)
+		}
+	}
+	return nil
+}
+
+// do sends one authenticated request, encoding body and decoding the
+// response into out if they are not nil
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.server+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.key)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+		if apiErr.Error != "" {
+			return fmt.Errorf("server returned %s: %s", resp.Status, apiErr.Error)
+		}
+		return fmt.Errorf("server returned %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,367 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/storage"
+)
+
+const (
+	minPassphraseLength = 6
+	maxInviteDays       = 365
+)
+
+// rejectionMessages explains each admission rejection code to the resident
+var rejectionMessages = map[string]string{
+	models.RejectISPNotAllowed:      "Registration is only available for building residents",
+	models.RejectNetworkNotAllowed:  "Registration is only available from the building's network",
+	models.RejectInviteRequired:     "An invite code is required to register",
+	models.RejectInviteInvalid:      "Unknown invite code",
+	models.RejectInviteUsed:         "This invite code has already been used",
+	models.RejectInviteExpired:      "This invite code has expired",
+	models.RejectPassphraseRequired: "The building passphrase is required to register",
+	models.RejectPassphraseWrong:    "Incorrect building passphrase",
+}
+
+// writeRejection writes a 403 whose "code" tells the client why
+// registration was refused
+func writeRejection(w http.ResponseWriter, code string) {
+	writeJSON(w, http.StatusForbidden, map[string]string{"error": rejectionMessages[code], "code": code})
+}
+
+// networkAdmitted reports whether an address is inside one of the
+// admission networks. No networks means any network.
+func (h *Handler) networkAdmitted(policy models.AdmissionPolicy, clientIP string) bool {
+	if len(policy.Networks) == 0 {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+
+	asn, looked := 0, false
+	for _, n := range policy.Networks {
+		if n.Prefix != "" {
+			_, prefix, err := net.ParseCIDR(n.Prefix)
+			if err != nil || !prefix.Contains(ip) {
+				continue
+			}
+		}
+		if n.ASN != 0 {
+			// Only resolve the origin ASN when a rule needs it
+			if !looked {
+				var err error
+				if asn, _, err = h.classifier.LookupASN(clientIP); err != nil {
+					log.Printf("ASN lookup failed for admission check: %v", err)
+				}
+				looked = true
+			}
+			if asn != n.ASN {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// requirements lists what a resident must supply to register
+func requirements(policy models.AdmissionPolicy) []string {
+	var requires []string
+	if policy.RequireInvite {
+		requires = append(requires, models.RequireInvite)
+	}
+	if policy.RequirePassphrase {
+		requires = append(requires, models.RequirePassphrase)
+	}
+	return requires
+}
+
+// applyAdmissionStatus fills in whether an unregistered caller of an
+// allowed ISP may register and what they must supply. A browser holding
+// the device token of an endpoint that lacks the caller's address family
+// links that address instead, which needs no invite or passphrase.
+func (h *Handler) applyAdmissionStatus(response *models.StatusResponse, clientIP, deviceToken string) {
+	policy, err := h.db.GetAdmissionPolicy()
+	if err != nil {
+		log.Printf("Failed to get admission policy: %v", err)
+		return
+	}
+	if !h.networkAdmitted(policy, clientIP) {
+		response.CanRegister = false
+		response.RejectReason = models.RejectNetworkNotAllowed
+		return
+	}
+	if h.linkable(clientIP, deviceToken) {
+		return
+	}
+	response.Requires = requirements(policy)
+}
+
+// linkable reports whether deviceToken belongs to an endpoint the caller's
+// address could be linked to as its other address family
+func (h *Handler) linkable(clientIP, deviceToken string) bool {
+	if deviceToken == "" {
+		return false
+	}
+	endpoint, err := h.db.FindByToken(storage.TokenDevice, deviceToken)
+	if err != nil {
+		log.Printf("Database error looking up device token: %v", err)
+		return false
+	}
+	return endpoint != nil && endpoint.SecondaryIP == "" && addressFamily(clientIP) != addressFamily(endpoint.IP)
+}
+
+// admit applies the admission policy to a new registration. A required
+// invite is claimed for endpointID, so the caller must release it if the
+// registration then fails. It writes the rejection and returns false if
+// the resident is not admitted.
+func (h *Handler) admit(w http.ResponseWriter, r *http.Request, req models.RegisterRequest, clientIP, endpointID string) bool {
+	policy, err := h.db.GetAdmissionPolicy()
+	if err != nil {
+		log.Printf("Failed to get admission policy: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+
+	if !h.networkAdmitted(policy, clientIP) {
+		log.Printf("Registration rejected for %s: outside admission networks", clientIP)
+		writeRejection(w, models.RejectNetworkNotAllowed)
+		return false
+	}
+
+	// Codes and passphrases can be guessed, so attempts count like logins
+	if (policy.RequirePassphrase && req.Passphrase != "") || (policy.RequireInvite && req.InviteCode != "") {
+		if !h.allowAuthAttempt(w, r) {
+			return false
+		}
+	}
+
+	if policy.RequirePassphrase {
+		if req.Passphrase == "" {
+			writeRejection(w, models.RejectPassphraseRequired)
+			return false
+		}
+		ok, err := h.db.CheckAdmissionPassphrase(strings.TrimSpace(req.Passphrase))
+		if err != nil {
+			log.Printf("Failed to check passphrase: %v", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return false
+		}
+		if !ok {
+			log.Printf("Registration rejected for %s: incorrect passphrase", clientIP)
+			writeRejection(w, models.RejectPassphraseWrong)
+			return false
+		}
+	}
+
+	// Claimed last, so a code is not used up by a registration refused above
+	if policy.RequireInvite {
+		if req.InviteCode == "" {
+			writeRejection(w, models.RejectInviteRequired)
+			return false
+		}
+		reason, err := h.db.ClaimInvite(req.InviteCode, endpointID)
+		if err != nil {
+			log.Printf("Failed to claim invite: %v", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return false
+		}
+		if reason != "" {
+			log.Printf("Registration rejected for %s: %s", clientIP, reason)
+			writeRejection(w, reason)
+			return false
+		}
+	}
+	return true
+}
+
+// AdminAdmission is the admin view of the admission policy
+type AdminAdmission struct {
+	models.AdmissionPolicy
+	PassphraseSet bool `json:"passphrase_set"`
+}
+
+// AdminAdmissionUpdate is the body of PUT /api/admin/admission
+type AdminAdmissionUpdate struct {
+	models.AdmissionPolicy
+	Passphrase *string `json:"passphrase,omitempty"` // New passphrase; "" removes it, absent keeps it
+}
+
+// loadAdminAdmission reads the policy and whether a passphrase is set
+func (h *Handler) loadAdminAdmission() (AdminAdmission, error) {
+	policy, err := h.db.GetAdmissionPolicy()
+	if err != nil {
+		return AdminAdmission{}, err
+	}
+	set, err := h.db.HasAdmissionPassphrase()
+	return AdminAdmission{AdmissionPolicy: policy, PassphraseSet: set}, err
+}
+
+// AdminGetAdmission handles GET /api/admin/admission
+func (h *Handler) AdminGetAdmission(w http.ResponseWriter, r *http.Request) {
+	admission, err := h.loadAdminAdmission()
+	if err != nil {
+		log.Printf("Failed to get admission policy: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	writeJSON(w, http.StatusOK, admission)
+}
+
+// AdminUpdateAdmission handles PUT /api/admin/admission
+func (h *Handler) AdminUpdateAdmission(w http.ResponseWriter, r *http.Request) {
+	var req AdminAdmissionUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	policy, err := req.AdmissionPolicy.Validate()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	before, err := h.loadAdminAdmission()
+	if err != nil {
+		log.Printf("Failed to get admission policy: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	passphraseSet := before.PassphraseSet
+	if req.Passphrase != nil {
+		passphrase := strings.TrimSpace(*req.Passphrase)
+		if passphrase != "" && len(passphrase) < minPassphraseLength {
+			writeError(w, http.StatusBadRequest, "passphrase must be at least 6 characters")
+			return
+		}
+		passphraseSet = passphrase != ""
+	}
+	if policy.RequirePassphrase && !passphraseSet {
+		writeError(w, http.StatusBadRequest, "set a passphrase before requiring one")
+		return
+	}
+
+	if req.Passphrase != nil {
+		if err := h.db.SetAdmissionPassphrase(strings.TrimSpace(*req.Passphrase)); err != nil {
+			log.Printf("Failed to save passphrase: %v", err)
+			writeError(w, http.StatusInternalServerError, "Failed to save admission policy")
+			return
+		}
+	}
+	if err := h.db.SetAdmissionPolicy(policy); err != nil {
+		log.Printf("Failed to save admission policy: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to save admission policy")
+		return
+	}
+
+	after := AdminAdmission{AdmissionPolicy: policy, PassphraseSet: passphraseSet}
+	auditAfter := map[string]interface{}{
+		"networks":           after.Networks,
+		"require_invite":     after.RequireInvite,
+		"require_passphrase": after.RequirePassphrase,
+		"passphrase_set":     after.PassphraseSet,
+	}
+	if req.Passphrase != nil && passphraseSet {
+		auditAfter["passphrase"] = "changed"
+	}
+	h.audit(r, models.AuditAdmissionUpdate, "", before, auditAfter)
+	writeJSON(w, http.StatusOK, after)
+}
+
+// InviteCreateRequest is the body of POST /api/admin/invites
+type InviteCreateRequest struct {
+	Note        string `json:"note"`
+	ExpiresDays int    `json:"expires_days"` // 0 = never
+}
+
+// InviteCreateResponse returns a new invite with its code, shown only once
+type InviteCreateResponse struct {
+	models.Invite
+	Code string `json:"code"`
+}
+
+// AdminListInvites handles GET /api/admin/invites
+func (h *Handler) AdminListInvites(w http.ResponseWriter, r *http.Request) {
+	invites, err := h.db.ListInvites()
+	if err != nil {
+		log.Printf("Failed to list invites: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if invites == nil {
+		invites = []models.Invite{}
+	}
+	writeJSON(w, http.StatusOK, invites)
+}
+
+// AdminCreateInvite handles POST /api/admin/invites
+func (h *Handler) AdminCreateInvite(w http.ResponseWriter, r *http.Request) {
+	var req InviteCreateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+	}
+	if req.ExpiresDays < 0 || req.ExpiresDays > maxInviteDays {
+		writeError(w, http.StatusBadRequest, "expires_days must be between 0 and 365")
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresDays)
+		expiresAt = &t
+	}
+	createdBy := ""
+	if user := AdminUserFromContext(r.Context()); user != nil {
+		createdBy = user.Username
+	}
+
+	invite, code, err := h.db.CreateInvite(strings.TrimSpace(req.Note), createdBy, expiresAt)
+	if err != nil {
+		log.Printf("Failed to create invite: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create invite")
+		return
+	}
+
+	h.audit(r, models.AuditInviteCreate, inviteTarget(invite.ID), nil, invite)
+	writeJSON(w, http.StatusCreated, InviteCreateResponse{Invite: *invite, Code: code})
+}
+
+// AdminDeleteInvite handles DELETE /api/admin/invites/{id}
+func (h *Handler) AdminDeleteInvite(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid invite ID")
+		return
+	}
+
+	found, err := h.db.DeleteInvite(id)
+	if err != nil {
+		log.Printf("Failed to delete invite: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "Invite not found")
+		return
+	}
+
+	h.audit(r, models.AuditInviteDelete, inviteTarget(id), nil, nil)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Invite revoked"})
+}
+
+func inviteTarget(id int64) string {
+	return "invite:" + strconv.FormatInt(id, 10)
+}
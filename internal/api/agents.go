@@ -0,0 +1,361 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// agentKey is the request context key for the authenticated probe agent
+type agentKey struct{}
+
+// AgentFromContext returns the probe agent authenticated by
+// requireAgentAuth, or nil outside agent routes
+func AgentFromContext(ctx context.Context) *models.ProbeAgent {
+	agent, _ := ctx.Value(agentKey{}).(*models.ProbeAgent)
+	return agent
+}
+
+// requireAgentAuth wraps a handler with probe agent key auth
+// ("Authorization: Bearer <key>") and auth rate limiting
+func (h *Handler) requireAgentAuth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !h.allowAuthAttempt(w, r) {
+			return
+		}
+
+		key, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			writeError(w, http.StatusUnauthorized, "Agent key required")
+			return
+		}
+		agent, err := h.db.AuthenticateProbeAgent(key)
+		if err != nil {
+			log.Printf("Failed to authenticate probe agent: %v", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		if agent == nil {
+			writeError(w, http.StatusUnauthorized, "Invalid agent key")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), agentKey{}, agent)))
+	}
+}
+
+// assignedEndpoints returns the active endpoints an agent probes
+func (h *Handler) assignedEndpoints(agent *models.ProbeAgent) ([]models.Endpoint, error) {
+	endpoints, err := h.db.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	assigned := endpoints[:0]
+	for _, ep := range endpoints {
+		if ep.IsPaused(now) {
+			continue
+		}
+		if len(agent.ISPs) > 0 && !slices.Contains(agent.ISPs, ep.ISP) {
+			continue
+		}
+		assigned = append(assigned, ep)
+	}
+	return assigned, nil
+}
+
+// AgentEndpoints handles GET /api/agent/endpoints
+func (h *Handler) AgentEndpoints(w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	endpoints, err := h.assignedEndpoints(agent)
+	if err != nil {
+		log.Printf("Failed to list endpoints for agent %s: %v", agent.Name, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	interval := 60 * time.Second
+	if h.metricsProvider != nil {
+		interval = h.metricsProvider.PingInterval()
+	}
+	response := models.AgentAssignment{
+		Agent:                agent.Name,
+		IntervalSeconds:      int(interval.Seconds()),
+		DefaultProbeStrategy: strings.Split(h.defaultProbeStrategy, ","),
+		Endpoints:            make([]models.AgentEndpoint, 0, len(endpoints)),
+	}
+	for _, ep := range endpoints {
+		ae := models.AgentEndpoint{ID: ep.ID, IP: ep.IP, SecondaryIP: ep.SecondaryIP}
+		if ep.ProbeStrategy != "" {
+			ae.ProbeStrategy = strings.Split(ep.ProbeStrategy, ",")
+		}
+		response.Endpoints = append(response.Endpoints, ae)
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// AgentReportResponse is the response of POST /api/agent/results
+type AgentReportResponse struct {
+	Accepted int `json:"accepted"`
+	Ignored  int `json:"ignored"` // Results for endpoints not assigned to the agent, or without a status
+}
+
+// AgentReportResults handles POST /api/agent/results
+func (h *Handler) AgentReportResults(w http.ResponseWriter, r *http.Request) {
+	agent := AgentFromContext(r.Context())
+	var report models.AgentReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	endpoints, err := h.assignedEndpoints(agent)
+	if err != nil {
+		log.Printf("Failed to list endpoints for agent %s: %v", agent.Name, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	assigned := make(map[string]bool, len(endpoints))
+	for _, ep := range endpoints {
+		assigned[ep.ID] = true
+	}
+
+	// The agent's clock may be off; results are never taken as newer than
+	// their arrival
+	now := time.Now()
+	var accepted []models.AgentResult
+	for _, result := range report.Results {
+		result.Status = models.ParseEndpointStatus(string(result.Status))
+		if !assigned[result.EndpointID] || result.Status == models.StatusUnknown {
+			continue
+		}
+		if result.CheckedAt.IsZero() || result.CheckedAt.After(now) {
+			result.CheckedAt = now
+		}
+		result.CheckedAt = result.CheckedAt.Local()
+		accepted = append(accepted, result)
+	}
+
+	if err := h.db.RecordAgentResults(agent.ID, accepted); err != nil {
+		log.Printf("Failed to record results of agent %s: %v", agent.Name, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	writeJSON(w, http.StatusOK, AgentReportResponse{
+		Accepted: len(accepted),
+		Ignored:  len(report.Results) - len(accepted),
+	})
+}
+
+// ProbeAgentRequest is the body of POST and PUT /api/admin/agents
+type ProbeAgentRequest struct {
+	Name string   `json:"name"`
+	ISPs []string `json:"isps,omitempty"` // ISPs whose endpoints it probes; empty = all
+}
+
+// ProbeAgentKeyResponse returns an agent with its key, which is shown once
+type ProbeAgentKeyResponse struct {
+	Agent models.ProbeAgent `json:"agent"`
+	Key   string            `json:"key"`
+}
+
+// agentFromRequest validates a request body. Returns an error message
+// suitable for the client, or "" if valid.
+func agentFromRequest(r *http.Request) (models.ProbeAgent, string) {
+	var req ProbeAgentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return models.ProbeAgent{}, "Invalid JSON body"
+	}
+
+	a := models.ProbeAgent{Name: strings.TrimSpace(req.Name)}
+	if a.Name == "" || len(a.Name) > 64 {
+		return models.ProbeAgent{}, "name must be 1 to 64 characters"
+	}
+	if a.Name == models.VantageServer {
+		return models.ProbeAgent{}, "name \"" + models.VantageServer + "\" is reserved for the monitoring server"
+	}
+	for _, isp := range req.ISPs {
+		isp = strings.TrimSpace(isp)
+		if isp != "" && !slices.Contains(a.ISPs, isp) {
+			a.ISPs = append(a.ISPs, isp)
+		}
+	}
+	return a, ""
+}
+
+// agentNameFree checks that no other agent has the same name, writing the
+// error response if one does
+func (h *Handler) agentNameFree(w http.ResponseWriter, a models.ProbeAgent) bool {
+	agents, err := h.db.ListProbeAgents()
+	if err != nil {
+		log.Printf("Failed to list probe agents: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	for _, other := range agents {
+		if other.Name == a.Name && other.ID != a.ID {
+			writeError(w, http.StatusConflict, "Probe agent "+a.Name+" already exists")
+			return false
+		}
+	}
+	return true
+}
+
+// AdminListAgents handles GET /api/admin/agents
+func (h *Handler) AdminListAgents(w http.ResponseWriter, r *http.Request) {
+	agents, err := h.db.ListProbeAgents()
+	if err != nil {
+		log.Printf("Failed to list probe agents: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if agents == nil {
+		agents = []models.ProbeAgent{}
+	}
+	writeJSON(w, http.StatusOK, agents)
+}
+
+// AdminCreateAgent handles POST /api/admin/agents
+func (h *Handler) AdminCreateAgent(w http.ResponseWriter, r *http.Request) {
+	a, msg := agentFromRequest(r)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if !h.agentNameFree(w, a) {
+		return
+	}
+	key, err := h.db.CreateProbeAgent(&a)
+	if err != nil {
+		log.Printf("Failed to create probe agent: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create probe agent")
+		return
+	}
+
+	h.audit(r, models.AuditAgentCreate, agentTarget(a.ID), nil, a)
+	writeJSON(w, http.StatusCreated, ProbeAgentKeyResponse{Agent: a, Key: key})
+}
+
+// AdminUpdateAgent handles PUT /api/admin/agents/{id}
+func (h *Handler) AdminUpdateAgent(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid agent ID")
+		return
+	}
+	a, msg := agentFromRequest(r)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	a.ID = id
+
+	before, err := h.db.GetProbeAgent(id)
+	if err != nil {
+		log.Printf("Failed to get probe agent %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if before == nil {
+		writeError(w, http.StatusNotFound, "Probe agent not found")
+		return
+	}
+
+	if !h.agentNameFree(w, a) {
+		return
+	}
+	if _, err := h.db.UpdateProbeAgent(a); err != nil {
+		log.Printf("Failed to update probe agent %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Failed to update probe agent")
+		return
+	}
+
+	after, err := h.db.GetProbeAgent(id)
+	if err != nil || after == nil {
+		log.Printf("Failed to reload probe agent %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	h.audit(r, models.AuditAgentUpdate, agentTarget(id), before, after)
+	writeJSON(w, http.StatusOK, after)
+}
+
+// AdminRotateAgentKey handles POST /api/admin/agents/{id}/key
+func (h *Handler) AdminRotateAgentKey(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid agent ID")
+		return
+	}
+
+	key, err := h.db.RotateProbeAgentKey(id)
+	if err != nil {
+		log.Printf("Failed to rotate key of probe agent %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if key == "" {
+		writeError(w, http.StatusNotFound, "Probe agent not found")
+		return
+	}
+
+	agent, err := h.db.GetProbeAgent(id)
+	if err != nil || agent == nil {
+		log.Printf("Failed to reload probe agent %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	h.audit(r, models.AuditAgentRotateKey, agentTarget(id), nil, nil)
+	writeJSON(w, http.StatusOK, ProbeAgentKeyResponse{Agent: *agent, Key: key})
+}
+
+// AdminDeleteAgent handles DELETE /api/admin/agents/{id}
+func (h *Handler) AdminDeleteAgent(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid agent ID")
+		return
+	}
+
+	before, err := h.db.GetProbeAgent(id)
+	if err != nil {
+		log.Printf("Failed to get probe agent %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if before == nil {
+		writeError(w, http.StatusNotFound, "Probe agent not found")
+		return
+	}
+
+	if _, err := h.db.DeleteProbeAgent(id); err != nil {
+		log.Printf("Failed to delete probe agent %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	h.audit(r, models.AuditAgentDelete, agentTarget(id), before, nil)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Probe agent deleted"})
+}
+
+// AdminDisagreements handles GET /api/admin/disagreements
+func (h *Handler) AdminDisagreements(w http.ResponseWriter, r *http.Request) {
+	disagreements := []models.VantageDisagreement{}
+	if h.metricsProvider != nil {
+		disagreements = h.metricsProvider.VantageDisagreements()
+	}
+	writeJSON(w, http.StatusOK, disagreements)
+}
+
+func agentTarget(id int64) string {
+	return "agent:" + strconv.FormatInt(id, 10)
+}
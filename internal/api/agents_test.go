@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonsson/ccc/internal/agent"
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/storage"
+)
+
+// agentTestServer serves the API over a fresh database holding two Comcast
+// endpoints and one Starry endpoint, with an agent assigned to Comcast.
+// Returns the server, the database and the agent's key.
+func agentTestServer(t *testing.T) (*httptest.Server, *storage.DB, string) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "ccc.db")
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, ep := range []models.Endpoint{
+		{ID: "CCC-Endpoint-c1", IP: "198.51.100.1", ISP: "Comcast", Status: models.StatusUp, ProbeStrategy: "tcp:443"},
+		{ID: "CCC-Endpoint-c2", IP: "198.51.100.2", SecondaryIP: "2001:db8::2", ISP: "Comcast", Status: models.StatusUp},
+		{ID: "CCC-Endpoint-s1", IP: "198.51.100.3", ISP: "Starry", Status: models.StatusUp},
+	} {
+		if err := db.Create(&ep); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	key, err := db.CreateProbeAgent(&models.ProbeAgent{Name: "north", ISPs: []string{"Comcast"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := NewHandler(db, dbPath, nil)
+	h.SetDefaultProbeStrategy("icmp,tcp:80")
+	mux := http.NewServeMux()
+	h.SetupRoutes(mux, nil)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, db, key
+}
+
+func TestAgentAuth(t *testing.T) {
+	srv, _, key := agentTestServer(t)
+
+	for _, tt := range []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"no key", "", http.StatusUnauthorized},
+		{"wrong key", "Bearer nope", http.StatusUnauthorized},
+		{"basic auth", "Basic YWRtaW46cHc=", http.StatusUnauthorized},
+		{"agent key", "Bearer " + key, http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/agent/endpoints", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgentEndpoints(t *testing.T) {
+	srv, db, key := agentTestServer(t)
+
+	assignment, err := agent.NewClient(srv.URL, key).Assignment(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if assignment.Agent != "north" || assignment.IntervalSeconds != 60 {
+		t.Errorf("assignment = %+v", assignment)
+	}
+	if len(assignment.DefaultProbeStrategy) != 2 || assignment.DefaultProbeStrategy[1] != "tcp:80" {
+		t.Errorf("default probe strategy = %v", assignment.DefaultProbeStrategy)
+	}
+
+	got := make(map[string]models.AgentEndpoint)
+	for _, ep := range assignment.Endpoints {
+		got[ep.ID] = ep
+	}
+	if len(got) != 2 {
+		t.Fatalf("assigned %d endpoints, want the 2 Comcast ones: %+v", len(got), assignment.Endpoints)
+	}
+	if ep := got["CCC-Endpoint-c1"]; ep.IP != "198.51.100.1" || len(ep.ProbeStrategy) != 1 || ep.ProbeStrategy[0] != "tcp:443" {
+		t.Errorf("c1 = %+v", ep)
+	}
+	if ep := got["CCC-Endpoint-c2"]; ep.SecondaryIP != "2001:db8::2" {
+		t.Errorf("c2 = %+v", ep)
+	}
+
+	a, err := db.GetProbeAgent(1)
+	if err != nil || a == nil || a.LastSeen == nil {
+		t.Errorf("agent last_seen not recorded: %+v, %v", a, err)
+	}
+}
+
+func TestAgentReportResults(t *testing.T) {
+	srv, db, key := agentTestServer(t)
+
+	past := time.Now().Add(-10 * time.Second).Truncate(time.Second)
+	report := models.AgentReport{Results: []models.AgentResult{
+		{EndpointID: "CCC-Endpoint-c1", Status: models.StatusDown, CheckedAt: past},
+		{EndpointID: "CCC-Endpoint-c2", Status: models.StatusUp, Success: true, RTTMs: 12.5, Method: "icmp", CheckedAt: time.Now().Add(time.Hour)},
+		{EndpointID: "CCC-Endpoint-s1", Status: models.StatusDown, CheckedAt: past}, // Not assigned
+		{EndpointID: "CCC-Endpoint-missing", Status: models.StatusDown},             // Does not exist
+		{EndpointID: "CCC-Endpoint-c1", Status: "bogus"},                            // Unknown status
+	}}
+	body, _ := json.Marshal(report)
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/api/agent/results", bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+key)
+	before := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+
+	var counts AgentReportResponse
+	json.NewDecoder(resp.Body).Decode(&counts)
+	if counts.Accepted != 2 || counts.Ignored != 3 {
+		t.Errorf("counts = %+v, want 2 accepted and 3 ignored", counts)
+	}
+
+	observations, err := db.GetAgentObservations(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observations) != 2 || len(observations["CCC-Endpoint-s1"]) != 0 {
+		t.Fatalf("observations = %+v", observations)
+	}
+
+	c1 := observations["CCC-Endpoint-c1"]
+	if len(c1) != 1 || c1[0].Agent != "north" || c1[0].Status != models.StatusDown || !c1[0].CheckedAt.Equal(past) {
+		t.Errorf("c1 observations = %+v", c1)
+	}
+	c2 := observations["CCC-Endpoint-c2"]
+	if len(c2) != 1 || c2[0].RTTMs != 12.5 || c2[0].Method != "icmp" {
+		t.Fatalf("c2 observations = %+v", c2)
+	}
+	if c2[0].CheckedAt.Before(before.Add(-time.Second)) || c2[0].CheckedAt.After(time.Now()) {
+		t.Errorf("future check time not clamped to arrival: %s", c2[0].CheckedAt)
+	}
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/storage"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+// audit records a change made by the authenticated admin. before and after
+// are compared field by field; pass nil for the side that does not exist.
+// Failures are logged, not returned: the change itself has already happened.
+func (h *Handler) audit(r *http.Request, action, target string, before, after interface{}) {
+	actor := ""
+	if user := AdminUserFromContext(r.Context()); user != nil {
+		actor = user.Username
+	}
+
+	changes, err := models.AuditDiff(before, after)
+	if err != nil {
+		log.Printf("Failed to diff audit entry %s %s: %v", action, target, err)
+	}
+
+	err = h.db.RecordAudit(models.AuditEntry{
+		Actor:    actor,
+		ClientIP: GetClientIP(r),
+		Action:   action,
+		Target:   target,
+		Changes:  changes,
+	})
+	if err != nil {
+		log.Printf("Failed to record audit entry %s %s: %v", action, target, err)
+	}
+}
+
+// AdminAuditLog handles GET /api/admin/audit
+// Filters: actor, action (exact or category, e.g. "endpoint"), target,
+// since and until (RFC 3339). Paginated with limit and offset.
+func (h *Handler) AdminAuditLog(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := storage.AuditFilter{
+		Actor:  q.Get("actor"),
+		Action: q.Get("action"),
+		Target: q.Get("target"),
+		Limit:  defaultAuditLimit,
+	}
+
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxAuditLimit {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		filter.Limit = n
+	}
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			writeError(w, http.StatusBadRequest, "offset must be a non-negative integer")
+			return
+		}
+		filter.Offset = n
+	}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"since", &filter.Since}, {"until", &filter.Until}} {
+		if v := q.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, p.name+" must be an RFC 3339 timestamp")
+				return
+			}
+			*p.dst = t
+		}
+	}
+
+	entries, total, err := h.db.ListAudit(filter)
+	if err != nil {
+		log.Printf("Failed to list audit log: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if entries == nil {
+		entries = []models.AuditEntry{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"entries": entries,
+		"total":   total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+	})
+}
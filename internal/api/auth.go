@@ -0,0 +1,213 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jonsson/ccc/internal/metrics"
+	"github.com/jonsson/ccc/internal/models"
+)
+
+const (
+	sessionCookieName = "ccc_session"
+	defaultSessionTTL = 12 * time.Hour
+)
+
+// adminUserKey is the request context key for the authenticated admin
+type adminUserKey struct{}
+
+// AdminUserFromContext returns the admin authenticated by requireAdminAuth,
+// or nil outside admin routes
+func AdminUserFromContext(ctx context.Context) *models.AdminUser {
+	user, _ := ctx.Value(adminUserKey{}).(*models.AdminUser)
+	return user
+}
+
+// requireAdminAuth wraps a handler with session or basic auth, a minimum
+// role and auth rate limiting
+func (h *Handler) requireAdminAuth(role models.AdminRole, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Apply auth-specific rate limiting (prevent brute force)
+		if !h.allowAuthAttempt(w, r) {
+			return
+		}
+
+		user, ok := h.authenticateAdmin(w, r)
+		if !ok {
+			return
+		}
+		if !user.Role.AtLeast(role) {
+			writeError(w, http.StatusForbidden, "Your role does not permit this action")
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), adminUserKey{}, user)))
+	}
+}
+
+// allowAuthAttempt applies the auth rate limiter, writing 429 if exceeded
+func (h *Handler) allowAuthAttempt(w http.ResponseWriter, r *http.Request) bool {
+	if h.authRateLimiter != nil && !h.authRateLimiter.Allow(GetClientIP(r)) {
+		metrics.RateLimited.Inc("auth")
+		w.Header().Set("Retry-After", "10")
+		writeError(w, http.StatusTooManyRequests, "Too many authentication attempts")
+		return false
+	}
+	return true
+}
+
+// authenticateAdmin resolves the admin from the session cookie, falling back
+// to basic auth for scripts. It writes the error response on failure.
+// No WWW-Authenticate challenge is sent, so browsers never show their own
+// login prompt over the admin panel.
+func (h *Handler) authenticateAdmin(w http.ResponseWriter, r *http.Request) (*models.AdminUser, bool) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		user, err := h.db.GetAdminSession(cookie.Value)
+		if err != nil {
+			log.Printf("Failed to look up admin session: %v", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return nil, false
+		}
+		if user != nil {
+			return user, true
+		}
+		h.clearSessionCookie(w, r)
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		hasUsers, err := h.db.HasAdminUsers()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return nil, false
+		}
+		if !hasUsers {
+			writeError(w, http.StatusForbidden, "Admin access is disabled (no admin users configured)")
+			return nil, false
+		}
+		writeError(w, http.StatusUnauthorized, "Authentication required")
+		return nil, false
+	}
+
+	user, err := h.db.AuthenticateAdminUser(username, password)
+	if err != nil {
+		log.Printf("Failed to authenticate admin: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return nil, false
+	}
+	if user == nil {
+		writeError(w, http.StatusUnauthorized, "Invalid username or password")
+		return nil, false
+	}
+	return user, true
+}
+
+// AdminLoginRequest is the request body for POST /api/admin/login
+type AdminLoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// AdminSessionResponse describes the logged-in admin
+type AdminSessionResponse struct {
+	User      models.AdminUser `json:"user"`
+	ExpiresAt *time.Time       `json:"expires_at,omitempty"`
+}
+
+// AdminLogin handles POST /api/admin/login
+// On success an HttpOnly session cookie is set.
+func (h *Handler) AdminLogin(w http.ResponseWriter, r *http.Request) {
+	if !h.allowAuthAttempt(w, r) {
+		return
+	}
+
+	var req AdminLoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if req.Username == "" || req.Password == "" {
+		writeError(w, http.StatusBadRequest, "Username and password are required")
+		return
+	}
+
+	user, err := h.db.AuthenticateAdminUser(req.Username, req.Password)
+	if err != nil {
+		log.Printf("Failed to authenticate admin: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if user == nil {
+		log.Printf("Failed admin login for %q from %s", req.Username, GetClientIP(r))
+		writeError(w, http.StatusUnauthorized, "Invalid username or password")
+		return
+	}
+
+	token, expiresAt, err := h.db.CreateAdminSession(user.ID, h.sessionTTL)
+	if err != nil {
+		log.Printf("Failed to create admin session: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Path:     "/api/",
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   IsSecureRequest(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	log.Printf("Admin %s logged in", user.Username)
+	writeJSON(w, http.StatusOK, AdminSessionResponse{User: *user, ExpiresAt: &expiresAt})
+}
+
+// AdminLogout handles POST /api/admin/logout
+func (h *Handler) AdminLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if err := h.db.DeleteAdminSession(cookie.Value); err != nil {
+			log.Printf("Failed to delete admin session: %v", err)
+		}
+	}
+	h.clearSessionCookie(w, r)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Logged out"})
+}
+
+// AdminMe handles GET /api/admin/me
+func (h *Handler) AdminMe(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, AdminSessionResponse{User: *AdminUserFromContext(r.Context())})
+}
+
+// AdminListUsers handles GET /api/admin/users
+// Accounts are managed with the "ccc-api user" subcommands.
+func (h *Handler) AdminListUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.db.ListAdminUsers()
+	if err != nil {
+		log.Printf("Failed to list admin users: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if users == nil {
+		users = []models.AdminUser{}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"users": users,
+	})
+}
+
+func (h *Handler) clearSessionCookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/api/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   IsSecureRequest(r),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
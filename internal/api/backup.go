@@ -0,0 +1,110 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/storage"
+)
+
+// backupWriteTimeout bounds a snapshot download, overriding the server's
+// short WriteTimeout
+const backupWriteTimeout = 10 * time.Minute
+
+// SetBackups sets the rotating backup directory managed by the scheduler
+func (h *Handler) SetBackups(dir string, keep int) {
+	h.backupDir = dir
+	h.backupKeep = keep
+}
+
+// AdminDownloadBackup handles GET /api/admin/backup
+// Streams a consistent snapshot of the live database.
+func (h *Handler) AdminDownloadBackup(w http.ResponseWriter, r *http.Request) {
+	tmpDir, err := os.MkdirTemp("", "ccc-backup-")
+	if err != nil {
+		log.Printf("Failed to create backup temp dir: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create backup")
+		return
+	}
+	defer os.RemoveAll(tmpDir)
+
+	name := "ccc-" + time.Now().Format("20060102-150405") + ".db"
+	path := filepath.Join(tmpDir, name)
+	if err := h.db.Backup(path); err != nil {
+		log.Printf("Failed to create backup: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create backup")
+		return
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("Failed to open backup: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create backup")
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create backup")
+		return
+	}
+
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(backupWriteTimeout))
+
+	h.audit(r, models.AuditBackupDownload, name, nil, nil)
+	w.Header().Set("Content-Type", "application/vnd.sqlite3")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	w.Header().Set("Content-Length", fmt.Sprint(info.Size()))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, f); err != nil {
+		log.Printf("Failed to send backup: %v", err)
+	}
+}
+
+// AdminListBackups handles GET /api/admin/backups
+func (h *Handler) AdminListBackups(w http.ResponseWriter, r *http.Request) {
+	if h.backupDir == "" {
+		writeError(w, http.StatusNotFound, "Scheduled backups are not configured (--backup-dir)")
+		return
+	}
+
+	backups, err := storage.ListBackups(h.backupDir)
+	if err != nil {
+		log.Printf("Failed to list backups: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to list backups")
+		return
+	}
+	if backups == nil {
+		backups = []models.BackupInfo{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"backups": backups,
+	})
+}
+
+// AdminCreateBackup handles POST /api/admin/backups
+// Takes a rotating backup now, in addition to the scheduled ones.
+func (h *Handler) AdminCreateBackup(w http.ResponseWriter, r *http.Request) {
+	if h.backupDir == "" {
+		writeError(w, http.StatusNotFound, "Scheduled backups are not configured (--backup-dir)")
+		return
+	}
+
+	info, err := h.db.RotateBackup(h.backupDir, h.backupKeep)
+	if err != nil {
+		log.Printf("Failed to create backup: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create backup")
+		return
+	}
+
+	log.Printf("Admin created backup %s", info.Name)
+	h.audit(r, models.AuditBackupCreate, info.Name, nil, nil)
+	writeJSON(w, http.StatusCreated, info)
+}
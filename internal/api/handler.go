@@ -5,14 +5,20 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/jonsson/ccc/internal/isp"
 	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/notify"
 	"github.com/jonsson/ccc/internal/storage"
+	"github.com/jonsson/ccc/internal/stream"
 )
 
 const Version = "0.1.0"
@@ -21,11 +27,14 @@ const Version = "0.1.0"
 type MetricsProvider interface {
 	HasAnyOutage() bool
 	IsISPOutage(isp string) bool
+	MonitorOffline() bool
+	VantageDisagreements() []models.VantageDisagreement
 	LastPingTime() time.Time
 	PingInterval() time.Duration
 	NextPingTime() time.Time
 	PingCycleCount() int64
 	StartTime() time.Time
+	ForgetEndpoint(id string) // Drops in-memory probe state after an address change
 }
 
 // For backwards compatibility
@@ -33,19 +42,31 @@ type OutageChecker = MetricsProvider
 
 // Handler holds dependencies for HTTP handlers
 type Handler struct {
-	db              *storage.DB
-	dbPath          string
-	classifier      *isp.Classifier
-	metricsProvider MetricsProvider
-	authRateLimiter *RateLimiter
+	db                   *storage.DB
+	dbPath               string
+	classifier           *isp.Classifier
+	metricsProvider      MetricsProvider
+	authRateLimiter      *RateLimiter
+	notifyManager        *notify.Manager
+	broker               *stream.Broker
+	metricsToken         string
+	metricsSeparate      bool
+	sessionTTL           time.Duration
+	backupDir            string
+	backupKeep           int
+	ispConfigFile        string     // Set when ASN mappings come from --isp-config
+	defaultProbeStrategy string     // Sent to probe agents for endpoints without their own
+	ispMu                sync.Mutex // Serializes reloads of the ASN mappings
 }
 
 // NewHandler creates a new API handler
 func NewHandler(db *storage.DB, dbPath string, classifier *isp.Classifier) *Handler {
 	return &Handler{
-		db:         db,
-		dbPath:     dbPath,
-		classifier: classifier,
+		db:                   db,
+		dbPath:               dbPath,
+		classifier:           classifier,
+		sessionTTL:           defaultSessionTTL,
+		defaultProbeStrategy: models.DefaultProbeStrategy,
 	}
 }
 
@@ -64,6 +85,31 @@ func (h *Handler) SetAuthRateLimiter(rl *RateLimiter) {
 	h.authRateLimiter = rl
 }
 
+// SetDefaultProbeStrategy sets the probe strategy probe agents use for
+// endpoints without their own
+func (h *Handler) SetDefaultProbeStrategy(strategy string) {
+	if strategy != "" {
+		h.defaultProbeStrategy = strategy
+	}
+}
+
+// SetSessionTTL sets how long admin login sessions last
+func (h *Handler) SetSessionTTL(ttl time.Duration) {
+	if ttl > 0 {
+		h.sessionTTL = ttl
+	}
+}
+
+// SetNotifyManager sets the notification manager used for test deliveries
+func (h *Handler) SetNotifyManager(m *notify.Manager) {
+	h.notifyManager = m
+}
+
+// SetBroker sets the live update broker served by /api/stream
+func (h *Handler) SetBroker(b *stream.Broker) {
+	h.broker = b
+}
+
 // Health handles GET /api/health
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, models.HealthResponse{
@@ -91,15 +137,31 @@ func (h *Handler) Status(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// A device token from a browser that registered under a previous address
+	// moves the endpoint to the caller's new address
+	if endpoint == nil {
+		if token := r.Header.Get(deviceTokenHeader); token != "" {
+			endpoint = h.reassociateEndpoint(clientIP, ispName, token)
+		}
+	}
+
 	response := models.StatusResponse{
 		ISP:         ispName,
 		Registered:  endpoint != nil,
-		CanRegister: h.classifier.IsAllowed(ispName),
+		CanRegister: h.classifier.IsAllowed(ispName, clientIP),
+	}
+	if !response.CanRegister {
+		response.RejectReason = models.RejectISPNotAllowed
+	} else if endpoint == nil {
+		h.applyAdmissionStatus(&response, clientIP, r.Header.Get(deviceTokenHeader))
 	}
 
 	if endpoint != nil {
 		response.EndpointID = &endpoint.ID
 		response.EndpointStatus = endpoint.Status
+		if endpoint.IsPaused(time.Now()) {
+			response.PausedUntil = endpoint.PausedUntil
+		}
 		// Update last seen
 		if err := h.db.UpdateLastSeen(endpoint.ID); err != nil {
 			log.Printf("Failed to update last_seen for %s: %v", endpoint.ID, err)
@@ -152,9 +214,23 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Check if ISP is allowed to register
-	if !h.classifier.IsAllowed(ispName) {
+	if !h.classifier.IsAllowed(ispName, clientIP) {
 		log.Printf("Registration rejected for %s: ISP %s not allowed", clientIP, ispName)
-		writeError(w, http.StatusForbidden, "Registration is only available for building residents")
+		writeRejection(w, models.RejectISPNotAllowed)
+		return
+	}
+
+	// A device token means this browser already registered from the other
+	// address family; attach this address to that endpoint instead
+	var req models.RegisterRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+	}
+	if req.DeviceToken != "" {
+		h.linkSecondaryAddress(w, clientIP, req.DeviceToken)
 		return
 	}
 
@@ -166,61 +242,148 @@ func (h *Handler) Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Check the admission policy (network, passphrase, invite)
+	if !h.admit(w, r, req, clientIP, endpointID) {
+		return
+	}
+
 	// Create endpoint
 	endpoint := &models.Endpoint{
 		ID:        endpointID,
-		IPv4:      clientIP,
+		IP:        clientIP,
 		ISP:       ispName,
-		Status:    "unknown",
+		Status:    models.StatusUnknown,
 		CreatedAt: time.Now(),
 		LastSeen:  time.Now(),
 	}
 
 	if err := h.db.Create(endpoint); err != nil {
 		log.Printf("Failed to create endpoint: %v", err)
+		if err := h.db.ReleaseInvite(endpointID); err != nil {
+			log.Printf("Failed to release invite: %v", err)
+		}
 		writeError(w, http.StatusInternalServerError, "Failed to register")
 		return
 	}
 
+	deviceToken, err := h.db.CreateEndpointToken(endpointID, storage.TokenDevice)
+	if err != nil {
+		// Registration still succeeded; the resident just can't link a second address
+		log.Printf("Failed to issue device token for %s: %v", endpointID, err)
+	}
+	deletionToken, err := h.db.CreateEndpointToken(endpointID, storage.TokenDeletion)
+	if err != nil {
+		// Unregistering from the same network still works without it
+		log.Printf("Failed to issue deletion token for %s: %v", endpointID, err)
+	}
+
 	log.Printf("Registered new endpoint: %s (ISP: %s)", endpointID, ispName)
 
 	writeJSON(w, http.StatusCreated, models.RegisterResponse{
-		EndpointID: endpointID,
-		ISP:        ispName,
-		Message:    "Successfully registered for monitoring",
+		EndpointID:    endpointID,
+		ISP:           ispName,
+		Message:       "Successfully registered for monitoring",
+		DeviceToken:   deviceToken,
+		DeletionToken: deletionToken,
 	})
 }
 
+// linkSecondaryAddress attaches the caller's address to the endpoint that
+// owns the device token, provided it is the endpoint's missing address
+// family and comes from an admitted network
+func (h *Handler) linkSecondaryAddress(w http.ResponseWriter, clientIP, deviceToken string) {
+	endpoint, err := h.db.FindByToken(storage.TokenDevice, deviceToken)
+	if err != nil {
+		log.Printf("Database error looking up device token: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if endpoint == nil {
+		writeError(w, http.StatusNotFound, "Unknown device token")
+		return
+	}
+
+	if addressFamily(clientIP) == addressFamily(endpoint.IP) {
+		writeError(w, http.StatusConflict, "This device is already registered from another address")
+		return
+	}
+	if endpoint.SecondaryIP != "" {
+		writeError(w, http.StatusConflict, "This device already has an IPv4 and an IPv6 address")
+		return
+	}
+
+	// The token stands in for the invite and passphrase, but the address
+	// must still come from an admitted network
+	policy, err := h.db.GetAdmissionPolicy()
+	if err != nil {
+		log.Printf("Failed to get admission policy: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !h.networkAdmitted(policy, clientIP) {
+		log.Printf("Address link rejected for %s: outside admission networks", clientIP)
+		writeRejection(w, models.RejectNetworkNotAllowed)
+		return
+	}
+
+	if err := h.db.SetSecondaryIP(endpoint.ID, clientIP); err != nil {
+		log.Printf("Failed to link address to %s: %v", endpoint.ID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to register")
+		return
+	}
+
+	log.Printf("Linked IPv%d address to endpoint %s", addressFamily(clientIP), endpoint.ID)
+
+	writeJSON(w, http.StatusOK, models.RegisterResponse{
+		EndpointID: endpoint.ID,
+		ISP:        endpoint.ISP,
+		Message:    fmt.Sprintf("Linked IPv%d address to existing endpoint", addressFamily(clientIP)),
+	})
+}
+
+// addressFamily returns 4 or 6 for a valid IP address, 0 otherwise
+func addressFamily(ip string) int {
+	parsed := net.ParseIP(ip)
+	switch {
+	case parsed == nil:
+		return 0
+	case parsed.To4() != nil:
+		return 4
+	default:
+		return 6
+	}
+}
+
 // Dashboard handles GET /api/dashboard
 func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
-	stats, err := h.db.GetISPStats()
+	response, err := h.DashboardSnapshot()
 	if err != nil {
 		log.Printf("Failed to get ISP stats: %v", err)
 		writeError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
 
+	writeJSON(w, http.StatusOK, response)
+}
+
+// DashboardSnapshot builds the public dashboard. It is also published to
+// /api/stream subscribers after every ping cycle.
+func (h *Handler) DashboardSnapshot() (models.DashboardResponse, error) {
+	stats, err := h.db.GetISPStats()
+	if err != nil {
+		return models.DashboardResponse{}, err
+	}
+
 	// Add ASN for each ISP (for icon lookup)
 	for i := range stats {
 		stats[i].ASN = h.classifier.GetASNForDisplay(stats[i].Name)
 	}
 
-	// Determine if there's a likely outage
-	// First check the scheduler's hop-aware analysis
-	likelyOutage := false
+	// The scheduler applies the per-ISP outage policies after each cycle
+	likelyOutage, monitorOffline := false, false
 	if h.metricsProvider != nil {
 		likelyOutage = h.metricsProvider.HasAnyOutage()
-	}
-
-	// Fallback: check if any ISP has more than threshold% of endpoints down
-	if !likelyOutage {
-		threshold := h.db.GetOutageThreshold()
-		for _, s := range stats {
-			if s.TotalCount > 0 && float64(s.DownCount)/float64(s.TotalCount) > threshold {
-				likelyOutage = true
-				break
-			}
-		}
+		monitorOffline = h.metricsProvider.MonitorOffline()
 	}
 
 	// Get last ping time from scheduler, fallback to now if not available
@@ -232,9 +395,10 @@ func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := models.DashboardResponse{
-		ISPs:         stats,
-		LikelyOutage: likelyOutage,
-		LastUpdated:  lastUpdated,
+		ISPs:           stats,
+		LikelyOutage:   likelyOutage,
+		MonitorOffline: monitorOffline,
+		LastUpdated:    lastUpdated,
 	}
 
 	// Handle empty stats
@@ -242,7 +406,7 @@ func (h *Handler) Dashboard(w http.ResponseWriter, r *http.Request) {
 		response.ISPs = []models.ISPStatus{}
 	}
 
-	writeJSON(w, http.StatusOK, response)
+	return response, nil
 }
 
 // Events handles GET /api/events
@@ -264,6 +428,76 @@ func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// Incidents handles GET /api/incidents
+// Query parameters: isp (optional), limit (default 20, max 200)
+func (h *Handler) Incidents(w http.ResponseWriter, r *http.Request) {
+	h.listIncidents(w, r, false)
+}
+
+// Incident handles GET /api/incidents/{id}
+func (h *Handler) Incident(w http.ResponseWriter, r *http.Request) {
+	h.getIncident(w, r, false)
+}
+
+// listIncidents writes recent incidents. Public responses omit the shared
+// hop address, which identifies a router in the residents' path.
+func (h *Handler) listIncidents(w http.ResponseWriter, r *http.Request, admin bool) {
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 200 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 200")
+			return
+		}
+		limit = n
+	}
+
+	incidents, err := h.db.ListIncidents(r.URL.Query().Get("isp"), limit)
+	if err != nil {
+		log.Printf("Failed to list incidents: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if incidents == nil {
+		incidents = []models.Incident{}
+	}
+	if !admin {
+		for i := range incidents {
+			incidents[i].SharedHop = ""
+		}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"incidents": incidents,
+	})
+}
+
+// getIncident writes a single incident
+func (h *Handler) getIncident(w http.ResponseWriter, r *http.Request, admin bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid incident ID")
+		return
+	}
+
+	incident, err := h.db.GetIncident(id)
+	if err != nil {
+		log.Printf("Failed to get incident %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if incident == nil {
+		writeError(w, http.StatusNotFound, "Incident not found")
+		return
+	}
+
+	if !admin {
+		incident.SharedHop = ""
+	}
+	writeJSON(w, http.StatusOK, incident)
+}
+
 // generateEndpointID creates a random endpoint ID
 func generateEndpointID() (string, error) {
 	bytes := make([]byte, 4)
@@ -301,6 +535,14 @@ var privateIPNets = []net.IPNet{
 	{IP: net.IPv4(169, 254, 0, 0), Mask: net.CIDRMask(16, 32)},
 	// 0.0.0.0/8
 	{IP: net.IPv4(0, 0, 0, 0), Mask: net.CIDRMask(8, 32)},
+	// fc00::/7 (IPv6 unique local)
+	{IP: net.ParseIP("fc00::"), Mask: net.CIDRMask(7, 128)},
+	// fe80::/10 (IPv6 link-local)
+	{IP: net.ParseIP("fe80::"), Mask: net.CIDRMask(10, 128)},
+	// ::1/128 (IPv6 loopback)
+	{IP: net.IPv6loopback, Mask: net.CIDRMask(128, 128)},
+	// ::/128 (IPv6 unspecified)
+	{IP: net.IPv6unspecified, Mask: net.CIDRMask(128, 128)},
 }
 
 // isPrivateIP checks if an IP is in a private/reserved range
@@ -313,18 +555,62 @@ func isPrivateIP(ip net.IP) bool {
 	return false
 }
 
-// AdminEndpoint is the admin view of an endpoint (includes IP)
+// AdminEndpoint is the admin view of an endpoint (includes IPs)
 type AdminEndpoint struct {
-	ID           string    `json:"id"`
-	IPv4         string    `json:"ipv4"`
-	ISP          string    `json:"isp"`
-	Status       string    `json:"status"`
-	CreatedAt    time.Time `json:"created_at"`
-	LastSeen     time.Time `json:"last_seen"`
-	LastOK       time.Time `json:"last_ok,omitempty"`
-	MonitoredHop string    `json:"monitored_hop,omitempty"`
-	HopNumber    int       `json:"hop_number,omitempty"`
-	UseHop       bool      `json:"use_hop"`
+	ID            string                `json:"id"`
+	IPv4          string                `json:"ipv4,omitempty"`
+	IPv6          string                `json:"ipv6,omitempty"`
+	ISP           string                `json:"isp"`
+	Status        models.EndpointStatus `json:"status"`
+	CreatedAt     time.Time             `json:"created_at"`
+	LastSeen      time.Time             `json:"last_seen"`
+	LastOK        time.Time             `json:"last_ok,omitempty"`
+	MonitoredHop  string                `json:"monitored_hop,omitempty"`
+	HopNumber     int                   `json:"hop_number,omitempty"`
+	UseHop        bool                  `json:"use_hop"`
+	PausedUntil   *time.Time            `json:"paused_until,omitempty"`
+	ProbeStrategy []string              `json:"probe_strategy,omitempty"` // Empty = the default strategy
+}
+
+// newAdminEndpoint converts an endpoint to its admin view, splitting its
+// addresses by family
+func newAdminEndpoint(e *models.Endpoint) AdminEndpoint {
+	ae := AdminEndpoint{
+		ID:           e.ID,
+		ISP:          e.ISP,
+		Status:       e.Status,
+		CreatedAt:    e.CreatedAt,
+		LastSeen:     e.LastSeen,
+		LastOK:       e.LastOK,
+		MonitoredHop: e.MonitoredHop,
+		HopNumber:    e.HopNumber,
+		UseHop:       e.UseHop,
+	}
+	if e.IsPaused(time.Now()) {
+		ae.PausedUntil = e.PausedUntil
+	}
+	if e.ProbeStrategy != "" {
+		ae.ProbeStrategy = strings.Split(e.ProbeStrategy, ",")
+	}
+	for _, ip := range e.Addresses() {
+		if addressFamily(ip) == 4 {
+			ae.IPv4 = ip
+		} else {
+			ae.IPv6 = ip
+		}
+	}
+	return ae
+}
+
+// endpointAudit is what the audit log keeps of an added or deleted
+// endpoint. Addresses are left out because audit entries outlive it.
+func endpointAudit(e *models.Endpoint) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         e.ID,
+		"isp":        e.ISP,
+		"created_at": e.CreatedAt,
+		"last_seen":  e.LastSeen,
+	}
 }
 
 // AdminListEndpoints handles GET /api/admin/endpoints
@@ -338,28 +624,36 @@ func (h *Handler) AdminListEndpoints(w http.ResponseWriter, r *http.Request) {
 
 	// Convert to admin view
 	result := make([]AdminEndpoint, len(endpoints))
-	for i, e := range endpoints {
-		result[i] = AdminEndpoint{
-			ID:           e.ID,
-			IPv4:         e.IPv4,
-			ISP:          e.ISP,
-			Status:       e.Status,
-			CreatedAt:    e.CreatedAt,
-			LastSeen:     e.LastSeen,
-			LastOK:       e.LastOK,
-			MonitoredHop: e.MonitoredHop,
-			HopNumber:    e.HopNumber,
-			UseHop:       e.UseHop,
-		}
+	for i := range endpoints {
+		result[i] = newAdminEndpoint(&endpoints[i])
 	}
 
 	writeJSON(w, http.StatusOK, result)
 }
 
-// AdminAddEndpointRequest is the request body for adding an endpoint
+// AdminAddEndpointRequest is the request body for adding an endpoint.
+// At least one address is required; giving both registers a dual-stack endpoint.
 type AdminAddEndpointRequest struct {
-	IPv4 string `json:"ipv4"`
-	ISP  string `json:"isp,omitempty"` // Optional, will auto-detect if empty
+	IPv4          string   `json:"ipv4,omitempty"`
+	IPv6          string   `json:"ipv6,omitempty"`
+	ISP           string   `json:"isp,omitempty"`            // Optional, will auto-detect if empty
+	ProbeStrategy []string `json:"probe_strategy,omitempty"` // Optional, e.g. ["tcp:443", "icmp"]
+}
+
+// validateAdminAddress checks that ip is a public address of the given family.
+// Returns an error message suitable for the client, or "" if valid.
+func validateAdminAddress(ip string, family int) string {
+	// Validate IP address format
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil || addressFamily(ip) != family {
+		return fmt.Sprintf("Invalid IPv%d address format", family)
+	}
+
+	// Block private/internal IPs for security
+	if isPrivateIP(parsedIP) {
+		return "Private/internal IP addresses are not allowed"
+	}
+	return ""
 }
 
 // AdminAddEndpoint handles POST /api/admin/endpoints
@@ -370,57 +664,55 @@ func (h *Handler) AdminAddEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.IPv4 == "" {
-		writeError(w, http.StatusBadRequest, "ipv4 is required")
-		return
-	}
-
-	// Validate IP address format
-	parsedIP := net.ParseIP(req.IPv4)
-	if parsedIP == nil {
-		writeError(w, http.StatusBadRequest, "Invalid IPv4 address format")
+	if req.IPv4 == "" && req.IPv6 == "" {
+		writeError(w, http.StatusBadRequest, "ipv4 or ipv6 is required")
 		return
 	}
 
-	// Only allow IPv4
-	if parsedIP.To4() == nil {
-		writeError(w, http.StatusBadRequest, "Only IPv4 addresses are supported")
-		return
+	var addresses []string
+	for _, a := range []struct {
+		ip     string
+		family int
+	}{{req.IPv4, 4}, {req.IPv6, 6}} {
+		if a.ip == "" {
+			continue
+		}
+		if msg := validateAdminAddress(a.ip, a.family); msg != "" {
+			writeError(w, http.StatusBadRequest, msg)
+			return
+		}
+		addresses = append(addresses, a.ip)
 	}
 
-	// Block private/internal IPs for security
-	if isPrivateIP(parsedIP) {
-		writeError(w, http.StatusBadRequest, "Private/internal IP addresses are not allowed")
+	strategy, err := parseProbeStrategy(req.ProbeStrategy)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Check if already exists
-	existing, err := h.db.FindByIP(req.IPv4)
-	if err != nil {
-		log.Printf("Database error looking up %s: %v", req.IPv4, err)
-		writeError(w, http.StatusInternalServerError, "Database error")
-		return
-	}
+	for _, ip := range addresses {
+		existing, err := h.db.FindByIP(ip)
+		if err != nil {
+			log.Printf("Database error looking up %s: %v", ip, err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
 
-	if existing != nil {
-		writeJSON(w, http.StatusOK, AdminEndpoint{
-			ID:        existing.ID,
-			IPv4:      existing.IPv4,
-			ISP:       existing.ISP,
-			Status:    existing.Status,
-			CreatedAt: existing.CreatedAt,
-			LastSeen:  existing.LastSeen,
-			LastOK:    existing.LastOK,
-		})
-		return
+		if existing != nil {
+			writeJSON(w, http.StatusOK, newAdminEndpoint(existing))
+			return
+		}
 	}
 
 	// Classify ISP if not provided
+	primaryIP := addresses[0]
 	ispName := req.ISP
 	if ispName == "" {
-		ispName, err = h.classifier.ClassifyISP(req.IPv4)
+		var err error
+		ispName, err = h.classifier.ClassifyISP(primaryIP)
 		if err != nil {
-			log.Printf("ISP classification error for %s: %v", req.IPv4, err)
+			log.Printf("ISP classification error for %s: %v", primaryIP, err)
 			ispName = "unknown"
 		}
 	}
@@ -435,12 +727,16 @@ func (h *Handler) AdminAddEndpoint(w http.ResponseWriter, r *http.Request) {
 
 	// Create endpoint
 	endpoint := &models.Endpoint{
-		ID:        endpointID,
-		IPv4:      req.IPv4,
-		ISP:       ispName,
-		Status:    "unknown",
-		CreatedAt: time.Now(),
-		LastSeen:  time.Now(),
+		ID:            endpointID,
+		IP:            primaryIP,
+		ISP:           ispName,
+		Status:        models.StatusUnknown,
+		CreatedAt:     time.Now(),
+		LastSeen:      time.Now(),
+		ProbeStrategy: strategy,
+	}
+	if len(addresses) > 1 {
+		endpoint.SecondaryIP = addresses[1]
 	}
 
 	if err := h.db.Create(endpoint); err != nil {
@@ -449,16 +745,62 @@ func (h *Handler) AdminAddEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Admin added endpoint: %s (%s, ISP: %s)", endpointID, req.IPv4, ispName)
+	log.Printf("Admin added endpoint: %s (%v, ISP: %s)", endpointID, addresses, ispName)
+	h.audit(r, models.AuditEndpointCreate, endpointID, nil, endpointAudit(endpoint))
 
-	writeJSON(w, http.StatusCreated, AdminEndpoint{
-		ID:        endpoint.ID,
-		IPv4:      endpoint.IPv4,
-		ISP:       endpoint.ISP,
-		Status:    endpoint.Status,
-		CreatedAt: endpoint.CreatedAt,
-		LastSeen:  endpoint.LastSeen,
-	})
+	writeJSON(w, http.StatusCreated, newAdminEndpoint(endpoint))
+}
+
+// parseProbeStrategy validates probe specs and joins them in canonical form
+func parseProbeStrategy(specs []string) (string, error) {
+	strategy, err := models.ParseProbeStrategy(strings.Join(specs, ","))
+	if err != nil {
+		return "", err
+	}
+	return models.FormatProbeStrategy(strategy), nil
+}
+
+// AdminSetProbeStrategyRequest is the request body for changing how an
+// endpoint is probed
+type AdminSetProbeStrategyRequest struct {
+	ProbeStrategy []string `json:"probe_strategy"` // Tried in order; empty = the default strategy
+}
+
+// AdminSetProbeStrategy handles PUT /api/admin/endpoints/{id}/probes
+func (h *Handler) AdminSetProbeStrategy(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var req AdminSetProbeStrategyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	strategy, err := parseProbeStrategy(req.ProbeStrategy)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	existing, err := h.db.FindByID(id)
+	if err != nil {
+		log.Printf("Failed to get endpoint %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "Endpoint not found")
+		return
+	}
+
+	if _, err := h.db.SetProbeStrategy(id, strategy); err != nil {
+		log.Printf("Failed to set probe strategy for %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	updated := *existing
+	updated.ProbeStrategy = strategy
+	h.audit(r, models.AuditEndpointProbes, id, newAdminEndpoint(existing), newAdminEndpoint(&updated))
+	writeJSON(w, http.StatusOK, newAdminEndpoint(&updated))
 }
 
 // AdminDeleteEndpoint handles DELETE /api/admin/endpoints/{id}
@@ -469,6 +811,13 @@ func (h *Handler) AdminDeleteEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	existing, err := h.db.FindByID(id)
+	if err != nil {
+		log.Printf("Failed to get endpoint %s: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
 	deleted, err := h.db.DeleteByID(id)
 	if err != nil {
 		log.Printf("Failed to delete endpoint %s: %v", id, err)
@@ -476,19 +825,20 @@ func (h *Handler) AdminDeleteEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !deleted {
+	if !deleted || existing == nil {
 		writeError(w, http.StatusNotFound, "Endpoint not found")
 		return
 	}
 
 	log.Printf("Admin deleted endpoint: %s", id)
+	h.audit(r, models.AuditEndpointDelete, id, endpointAudit(existing), nil)
 	writeJSON(w, http.StatusOK, map[string]string{"message": "Endpoint deleted"})
 }
 
 // AdminMetrics handles GET /api/admin/metrics
 func (h *Handler) AdminMetrics(w http.ResponseWriter, r *http.Request) {
 	// Get endpoint metrics
-	total, up, down, unknown, direct, hopMonitored, err := h.db.GetEndpointMetrics()
+	total, up, degraded, down, unknown, direct, hopMonitored, err := h.db.GetEndpointMetrics()
 	if err != nil {
 		log.Printf("Failed to get endpoint metrics: %v", err)
 		writeError(w, http.StatusInternalServerError, "Database error")
@@ -502,6 +852,11 @@ func (h *Handler) AdminMetrics(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusInternalServerError, "Database error")
 		return
 	}
+	if h.metricsProvider != nil {
+		for i := range ispStats {
+			ispStats[i].LikelyOutage = h.metricsProvider.IsISPOutage(ispStats[i].Name)
+		}
+	}
 
 	// Get shared hop count
 	sharedHops, err := h.db.GetSharedHopCount()
@@ -527,7 +882,7 @@ func (h *Handler) AdminMetrics(w http.ResponseWriter, r *http.Request) {
 	// Calculate overall uptime percentage
 	var overallUptimePct float64
 	if total > 0 {
-		overallUptimePct = float64(up) / float64(total) * 100
+		overallUptimePct = float64(up+degraded) / float64(total) * 100
 	}
 
 	// Get scheduler metrics
@@ -559,25 +914,26 @@ func (h *Handler) AdminMetrics(w http.ResponseWriter, r *http.Request) {
 	}
 
 	metrics := models.AdminMetrics{
-		TotalEndpoints:   total,
-		EndpointsUp:      up,
-		EndpointsDown:    down,
-		EndpointsUnknown: unknown,
-		OverallUptimePct: overallUptimePct,
-		ISPStats:         ispStats,
-		LastPingTime:     lastPingTime,
-		PingInterval:     pingInterval,
-		NextPingTime:     nextPingTime,
-		TotalPingCycles:  totalPingCycles,
-		DirectMonitored:  direct,
-		HopMonitored:     hopMonitored,
-		SharedHops:       sharedHops,
-		ServerStartTime:  serverStartTime,
-		ServerUptime:     serverUptime,
-		Version:          Version,
-		DatabaseSize:     dbSize,
-		DatabasePath:     h.dbPath,
-		UptimeHistory:    history,
+		TotalEndpoints:    total,
+		EndpointsUp:       up,
+		EndpointsDegraded: degraded,
+		EndpointsDown:     down,
+		EndpointsUnknown:  unknown,
+		OverallUptimePct:  overallUptimePct,
+		ISPStats:          ispStats,
+		LastPingTime:      lastPingTime,
+		PingInterval:      pingInterval,
+		NextPingTime:      nextPingTime,
+		TotalPingCycles:   totalPingCycles,
+		DirectMonitored:   direct,
+		HopMonitored:      hopMonitored,
+		SharedHops:        sharedHops,
+		ServerStartTime:   serverStartTime,
+		ServerUptime:      serverUptime,
+		Version:           Version,
+		DatabaseSize:      dbSize,
+		DatabasePath:      h.dbPath,
+		UptimeHistory:     history,
 	}
 
 	// Handle nil slices for JSON
@@ -591,20 +947,110 @@ func (h *Handler) AdminMetrics(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, metrics)
 }
 
+// AdminHistory handles GET /api/admin/history
+// Query parameters: hours (default 24, max 2 years), isp (default building-wide)
+func (h *Handler) AdminHistory(w http.ResponseWriter, r *http.Request) {
+	hours := 24
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 2*365*24 {
+			writeError(w, http.StatusBadRequest, "hours must be between 1 and 17520")
+			return
+		}
+		hours = n
+	}
+
+	window := time.Duration(hours) * time.Hour
+	history, err := h.db.GetISPUptimeHistory(r.URL.Query().Get("isp"), window)
+	if err != nil {
+		log.Printf("Failed to get uptime history: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if history == nil {
+		history = []models.UptimePoint{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"history": history,
+	})
+}
+
+// AdminProbeResults handles GET /api/admin/probes
+// Query parameters: endpoint_id or isp (one required), hours (default 24)
+func (h *Handler) AdminProbeResults(w http.ResponseWriter, r *http.Request) {
+	endpointID := r.URL.Query().Get("endpoint_id")
+	ispName := r.URL.Query().Get("isp")
+	if endpointID == "" && ispName == "" {
+		writeError(w, http.StatusBadRequest, "endpoint_id or isp is required")
+		return
+	}
+
+	hours := 24
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 24*90 {
+			writeError(w, http.StatusBadRequest, "hours must be between 1 and 2160")
+			return
+		}
+		hours = n
+	}
+
+	to := time.Now()
+	from := to.Add(-time.Duration(hours) * time.Hour)
+
+	var results []models.ProbeResult
+	var err error
+	if endpointID != "" {
+		results, err = h.db.GetProbeResults(endpointID, from, to)
+	} else {
+		results, err = h.db.GetISPProbeResults(ispName, from, to)
+	}
+	if err != nil {
+		log.Printf("Failed to get probe results: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if results == nil {
+		results = []models.ProbeResult{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"probes": results,
+	})
+}
+
 // AdminSettings represents the configurable settings
 type AdminSettings struct {
-	OutageThreshold float64 `json:"outage_threshold"`
+	DefaultOutagePolicy models.OutagePolicy            `json:"default_outage_policy"`
+	OutagePolicies      map[string]models.OutagePolicy `json:"outage_policies"` // Per-ISP overrides by display name
+}
+
+// loadAdminSettings reads the current settings
+func (h *Handler) loadAdminSettings() (AdminSettings, error) {
+	policies, err := h.db.GetOutagePolicies()
+	return AdminSettings{
+		DefaultOutagePolicy: policies.Default,
+		OutagePolicies:      policies.ISPs,
+	}, err
 }
 
 // AdminGetSettings handles GET /api/admin/settings
 func (h *Handler) AdminGetSettings(w http.ResponseWriter, r *http.Request) {
-	settings := AdminSettings{
-		OutageThreshold: h.db.GetOutageThreshold(),
+	settings, err := h.loadAdminSettings()
+	if err != nil {
+		log.Printf("Failed to get settings: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
 	}
 	writeJSON(w, http.StatusOK, settings)
 }
 
 // AdminUpdateSettings handles PUT /api/admin/settings
+// The body replaces all settings; ISPs missing from outage_policies fall
+// back to the default policy.
 func (h *Handler) AdminUpdateSettings(w http.ResponseWriter, r *http.Request) {
 	var req AdminSettings
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -612,21 +1058,33 @@ func (h *Handler) AdminUpdateSettings(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if req.OutageThreshold < 0 || req.OutageThreshold > 1 {
-		writeError(w, http.StatusBadRequest, "Outage threshold must be between 0 and 1")
+	policies := storage.OutagePolicies{
+		Default: req.DefaultOutagePolicy,
+		ISPs:    req.OutagePolicies,
+	}
+	if err := policies.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	if err := h.db.SetOutageThreshold(req.OutageThreshold); err != nil {
+	before, err := h.loadAdminSettings()
+	if err != nil {
+		log.Printf("Failed to get settings: %v", err)
+	}
+	if err := h.db.SetOutagePolicies(policies); err != nil {
 		log.Printf("Failed to save settings: %v", err)
 		writeError(w, http.StatusInternalServerError, "Failed to save settings")
 		return
 	}
+	after, err := h.loadAdminSettings()
+	if err != nil {
+		log.Printf("Failed to get settings: %v", err)
+	}
 
-	log.Printf("Admin updated settings: outage_threshold=%.0f%%", req.OutageThreshold*100)
-	writeJSON(w, http.StatusOK, AdminSettings{
-		OutageThreshold: h.db.GetOutageThreshold(),
-	})
+	log.Printf("Admin updated settings: default outage threshold %.0f%%, %d ISP policies",
+		after.DefaultOutagePolicy.DownThreshold*100, len(after.OutagePolicies))
+	h.audit(r, models.AuditSettingsUpdate, "", before, after)
+	writeJSON(w, http.StatusOK, after)
 }
 
 // SiteConfig handles GET /api/site-config (public)
@@ -658,6 +1116,10 @@ func (h *Handler) AdminUpdateSiteConfig(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	before, err := h.db.GetSiteConfig()
+	if err != nil {
+		log.Printf("Failed to get site config: %v", err)
+	}
 	if err := h.db.SetSiteConfig(config); err != nil {
 		log.Printf("Failed to save site config: %v", err)
 		writeError(w, http.StatusInternalServerError, "Failed to save site config")
@@ -665,5 +1127,317 @@ func (h *Handler) AdminUpdateSiteConfig(w http.ResponseWriter, r *http.Request)
 	}
 
 	log.Printf("Admin updated site config")
+	h.audit(r, models.AuditSiteConfigUpdate, "", before, config)
 	writeJSON(w, http.StatusOK, config)
 }
+
+// AdminListIncidents handles GET /api/admin/incidents
+func (h *Handler) AdminListIncidents(w http.ResponseWriter, r *http.Request) {
+	h.listIncidents(w, r, true)
+}
+
+// AdminGetIncident handles GET /api/admin/incidents/{id}
+func (h *Handler) AdminGetIncident(w http.ResponseWriter, r *http.Request) {
+	h.getIncident(w, r, true)
+}
+
+// AdminAnnotateIncidentRequest is the request body for annotating an incident
+type AdminAnnotateIncidentRequest struct {
+	Note string `json:"note"`
+}
+
+// AdminAnnotateIncident handles PUT /api/admin/incidents/{id}
+func (h *Handler) AdminAnnotateIncident(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid incident ID")
+		return
+	}
+
+	var req AdminAnnotateIncidentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	if len(req.Note) > 2000 {
+		writeError(w, http.StatusBadRequest, "Note must be at most 2000 characters")
+		return
+	}
+
+	existing, err := h.db.GetIncident(id)
+	if err != nil {
+		log.Printf("Failed to get incident %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "Incident not found")
+		return
+	}
+
+	found, err := h.db.AnnotateIncident(id, req.Note)
+	if err != nil {
+		log.Printf("Failed to annotate incident %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Failed to save note")
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "Incident not found")
+		return
+	}
+
+	log.Printf("Admin annotated incident %d", id)
+	h.audit(r, models.AuditIncidentAnnotate, strconv.FormatInt(id, 10),
+		map[string]string{"note": existing.Note}, map[string]string{"note": req.Note})
+	h.getIncident(w, r, true)
+}
+
+// AdminNotifierRequest is the request body for creating or updating a notifier
+type AdminNotifierRequest struct {
+	Name    string          `json:"name"`
+	Type    string          `json:"type"`
+	Enabled *bool           `json:"enabled,omitempty"` // Defaults to true
+	Config  json.RawMessage `json:"config"`
+}
+
+// redactNotifier hides secrets before a notifier config is returned
+func redactNotifier(n models.NotifierConfig) models.NotifierConfig {
+	n.Config = notify.RedactConfig(n.Config)
+	return n
+}
+
+// auditNotifier is the audited form of a notifier: redacted, without the
+// immutable ID and creation time
+func auditNotifier(n models.NotifierConfig) map[string]interface{} {
+	return map[string]interface{}{
+		"name":    n.Name,
+		"type":    n.Type,
+		"enabled": n.Enabled,
+		"config":  notify.RedactConfig(n.Config),
+	}
+}
+
+// notifierID parses the {id} path value
+func notifierID(r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	return id, err == nil
+}
+
+// AdminListNotifiers handles GET /api/admin/notifiers
+func (h *Handler) AdminListNotifiers(w http.ResponseWriter, r *http.Request) {
+	notifiers, err := h.db.ListNotifiers(false)
+	if err != nil {
+		log.Printf("Failed to list notifiers: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	result := make([]models.NotifierConfig, 0, len(notifiers))
+	for _, n := range notifiers {
+		result = append(result, redactNotifier(n))
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"notifiers": result,
+	})
+}
+
+// AdminCreateNotifier handles POST /api/admin/notifiers
+func (h *Handler) AdminCreateNotifier(w http.ResponseWriter, r *http.Request) {
+	var req AdminNotifierRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	n := models.NotifierConfig{
+		Name:    req.Name,
+		Type:    req.Type,
+		Enabled: req.Enabled == nil || *req.Enabled,
+		Config:  req.Config,
+	}
+	if msg := validateNotifier(n); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	id, err := h.db.CreateNotifier(n)
+	if err != nil {
+		log.Printf("Failed to create notifier: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create notifier")
+		return
+	}
+	n.ID = id
+	n.CreatedAt = time.Now()
+
+	log.Printf("Admin created %s notifier %d (%s)", n.Type, id, n.Name)
+	h.audit(r, models.AuditNotifierCreate, strconv.FormatInt(id, 10), nil, auditNotifier(n))
+	writeJSON(w, http.StatusCreated, redactNotifier(n))
+}
+
+// AdminUpdateNotifier handles PUT /api/admin/notifiers/{id}
+// Secret fields sent back as the redacted placeholder keep their stored value.
+func (h *Handler) AdminUpdateNotifier(w http.ResponseWriter, r *http.Request) {
+	id, ok := notifierID(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid notifier ID")
+		return
+	}
+
+	var req AdminNotifierRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+
+	existing, err := h.db.GetNotifier(id)
+	if err != nil {
+		log.Printf("Failed to get notifier %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if existing == nil {
+		writeError(w, http.StatusNotFound, "Notifier not found")
+		return
+	}
+
+	n := *existing
+	if req.Name != "" {
+		n.Name = req.Name
+	}
+	if req.Type != "" {
+		n.Type = req.Type
+	}
+	if req.Enabled != nil {
+		n.Enabled = *req.Enabled
+	}
+	if len(req.Config) > 0 {
+		merged, err := notify.MergeSecrets(req.Config, existing.Config)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "config must be a JSON object")
+			return
+		}
+		n.Config = merged
+	}
+	if msg := validateNotifier(n); msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if _, err := h.db.UpdateNotifier(n); err != nil {
+		log.Printf("Failed to update notifier %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Failed to update notifier")
+		return
+	}
+
+	log.Printf("Admin updated notifier %d (%s)", id, n.Name)
+	h.audit(r, models.AuditNotifierUpdate, strconv.FormatInt(id, 10), auditNotifier(*existing), auditNotifier(n))
+	writeJSON(w, http.StatusOK, redactNotifier(n))
+}
+
+// AdminDeleteNotifier handles DELETE /api/admin/notifiers/{id}
+func (h *Handler) AdminDeleteNotifier(w http.ResponseWriter, r *http.Request) {
+	id, ok := notifierID(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid notifier ID")
+		return
+	}
+
+	existing, err := h.db.GetNotifier(id)
+	if err != nil {
+		log.Printf("Failed to get notifier %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	deleted, err := h.db.DeleteNotifier(id)
+	if err != nil {
+		log.Printf("Failed to delete notifier %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if !deleted || existing == nil {
+		writeError(w, http.StatusNotFound, "Notifier not found")
+		return
+	}
+
+	log.Printf("Admin deleted notifier %d", id)
+	h.audit(r, models.AuditNotifierDelete, strconv.FormatInt(id, 10), auditNotifier(*existing), nil)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Notifier deleted"})
+}
+
+// AdminTestNotifier handles POST /api/admin/notifiers/{id}/test
+func (h *Handler) AdminTestNotifier(w http.ResponseWriter, r *http.Request) {
+	id, ok := notifierID(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid notifier ID")
+		return
+	}
+	if h.notifyManager == nil {
+		writeError(w, http.StatusServiceUnavailable, "Notifications are not enabled")
+		return
+	}
+
+	n, err := h.db.GetNotifier(id)
+	if err != nil {
+		log.Printf("Failed to get notifier %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if n == nil {
+		writeError(w, http.StatusNotFound, "Notifier not found")
+		return
+	}
+
+	if err := h.notifyManager.SendTest(r.Context(), *n); err != nil {
+		writeError(w, http.StatusBadGateway, "Test notification failed: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Test notification sent"})
+}
+
+// AdminNotifierDeliveries handles GET /api/admin/notifiers/{id}/deliveries
+// Query parameters: limit (default 50, max 500)
+func (h *Handler) AdminNotifierDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := notifierID(r)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid notifier ID")
+		return
+	}
+
+	limit := 50
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 500 {
+			writeError(w, http.StatusBadRequest, "limit must be between 1 and 500")
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.db.ListNotificationDeliveries(id, limit)
+	if err != nil {
+		log.Printf("Failed to list deliveries for notifier %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []models.NotificationDelivery{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"deliveries": deliveries,
+	})
+}
+
+// validateNotifier returns an error message if the notifier config is invalid
+func validateNotifier(n models.NotifierConfig) string {
+	if n.Name == "" || len(n.Name) > 100 {
+		return "name is required (max 100 characters)"
+	}
+	if _, err := notify.New(n); err != nil {
+		return err.Error()
+	}
+	return ""
+}
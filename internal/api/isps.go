@@ -0,0 +1,436 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jonsson/ccc/internal/isp"
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// ISP definition sources reported by GET /api/admin/isps
+const (
+	ispSourceDatabase = "database"
+	ispSourceFile     = "file"
+)
+
+// SetISPConfigFile makes the --isp-config file the source of the ISP
+// definitions instead of the database. The file is read by ReloadISPConfig
+// and the admin API can no longer change the definitions.
+func (h *Handler) SetISPConfigFile(path string) {
+	h.ispConfigFile = path
+}
+
+// ReloadISPConfig loads the ISP definitions from their source into the
+// classifier. It runs at startup and on SIGHUP.
+func (h *Handler) ReloadISPConfig() error {
+	h.ispMu.Lock()
+	defer h.ispMu.Unlock()
+
+	if h.ispConfigFile != "" {
+		if err := h.classifier.LoadConfig(h.ispConfigFile); err != nil {
+			return err
+		}
+		return h.seedISPs()
+	}
+
+	isps, err := h.db.ListISPs()
+	if err != nil {
+		return err
+	}
+	config, err := isp.NewConfig(isps)
+	if err != nil {
+		return fmt.Errorf("invalid ISP config in database: %w", err)
+	}
+	h.classifier.SetConfig(config)
+	log.Printf("Loaded ISP config from database: %d ISPs", config.Len())
+	return nil
+}
+
+// seedISPs copies the definitions loaded from the --isp-config file into
+// the database while it has none, so dropping the flag later switches to
+// database mode with the same definitions
+func (h *Handler) seedISPs() error {
+	existing, err := h.db.ListISPs()
+	if err != nil {
+		return err
+	}
+	isps := h.classifier.Config().ISPs()
+	if len(existing) > 0 || len(isps) == 0 {
+		return nil
+	}
+	if err := h.db.ReplaceISPs(isps); err != nil {
+		return fmt.Errorf("failed to seed ISPs from config file: %w", err)
+	}
+	log.Printf("Copied %d ISPs from %s into the database", len(isps), h.ispConfigFile)
+	return nil
+}
+
+// ispConfigReadOnly rejects changes while the definitions come from a file
+func (h *Handler) ispConfigReadOnly(w http.ResponseWriter) bool {
+	if h.ispConfigFile == "" {
+		return false
+	}
+	writeError(w, http.StatusConflict, "ISP definitions are read from the --isp-config file; edit it and send SIGHUP to reload")
+	return true
+}
+
+// changeISPs validates the complete set of definitions that a change would
+// leave, saves the change and applies it to the classifier. The caller
+// holds h.ispMu from reading the current definitions until this returns.
+// It writes the response.
+func (h *Handler) changeISPs(w http.ResponseWriter, r *http.Request, status int, isps []models.ISPDefinition, save func(*isp.Config) error) {
+	config, err := isp.NewConfig(isps)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := save(config); err != nil {
+		log.Printf("Failed to save ISPs: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	h.classifier.SetConfig(config)
+
+	var reclassified *models.ReclassifyResult
+	if r.URL.Query().Get("reclassify") == "true" {
+		reclassified, err = h.reclassifyEndpoints(r)
+		if err != nil {
+			log.Printf("Failed to reclassify endpoints: %v", err)
+			writeError(w, http.StatusInternalServerError, "Saved, but failed to reclassify endpoints")
+			return
+		}
+	}
+	h.writeISPs(w, status, reclassified)
+}
+
+// reclassifyEndpoints looks up the ISP of every endpoint again under the
+// current definitions. Each address is tried in turn, primary first, and
+// one that the prefix rules of its ISP exclude is skipped; an endpoint
+// with no address left becomes Unknown. An endpoint whose ISP is still
+// defined and admits one of its addresses only moves to another defined
+// ISP, so ISPs set by hand on admin-added endpoints survive. The caller
+// holds h.ispMu.
+func (h *Handler) reclassifyEndpoints(r *http.Request) (*models.ReclassifyResult, error) {
+	endpoints, err := h.db.ListAll()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.ReclassifyResult{Checked: len(endpoints)}
+	changes := make(map[string]string)
+	for _, ep := range endpoints {
+		ispName, ok := h.classifyEndpoint(ep)
+		if !ok {
+			result.Failed++
+			continue
+		}
+		if ispName == ep.ISP || (!h.ispDefined(ispName) && h.ispDefined(ep.ISP) && h.admitsAny(ep.ISP, ep)) {
+			continue
+		}
+		if err := h.db.SetEndpointISP(ep.ID, ispName); err != nil {
+			return nil, err
+		}
+		changes[ep.ID] = ep.ISP + " -> " + ispName
+		result.Changed++
+	}
+
+	if result.Changed > 0 {
+		h.audit(r, models.AuditISPReclassify, "endpoints", nil, changes)
+	}
+	return result, nil
+}
+
+// classifyEndpoint returns the ISP of the first of an endpoint's addresses
+// that its ISP's prefix rules admit, or Unknown if they exclude them all.
+// It fails if no address could be looked up.
+func (h *Handler) classifyEndpoint(ep models.Endpoint) (string, bool) {
+	looked := false
+	for _, addr := range ep.Addresses() {
+		ispName, err := h.classifier.ClassifyISP(addr)
+		if err != nil {
+			log.Printf("ISP classification error for endpoint %s (%s): %v", ep.ID, addr, err)
+			continue
+		}
+		looked = true
+		if h.ispDefined(ispName) && !h.classifier.Admits(ispName, addr) {
+			continue
+		}
+		return ispName, true
+	}
+	return "Unknown", looked
+}
+
+// ispDefined reports whether an ISP name is one of the definitions rather
+// than a fallback AS organisation name
+func (h *Handler) ispDefined(ispName string) bool {
+	return h.classifier.GetASNForDisplay(ispName) != 0
+}
+
+// admitsAny reports whether the prefix rules of an ISP admit any of an
+// endpoint's addresses
+func (h *Handler) admitsAny(ispName string, ep models.Endpoint) bool {
+	for _, addr := range ep.Addresses() {
+		if h.classifier.Admits(ispName, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ispListResponse is the body of GET /api/admin/isps and of changes
+type ispListResponse struct {
+	Source       string                   `json:"source"` // "database" or "file"
+	ISPs         []models.ISPDefinition   `json:"isps"`
+	Reclassified *models.ReclassifyResult `json:"reclassified,omitempty"`
+}
+
+// writeISPs responds with the current definitions
+func (h *Handler) writeISPs(w http.ResponseWriter, status int, reclassified *models.ReclassifyResult) {
+	resp := ispListResponse{Source: ispSourceFile, Reclassified: reclassified}
+	if h.ispConfigFile == "" {
+		// The database copy carries update times
+		isps, err := h.db.ListISPs()
+		if err != nil {
+			log.Printf("Failed to list ISPs: %v", err)
+			writeError(w, http.StatusInternalServerError, "Database error")
+			return
+		}
+		resp.Source, resp.ISPs = ispSourceDatabase, isps
+	} else {
+		resp.ISPs = h.classifier.Config().ISPs()
+	}
+	if resp.ISPs == nil {
+		resp.ISPs = []models.ISPDefinition{}
+	}
+	writeJSON(w, status, resp)
+}
+
+// AdminListISPs handles GET /api/admin/isps
+func (h *Handler) AdminListISPs(w http.ResponseWriter, r *http.Request) {
+	h.writeISPs(w, http.StatusOK, nil)
+}
+
+// AdminCreateISP handles POST /api/admin/isps
+func (h *Handler) AdminCreateISP(w http.ResponseWriter, r *http.Request) {
+	if h.ispConfigReadOnly(w) {
+		return
+	}
+
+	var def models.ISPDefinition
+	if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	h.ispMu.Lock()
+	defer h.ispMu.Unlock()
+
+	isps, err := h.db.ListISPs()
+	if err != nil {
+		log.Printf("Failed to list ISPs: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	for _, existing := range isps {
+		if existing.Display == strings.TrimSpace(def.Display) {
+			writeError(w, http.StatusConflict, fmt.Sprintf("ISP %s already exists", existing.Display))
+			return
+		}
+	}
+
+	h.changeISPs(w, r, http.StatusCreated, append(isps, def), func(config *isp.Config) error {
+		saved, _ := config.ISP(def.ASNs[0])
+		if err := h.db.SaveISP("", saved); err != nil {
+			return err
+		}
+		h.audit(r, models.AuditISPCreate, ispTarget(saved.Display), nil, ispAuditFields(saved))
+		return nil
+	})
+}
+
+// AdminUpdateISP handles PUT /api/admin/isps/{asn}
+// The ISP is the one listing the ASN; the body replaces its definition.
+func (h *Handler) AdminUpdateISP(w http.ResponseWriter, r *http.Request) {
+	if h.ispConfigReadOnly(w) {
+		return
+	}
+	asn, ok := parseASN(w, r)
+	if !ok {
+		return
+	}
+
+	var def models.ISPDefinition
+	if err := json.NewDecoder(r.Body).Decode(&def); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	h.ispMu.Lock()
+	defer h.ispMu.Unlock()
+
+	isps, err := h.db.ListISPs()
+	if err != nil {
+		log.Printf("Failed to list ISPs: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	i := findISP(isps, asn)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "ISP not found")
+		return
+	}
+	existing := isps[i]
+	isps[i] = def
+
+	h.changeISPs(w, r, http.StatusOK, isps, func(config *isp.Config) error {
+		saved, _ := config.ISP(def.ASNs[0])
+		if err := h.db.SaveISP(existing.Display, saved); err != nil {
+			return err
+		}
+		h.audit(r, models.AuditISPUpdate, ispTarget(existing.Display), ispAuditFields(existing), ispAuditFields(saved))
+		return nil
+	})
+}
+
+// AdminDeleteISP handles DELETE /api/admin/isps/{asn}
+func (h *Handler) AdminDeleteISP(w http.ResponseWriter, r *http.Request) {
+	if h.ispConfigReadOnly(w) {
+		return
+	}
+	asn, ok := parseASN(w, r)
+	if !ok {
+		return
+	}
+
+	h.ispMu.Lock()
+	defer h.ispMu.Unlock()
+
+	isps, err := h.db.ListISPs()
+	if err != nil {
+		log.Printf("Failed to list ISPs: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	i := findISP(isps, asn)
+	if i < 0 {
+		writeError(w, http.StatusNotFound, "ISP not found")
+		return
+	}
+	existing := isps[i]
+
+	h.changeISPs(w, r, http.StatusOK, append(isps[:i], isps[i+1:]...), func(*isp.Config) error {
+		if _, err := h.db.DeleteISP(existing.Display); err != nil {
+			return err
+		}
+		h.audit(r, models.AuditISPDelete, ispTarget(existing.Display), ispAuditFields(existing), nil)
+		return nil
+	})
+}
+
+// AdminExportISPs handles GET /api/admin/isps/export
+// Returns the definitions in the --isp-config file format.
+func (h *Handler) AdminExportISPs(w http.ResponseWriter, r *http.Request) {
+	data, err := isp.MarshalConfig(h.classifier.Config().ISPs())
+	if err != nil {
+		log.Printf("Failed to export ISP config: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to export ISP config")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="isp_config.json"`)
+	w.Write(data)
+}
+
+// AdminImportISPs handles POST /api/admin/isps/import
+// Replaces all definitions with a file in the --isp-config format.
+func (h *Handler) AdminImportISPs(w http.ResponseWriter, r *http.Request) {
+	if h.ispConfigReadOnly(w) {
+		return
+	}
+
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	isps, err := isp.ParseConfig(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.ispMu.Lock()
+	defer h.ispMu.Unlock()
+
+	before := h.classifier.Config().ISPs()
+	h.changeISPs(w, r, http.StatusOK, isps, func(config *isp.Config) error {
+		if err := h.db.ReplaceISPs(config.ISPs()); err != nil {
+			return err
+		}
+		h.audit(r, models.AuditISPImport, "isps", ispSetAudit(before), ispSetAudit(config.ISPs()))
+		return nil
+	})
+}
+
+// AdminReclassifyEndpoints handles POST /api/admin/isps/reclassify
+// Re-runs ISP classification for every endpoint under the current definitions.
+func (h *Handler) AdminReclassifyEndpoints(w http.ResponseWriter, r *http.Request) {
+	h.ispMu.Lock()
+	defer h.ispMu.Unlock()
+
+	result, err := h.reclassifyEndpoints(r)
+	if err != nil {
+		log.Printf("Failed to reclassify endpoints: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// findISP returns the index of the definition listing asn, or -1
+func findISP(isps []models.ISPDefinition, asn int) int {
+	for i, def := range isps {
+		for _, a := range def.ASNs {
+			if a == asn {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parseASN reads the {asn} path value, accepting an optional "AS" prefix
+func parseASN(w http.ResponseWriter, r *http.Request) (int, bool) {
+	v := strings.TrimPrefix(strings.ToUpper(r.PathValue("asn")), "AS")
+	asn, err := strconv.Atoi(v)
+	if err != nil || asn <= 0 {
+		writeError(w, http.StatusBadRequest, "Invalid ASN")
+		return 0, false
+	}
+	return asn, true
+}
+
+func ispTarget(display string) string {
+	return "isp:" + display
+}
+
+// ispAuditFields is a definition without its update time, for audit diffs
+func ispAuditFields(def models.ISPDefinition) models.ISPDefinition {
+	def.UpdatedAt = nil
+	return def
+}
+
+// ispSetAudit keys definitions by display name for the audit diff
+func ispSetAudit(isps []models.ISPDefinition) map[string]models.ISPDefinition {
+	out := make(map[string]models.ISPDefinition, len(isps))
+	for _, def := range isps {
+		out[def.Display] = ispAuditFields(def)
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+	"net"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/jonsson/ccc/internal/isp"
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/storage"
+)
+
+// tableResolver resolves addresses from a fixed prefix table and fails for
+// addresses outside it
+type tableResolver map[string]isp.ASNResult
+
+func (tableResolver) Name() string { return "table" }
+
+func (t tableResolver) Resolve(ip net.IP) (isp.ASNResult, bool, error) {
+	for prefix, result := range t {
+		if _, n, _ := net.ParseCIDR(prefix); n.Contains(ip) {
+			return result, true, nil
+		}
+	}
+	return isp.ASNResult{}, false, errors.New("lookup failed")
+}
+
+func TestReclassifyEndpoints(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "ccc.db")
+	db, err := storage.New(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	config, err := isp.NewConfig([]models.ISPDefinition{
+		{Display: "Comcast", ASNs: []int{7922}, Allowed: true, ExcludePrefixes: []string{"198.51.100.128/25"}},
+		{Display: "Starry", ASNs: []int{27611}, Allowed: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	classifier := isp.NewClassifier()
+	classifier.SetResolvers(tableResolver{
+		"198.51.100.0/24": {ASN: 7922},
+		"2001:db8::/32":   {ASN: 7922},
+		"203.0.113.0/24":  {ASN: 27611},
+		"192.0.2.0/24":    {ASN: 64500, Org: "Example Net"},
+	})
+	classifier.SetConfig(config)
+
+	tests := []struct {
+		ep   models.Endpoint
+		want string
+	}{
+		{models.Endpoint{ID: "moved", IP: "198.51.100.1", ISP: "Starry"}, "Comcast"},
+		{models.Endpoint{ID: "excluded-primary", IP: "198.51.100.200", SecondaryIP: "2001:db8::5", ISP: "Old"}, "Comcast"},
+		{models.Endpoint{ID: "excluded", IP: "198.51.100.201", ISP: "Comcast"}, "Unknown"},
+		{models.Endpoint{ID: "set-by-hand", IP: "192.0.2.5", ISP: "Starry"}, "Starry"},
+		{models.Endpoint{ID: "fallback", IP: "192.0.2.6", ISP: "Old"}, "Example Net"},
+		{models.Endpoint{ID: "failed", IP: "100.64.0.1", ISP: "Old"}, "Old"},
+	}
+	for _, tt := range tests {
+		ep := tt.ep
+		ep.Status = models.StatusUp
+		if err := db.Create(&ep); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := NewHandler(db, dbPath, classifier)
+	result, err := h.reclassifyEndpoints(httptest.NewRequest("POST", "/api/admin/isps/reclassify", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (models.ReclassifyResult{Checked: 6, Changed: 4, Failed: 1}); *result != want {
+		t.Errorf("result = %+v, want %+v", *result, want)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ep.ID, func(t *testing.T) {
+			ep, err := db.FindByID(tt.ep.ID)
+			if err != nil || ep == nil {
+				t.Fatalf("endpoint not found: %v", err)
+			}
+			if ep.ISP != tt.want {
+				t.Errorf("ISP = %q, want %q", ep.ISP, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+
+	"github.com/jonsson/ccc/internal/metrics"
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// SetMetricsExport configures /metrics. A non-empty token requires
+// "Authorization: Bearer <token>"; separate keeps the route off the main
+// mux because it is served on its own listener.
+func (h *Handler) SetMetricsExport(token string, separate bool) {
+	h.metricsToken = token
+	h.metricsSeparate = separate
+}
+
+// Metrics handles GET /metrics in the Prometheus text exposition format
+func (h *Handler) Metrics(w http.ResponseWriter, r *http.Request) {
+	if h.metricsToken != "" {
+		want := "Bearer " + h.metricsToken
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="CCC metrics"`)
+			writeError(w, http.StatusUnauthorized, "Invalid metrics token")
+			return
+		}
+	}
+
+	h.collectScrapeMetrics()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	metrics.Default.Write(w)
+}
+
+// collectScrapeMetrics refreshes gauges that are read from the database or
+// other components at scrape time rather than updated as things happen
+func (h *Handler) collectScrapeMetrics() {
+	if stats, err := h.db.GetISPMetrics(); err != nil {
+		log.Printf("Failed to collect ISP metrics: %v", err)
+	} else {
+		metrics.EndpointsByStatus.Reset()
+		for _, s := range stats {
+			metrics.EndpointsByStatus.Set(float64(s.Up), s.Name, string(models.StatusUp))
+			metrics.EndpointsByStatus.Set(float64(s.Degraded), s.Name, string(models.StatusDegraded))
+			metrics.EndpointsByStatus.Set(float64(s.Down), s.Name, string(models.StatusDown))
+			metrics.EndpointsByStatus.Set(float64(s.Unknown), s.Name, string(models.StatusUnknown))
+		}
+	}
+
+	if open, err := h.db.GetOpenIncidents(); err != nil {
+		log.Printf("Failed to collect incident metrics: %v", err)
+	} else {
+		metrics.OngoingIncidents.Reset()
+		for ispName := range open {
+			metrics.OngoingIncidents.Set(1, ispName)
+		}
+	}
+
+	hits, misses := h.classifier.CacheStats()
+	metrics.ClassifierCacheSize.Set(float64(h.classifier.CacheSize()))
+	metrics.ClassifierCacheLookups.Set(float64(hits), "hit")
+	metrics.ClassifierCacheLookups.Set(float64(misses), "miss")
+	ratio := 0.0
+	if hits+misses > 0 {
+		ratio = float64(hits) / float64(hits+misses)
+	}
+	metrics.ClassifierCacheHitRatio.Set(ratio)
+}
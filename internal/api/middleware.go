@@ -4,9 +4,12 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/jonsson/ccc/internal/metrics"
 )
 
 // SecurityConfig holds security-related configuration
@@ -93,6 +96,13 @@ func LoggingMiddleware(next http.Handler) http.Handler {
 
 		next.ServeHTTP(wrapped, r)
 
+		// Label by route pattern rather than path to keep cardinality bounded
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequests.Inc(route, r.Method, strconv.Itoa(wrapped.statusCode))
+
 		log.Printf("%s %s %d %s [%s]",
 			r.Method,
 			r.URL.Path,
@@ -113,6 +123,12 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController (flushing
+// and deadlines for /api/stream)
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
 // CORSMiddleware adds CORS headers based on configuration
 func CORSMiddleware(cfg SecurityConfig) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -136,8 +152,8 @@ func CORSMiddleware(cfg SecurityConfig) func(http.Handler) http.Handler {
 
 				if allowedOrigin != "" {
 					w.Header().Set("Access-Control-Allow-Origin", allowedOrigin)
-					w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
-					w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+					w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+					w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+deviceTokenHeader)
 					w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
 
 					if allowedOrigin != "*" {
@@ -245,6 +261,7 @@ func RateLimitMiddleware(limiter *RateLimiter) func(http.Handler) http.Handler {
 			clientIP := GetClientIP(r)
 
 			if !limiter.Allow(clientIP) {
+				metrics.RateLimited.Inc("api")
 				w.Header().Set("Retry-After", "1")
 				http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
 				return
@@ -307,6 +324,24 @@ func GetClientIP(r *http.Request) string {
 	return remoteIP
 }
 
+// IsSecureRequest reports whether the client connected over HTTPS, either
+// directly or via a trusted proxy that sets X-Forwarded-Proto
+func IsSecureRequest(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+
+	remoteIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remoteIP = r.RemoteAddr
+	}
+	proxyCacheMu.RLock()
+	trusted := isTrustedProxy(remoteIP, proxyCache)
+	proxyCacheMu.RUnlock()
+
+	return trusted && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
 func isValidIP(ip string) bool {
 	return net.ParseIP(ip) != nil
 }
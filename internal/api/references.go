@@ -0,0 +1,179 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// ReferenceTargetRequest is the body of POST and PUT /api/admin/references
+type ReferenceTargetRequest struct {
+	Name          string   `json:"name"`    // Defaults to the address
+	Address       string   `json:"address"` // IPv4 or IPv6 address
+	ProbeStrategy []string `json:"probe_strategy,omitempty"`
+}
+
+// referenceFromRequest validates a request body. Returns an error message
+// suitable for the client, or "" if valid.
+func referenceFromRequest(r *http.Request) (models.ReferenceTarget, string) {
+	var req ReferenceTargetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return models.ReferenceTarget{}, "Invalid JSON body"
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(req.Address))
+	if ip == nil {
+		return models.ReferenceTarget{}, "address must be an IP address"
+	}
+	strategy, err := parseProbeStrategy(req.ProbeStrategy)
+	if err != nil {
+		return models.ReferenceTarget{}, err.Error()
+	}
+
+	t := models.ReferenceTarget{
+		Name:    strings.TrimSpace(req.Name),
+		Address: ip.String(),
+	}
+	if t.Name == "" {
+		t.Name = t.Address
+	}
+	if strategy != "" {
+		t.ProbeStrategy = strings.Split(strategy, ",")
+	}
+	return t, ""
+}
+
+// referenceAddressFree checks that no other reference target probes the
+// same address, writing the error response if one does
+func (h *Handler) referenceAddressFree(w http.ResponseWriter, t models.ReferenceTarget) bool {
+	targets, err := h.db.ListReferenceTargets()
+	if err != nil {
+		log.Printf("Failed to list reference targets: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return false
+	}
+	for _, other := range targets {
+		if other.Address == t.Address && other.ID != t.ID {
+			writeError(w, http.StatusConflict, "Reference target "+other.Name+" already probes this address")
+			return false
+		}
+	}
+	return true
+}
+
+// AdminListReferences handles GET /api/admin/references
+func (h *Handler) AdminListReferences(w http.ResponseWriter, r *http.Request) {
+	targets, err := h.db.ListReferenceTargets()
+	if err != nil {
+		log.Printf("Failed to list reference targets: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if targets == nil {
+		targets = []models.ReferenceTarget{}
+	}
+	writeJSON(w, http.StatusOK, targets)
+}
+
+// AdminCreateReference handles POST /api/admin/references
+func (h *Handler) AdminCreateReference(w http.ResponseWriter, r *http.Request) {
+	t, msg := referenceFromRequest(r)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+
+	if !h.referenceAddressFree(w, t) {
+		return
+	}
+	if err := h.db.CreateReferenceTarget(&t); err != nil {
+		log.Printf("Failed to create reference target: %v", err)
+		writeError(w, http.StatusInternalServerError, "Failed to create reference target")
+		return
+	}
+
+	h.audit(r, models.AuditReferenceCreate, referenceTarget(t.ID), nil, t)
+	writeJSON(w, http.StatusCreated, t)
+}
+
+// AdminUpdateReference handles PUT /api/admin/references/{id}
+func (h *Handler) AdminUpdateReference(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid reference target ID")
+		return
+	}
+	t, msg := referenceFromRequest(r)
+	if msg != "" {
+		writeError(w, http.StatusBadRequest, msg)
+		return
+	}
+	t.ID = id
+
+	before, err := h.db.GetReferenceTarget(id)
+	if err != nil {
+		log.Printf("Failed to get reference target %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if before == nil {
+		writeError(w, http.StatusNotFound, "Reference target not found")
+		return
+	}
+
+	if !h.referenceAddressFree(w, t) {
+		return
+	}
+	if _, err := h.db.UpdateReferenceTarget(t); err != nil {
+		log.Printf("Failed to update reference target %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Failed to update reference target")
+		return
+	}
+
+	after, err := h.db.GetReferenceTarget(id)
+	if err != nil || after == nil {
+		log.Printf("Failed to reload reference target %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	h.audit(r, models.AuditReferenceUpdate, referenceTarget(id), before, after)
+	writeJSON(w, http.StatusOK, after)
+}
+
+// AdminDeleteReference handles DELETE /api/admin/references/{id}
+func (h *Handler) AdminDeleteReference(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid reference target ID")
+		return
+	}
+
+	before, err := h.db.GetReferenceTarget(id)
+	if err != nil {
+		log.Printf("Failed to get reference target %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if before == nil {
+		writeError(w, http.StatusNotFound, "Reference target not found")
+		return
+	}
+
+	if _, err := h.db.DeleteReferenceTarget(id); err != nil {
+		log.Printf("Failed to delete reference target %d: %v", id, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	h.audit(r, models.AuditReferenceDelete, referenceTarget(id), before, nil)
+	writeJSON(w, http.StatusOK, map[string]string{"message": "Reference target deleted"})
+}
+
+func referenceTarget(id int64) string {
+	return "reference:" + strconv.FormatInt(id, 10)
+}
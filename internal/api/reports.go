@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// maxReportDays limits the date range of one availability report
+const maxReportDays = 2 * 366
+
+// reportCSVHeader lists the columns of CSV availability reports
+var reportCSVHeader = []string{
+	"scope", "period", "isp", "endpoint_id", "monitored_minutes", "outage_minutes",
+	"availability_pct", "endpoint_availability_pct", "failures", "mtbf_hours", "mttr_minutes",
+}
+
+// AdminReport handles GET /api/admin/reports
+// Query parameters: from and to (YYYY-MM-DD, inclusive; default this month
+// so far), breakdown (none, daily or monthly; default none), isp (default
+// all), format (json or csv; default json)
+func (h *Handler) AdminReport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := today
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, p.name+" must be a date like 2006-01-02")
+			return
+		}
+		*p.dst = t
+	}
+	if to.Before(from) {
+		writeError(w, http.StatusBadRequest, "to must not be before from")
+		return
+	}
+	if to.After(from.AddDate(0, 0, maxReportDays)) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("date range must be at most %d days", maxReportDays))
+		return
+	}
+
+	breakdown := q.Get("breakdown")
+	switch breakdown {
+	case "":
+		breakdown = models.BreakdownNone
+	case models.BreakdownNone, models.BreakdownDaily, models.BreakdownMonthly:
+	default:
+		writeError(w, http.StatusBadRequest, "breakdown must be none, daily or monthly")
+		return
+	}
+
+	format := q.Get("format")
+	if format != "" && format != "json" && format != "csv" {
+		writeError(w, http.StatusBadRequest, "format must be json or csv")
+		return
+	}
+
+	report, err := h.db.GetAvailabilityReport(from, to, breakdown, q.Get("isp"))
+	if err != nil {
+		log.Printf("Failed to build availability report: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if format != "csv" {
+		writeJSON(w, http.StatusOK, report)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="availability_%s_%s.csv"`, report.From, report.To))
+	cw := csv.NewWriter(w)
+	cw.Write(reportCSVHeader)
+	for _, row := range report.ISPs {
+		cw.Write(reportCSVRecord("isp", row))
+	}
+	for _, row := range report.Endpoints {
+		cw.Write(reportCSVRecord("endpoint", row))
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		log.Printf("Failed to write availability report: %v", err)
+	}
+}
+
+// reportCSVRecord formats one report row in the order of reportCSVHeader.
+// Figures that do not apply are left empty.
+func reportCSVRecord(scope string, row models.AvailabilityRow) []string {
+	optional := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	}
+	return []string{
+		scope,
+		row.Period,
+		row.ISP,
+		row.EndpointID,
+		strconv.FormatFloat(row.MonitoredMinutes, 'f', -1, 64),
+		strconv.FormatFloat(row.OutageMinutes, 'f', -1, 64),
+		optional(row.AvailabilityPct),
+		optional(row.EndpointAvailabilityPct),
+		strconv.Itoa(row.Failures),
+		optional(row.MTBFHours),
+		optional(row.MTTRMinutes),
+	}
+}
@@ -3,6 +3,8 @@ package api
 import (
 	"io/fs"
 	"net/http"
+
+	"github.com/jonsson/ccc/internal/models"
 )
 
 // SetupRoutes configures all API routes
@@ -11,69 +13,86 @@ func (h *Handler) SetupRoutes(mux *http.ServeMux, staticFS fs.FS) {
 	mux.HandleFunc("GET /api/health", h.Health)
 	mux.HandleFunc("GET /api/status", h.Status)
 	mux.HandleFunc("POST /api/register", h.Register)
+	mux.HandleFunc("DELETE /api/register", h.Unregister)
+	mux.HandleFunc("POST /api/register/pause", h.PauseMonitoring)
 	mux.HandleFunc("GET /api/dashboard", h.Dashboard)
 	mux.HandleFunc("GET /api/events", h.Events)
+	mux.HandleFunc("GET /api/stream", h.Stream)
+	mux.HandleFunc("GET /api/incidents", h.Incidents)
+	mux.HandleFunc("GET /api/incidents/{id}", h.Incident)
 	mux.HandleFunc("GET /api/site-config", h.SiteConfig)
 
-	// Admin API routes (protected by basic auth)
-	mux.HandleFunc("GET /api/admin/endpoints", h.requireAdminAuth(h.AdminListEndpoints))
-	mux.HandleFunc("POST /api/admin/endpoints", h.requireAdminAuth(h.AdminAddEndpoint))
-	mux.HandleFunc("DELETE /api/admin/endpoints/{id}", h.requireAdminAuth(h.AdminDeleteEndpoint))
-	mux.HandleFunc("GET /api/admin/metrics", h.requireAdminAuth(h.AdminMetrics))
-	mux.HandleFunc("GET /api/admin/settings", h.requireAdminAuth(h.AdminGetSettings))
-	mux.HandleFunc("PUT /api/admin/settings", h.requireAdminAuth(h.AdminUpdateSettings))
-	mux.HandleFunc("GET /api/admin/site-config", h.requireAdminAuth(h.AdminGetSiteConfig))
-	mux.HandleFunc("PUT /api/admin/site-config", h.requireAdminAuth(h.AdminUpdateSiteConfig))
-
-	// Static files (if provided)
-	if staticFS != nil {
-		mux.Handle("/", spaHandler(staticFS))
-	}
-}
+	// Probe agents (per-agent key)
+	mux.HandleFunc("GET /api/agent/endpoints", h.requireAgentAuth(h.AgentEndpoints))
+	mux.HandleFunc("POST /api/agent/results", h.requireAgentAuth(h.AgentReportResults))
 
-// requireAdminAuth wraps a handler with basic auth and rate limiting
-func (h *Handler) requireAdminAuth(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		clientIP := GetClientIP(r)
+	// Admin sessions
+	mux.HandleFunc("POST /api/admin/login", h.AdminLogin)
+	mux.HandleFunc("POST /api/admin/logout", h.AdminLogout)
+	mux.HandleFunc("GET /api/admin/me", h.requireAdminAuth(models.RoleViewer, h.AdminMe))
 
-		// Apply auth-specific rate limiting (prevent brute force)
-		if h.authRateLimiter != nil && !h.authRateLimiter.Allow(clientIP) {
-			w.Header().Set("Retry-After", "10")
-			writeError(w, http.StatusTooManyRequests, "Too many authentication attempts")
-			return
-		}
-
-		// Check if password is configured
-		hasPassword, err := h.db.HasAdminPassword()
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Database error")
-			return
-		}
-		if !hasPassword {
-			writeError(w, http.StatusForbidden, "Admin access is disabled (no password configured)")
-			return
-		}
+	// Admin API routes (session cookie or basic auth, by minimum role)
+	viewer := func(next http.HandlerFunc) http.HandlerFunc { return h.requireAdminAuth(models.RoleViewer, next) }
+	operator := func(next http.HandlerFunc) http.HandlerFunc { return h.requireAdminAuth(models.RoleOperator, next) }
+	owner := func(next http.HandlerFunc) http.HandlerFunc { return h.requireAdminAuth(models.RoleOwner, next) }
 
-		// Check basic auth
-		_, password, ok := r.BasicAuth()
-		if !ok {
-			w.Header().Set("WWW-Authenticate", `Basic realm="CCC Admin"`)
-			writeError(w, http.StatusUnauthorized, "Authentication required")
-			return
-		}
+	mux.HandleFunc("GET /api/admin/endpoints", viewer(h.AdminListEndpoints))
+	mux.HandleFunc("POST /api/admin/endpoints", operator(h.AdminAddEndpoint))
+	mux.HandleFunc("DELETE /api/admin/endpoints/{id}", operator(h.AdminDeleteEndpoint))
+	mux.HandleFunc("PUT /api/admin/endpoints/{id}/probes", operator(h.AdminSetProbeStrategy))
+	mux.HandleFunc("GET /api/admin/metrics", viewer(h.AdminMetrics))
+	mux.HandleFunc("GET /api/admin/history", viewer(h.AdminHistory))
+	mux.HandleFunc("GET /api/admin/probes", viewer(h.AdminProbeResults))
+	mux.HandleFunc("GET /api/admin/reports", viewer(h.AdminReport))
+	mux.HandleFunc("GET /api/admin/incidents", viewer(h.AdminListIncidents))
+	mux.HandleFunc("GET /api/admin/incidents/{id}", viewer(h.AdminGetIncident))
+	mux.HandleFunc("PUT /api/admin/incidents/{id}", operator(h.AdminAnnotateIncident))
+	mux.HandleFunc("GET /api/admin/notifiers", viewer(h.AdminListNotifiers))
+	mux.HandleFunc("POST /api/admin/notifiers", operator(h.AdminCreateNotifier))
+	mux.HandleFunc("PUT /api/admin/notifiers/{id}", operator(h.AdminUpdateNotifier))
+	mux.HandleFunc("DELETE /api/admin/notifiers/{id}", operator(h.AdminDeleteNotifier))
+	mux.HandleFunc("POST /api/admin/notifiers/{id}/test", operator(h.AdminTestNotifier))
+	mux.HandleFunc("GET /api/admin/notifiers/{id}/deliveries", viewer(h.AdminNotifierDeliveries))
+	mux.HandleFunc("GET /api/admin/settings", viewer(h.AdminGetSettings))
+	mux.HandleFunc("PUT /api/admin/settings", owner(h.AdminUpdateSettings))
+	mux.HandleFunc("GET /api/admin/isps", viewer(h.AdminListISPs))
+	mux.HandleFunc("POST /api/admin/isps", owner(h.AdminCreateISP))
+	mux.HandleFunc("PUT /api/admin/isps/{asn}", owner(h.AdminUpdateISP))
+	mux.HandleFunc("DELETE /api/admin/isps/{asn}", owner(h.AdminDeleteISP))
+	mux.HandleFunc("GET /api/admin/isps/export", viewer(h.AdminExportISPs))
+	mux.HandleFunc("POST /api/admin/isps/import", owner(h.AdminImportISPs))
+	mux.HandleFunc("POST /api/admin/isps/reclassify", owner(h.AdminReclassifyEndpoints))
+	mux.HandleFunc("GET /api/admin/references", viewer(h.AdminListReferences))
+	mux.HandleFunc("POST /api/admin/references", owner(h.AdminCreateReference))
+	mux.HandleFunc("PUT /api/admin/references/{id}", owner(h.AdminUpdateReference))
+	mux.HandleFunc("DELETE /api/admin/references/{id}", owner(h.AdminDeleteReference))
+	mux.HandleFunc("GET /api/admin/agents", viewer(h.AdminListAgents))
+	mux.HandleFunc("POST /api/admin/agents", owner(h.AdminCreateAgent))
+	mux.HandleFunc("PUT /api/admin/agents/{id}", owner(h.AdminUpdateAgent))
+	mux.HandleFunc("POST /api/admin/agents/{id}/key", owner(h.AdminRotateAgentKey))
+	mux.HandleFunc("DELETE /api/admin/agents/{id}", owner(h.AdminDeleteAgent))
+	mux.HandleFunc("GET /api/admin/disagreements", viewer(h.AdminDisagreements))
+	mux.HandleFunc("GET /api/admin/admission", viewer(h.AdminGetAdmission))
+	mux.HandleFunc("PUT /api/admin/admission", owner(h.AdminUpdateAdmission))
+	mux.HandleFunc("GET /api/admin/invites", viewer(h.AdminListInvites))
+	mux.HandleFunc("POST /api/admin/invites", operator(h.AdminCreateInvite))
+	mux.HandleFunc("DELETE /api/admin/invites/{id}", operator(h.AdminDeleteInvite))
+	mux.HandleFunc("GET /api/admin/site-config", viewer(h.AdminGetSiteConfig))
+	mux.HandleFunc("PUT /api/admin/site-config", owner(h.AdminUpdateSiteConfig))
+	mux.HandleFunc("GET /api/admin/users", owner(h.AdminListUsers))
+	mux.HandleFunc("GET /api/admin/audit", viewer(h.AdminAuditLog))
+	mux.HandleFunc("GET /api/admin/backup", owner(h.AdminDownloadBackup))
+	mux.HandleFunc("GET /api/admin/backups", owner(h.AdminListBackups))
+	mux.HandleFunc("POST /api/admin/backups", owner(h.AdminCreateBackup))
 
-		valid, err := h.db.CheckAdminPassword(password)
-		if err != nil {
-			writeError(w, http.StatusInternalServerError, "Database error")
-			return
-		}
-		if !valid {
-			w.Header().Set("WWW-Authenticate", `Basic realm="CCC Admin"`)
-			writeError(w, http.StatusUnauthorized, "Invalid password")
-			return
-		}
+	// Prometheus metrics, unless served on a separate listener
+	if !h.metricsSeparate {
+		mux.HandleFunc("GET /metrics", h.Metrics)
+	}
 
-		next(w, r)
+	// Static files (if provided)
+	if staticFS != nil {
+		mux.Handle("/", spaHandler(staticFS))
 	}
 }
 
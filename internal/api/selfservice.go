@@ -0,0 +1,215 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/storage"
+	"github.com/jonsson/ccc/internal/stream"
+)
+
+const (
+	defaultPauseDays = 7
+	maxPauseDays     = 30
+
+	// deviceTokenHeader carries the device token on GET /api/status
+	deviceTokenHeader = "X-Device-Token"
+)
+
+// Unregister handles DELETE /api/register
+// Removes the caller's endpoint, or the endpoint a deletion token belongs to
+// when one is given, together with its addresses and tokens. Addresses in
+// its audit entries are redacted.
+func (h *Handler) Unregister(w http.ResponseWriter, r *http.Request) {
+	var req models.UnregisterRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+	}
+
+	var endpoint *models.Endpoint
+	var err error
+	if req.DeletionToken != "" {
+		endpoint, err = h.db.FindByToken(storage.TokenDeletion, req.DeletionToken)
+	} else {
+		endpoint, err = h.db.FindByIP(GetClientIP(r))
+	}
+	if err != nil {
+		log.Printf("Database error looking up endpoint to unregister: %v", err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if endpoint == nil {
+		if req.DeletionToken != "" {
+			writeError(w, http.StatusNotFound, "Unknown deletion token")
+		} else {
+			writeError(w, http.StatusNotFound, "No endpoint is registered from this address")
+		}
+		return
+	}
+
+	// Older audit entries of the endpoint may still hold its addresses
+	if _, err := h.db.RedactAuditAddresses(endpoint.ID); err != nil {
+		log.Printf("Failed to redact audit entries of %s: %v", endpoint.ID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to unregister")
+		return
+	}
+
+	if _, err := h.db.DeleteByID(endpoint.ID); err != nil {
+		log.Printf("Failed to unregister endpoint %s: %v", endpoint.ID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to unregister")
+		return
+	}
+
+	// Audited without addresses: the resident asked for them to be forgotten
+	changes, _ := models.AuditDiff(map[string]interface{}{
+		"isp":        endpoint.ISP,
+		"created_at": endpoint.CreatedAt,
+	}, nil)
+	if err := h.db.RecordAudit(models.AuditEntry{
+		Actor:   models.AuditActorResident,
+		Action:  models.AuditEndpointUnregister,
+		Target:  endpoint.ID,
+		Changes: changes,
+	}); err != nil {
+		log.Printf("Failed to audit unregistration of %s: %v", endpoint.ID, err)
+	}
+
+	log.Printf("Resident unregistered endpoint %s", endpoint.ID)
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"endpoint_id": endpoint.ID,
+		"message":     "Unregistered. Your addresses have been deleted.",
+	})
+}
+
+// PauseMonitoring handles POST /api/register/pause
+// Pauses monitoring of the caller's endpoint for a number of days, e.g.
+// while away with the router switched off; days=0 resumes it early.
+func (h *Handler) PauseMonitoring(w http.ResponseWriter, r *http.Request) {
+	var req models.PauseRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+			writeError(w, http.StatusBadRequest, "Invalid JSON body")
+			return
+		}
+	}
+	days := defaultPauseDays
+	if req.Days != nil {
+		days = *req.Days
+	}
+	if days < 0 || days > maxPauseDays {
+		writeError(w, http.StatusBadRequest, "days must be between 0 and 30")
+		return
+	}
+
+	clientIP := GetClientIP(r)
+	endpoint, err := h.db.FindByIP(clientIP)
+	if err != nil {
+		log.Printf("Database error looking up %s: %v", clientIP, err)
+		writeError(w, http.StatusInternalServerError, "Database error")
+		return
+	}
+	if endpoint == nil {
+		writeError(w, http.StatusNotFound, "No endpoint is registered from this address")
+		return
+	}
+
+	var until *time.Time
+	message := "Monitoring resumed"
+	if days > 0 {
+		t := time.Now().Add(time.Duration(days) * 24 * time.Hour).Truncate(time.Second)
+		until = &t
+		message = "Monitoring paused"
+	}
+	if err := h.db.SetPausedUntil(endpoint.ID, until); err != nil {
+		log.Printf("Failed to pause endpoint %s: %v", endpoint.ID, err)
+		writeError(w, http.StatusInternalServerError, "Failed to pause monitoring")
+		return
+	}
+	if err := h.db.UpdateLastSeen(endpoint.ID); err != nil {
+		log.Printf("Failed to update last_seen for %s: %v", endpoint.ID, err)
+	}
+
+	log.Printf("%s for endpoint %s", message, endpoint.ID)
+
+	writeJSON(w, http.StatusOK, models.PauseResponse{
+		EndpointID:  endpoint.ID,
+		PausedUntil: until,
+		Message:     message,
+	})
+}
+
+// reassociateEndpoint moves the endpoint that owns deviceToken to clientIP
+// after the resident's ISP rotated their address, re-classifying its ISP.
+// It replaces the address of the caller's family; a new address family is
+// left to linkSecondaryAddress. Returns the updated endpoint, or nil if the
+// token does not apply.
+func (h *Handler) reassociateEndpoint(clientIP, ispName, deviceToken string) *models.Endpoint {
+	endpoint, err := h.db.FindByToken(storage.TokenDevice, deviceToken)
+	if err != nil {
+		log.Printf("Database error looking up device token: %v", err)
+		return nil
+	}
+	if endpoint == nil || !h.classifier.IsAllowed(ispName, clientIP) {
+		return nil
+	}
+	policy, err := h.db.GetAdmissionPolicy()
+	if err != nil {
+		log.Printf("Failed to get admission policy: %v", err)
+		return nil
+	}
+	if !h.networkAdmitted(policy, clientIP) {
+		return nil
+	}
+
+	var secondary bool
+	switch family := addressFamily(clientIP); {
+	case family == addressFamily(endpoint.IP):
+		secondary = false
+	case endpoint.SecondaryIP != "" && family == addressFamily(endpoint.SecondaryIP):
+		secondary = true
+	default:
+		return nil
+	}
+
+	if err := h.db.ReassignAddress(endpoint.ID, secondary, clientIP, ispName); err != nil {
+		log.Printf("Failed to reassign address of %s: %v", endpoint.ID, err)
+		return nil
+	}
+
+	if h.metricsProvider != nil {
+		h.metricsProvider.ForgetEndpoint(endpoint.ID)
+	}
+
+	log.Printf("Endpoint %s changed IPv%d address (ISP: %s)", endpoint.ID, addressFamily(clientIP), ispName)
+	h.recordEvent("ip_changed", ispName, endpoint.ID, ispName+" endpoint changed address")
+
+	updated, err := h.db.FindByID(endpoint.ID)
+	if err != nil {
+		log.Printf("Failed to reload endpoint %s: %v", endpoint.ID, err)
+		return nil
+	}
+	return updated
+}
+
+// recordEvent stores an event and pushes it to live subscribers
+func (h *Handler) recordEvent(eventType, isp, endpointID, message string) {
+	event, err := h.db.RecordEvent(eventType, isp, endpointID, message)
+	if err != nil {
+		log.Printf("Failed to record %s event: %v", eventType, err)
+		return
+	}
+
+	if h.broker != nil {
+		if err := h.broker.Publish(stream.TypeEvent, event); err != nil {
+			log.Printf("Failed to publish event: %v", err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/storage"
+)
+
+func TestUnregisterRedactsAuditAddresses(t *testing.T) {
+	srv, db, _ := agentTestServer(t)
+
+	// Entries as recorded before endpoint audits left addresses out
+	for _, e := range []models.AuditEntry{
+		{Actor: "alice", Action: models.AuditEndpointCreate, Target: "CCC-Endpoint-c2",
+			Changes: json.RawMessage(`{"ipv4":{"after":"198.51.100.2"},"ipv6":{"after":"2001:db8::2"},"isp":{"after":"Comcast"}}`)},
+		{Actor: models.AuditActorSystem, Action: models.AuditEndpointExpire, Target: "CCC-Endpoint-c2",
+			Changes: json.RawMessage(`{"addresses":{"before":["198.51.100.2"]}}`)},
+		{Actor: "alice", Action: models.AuditEndpointCreate, Target: "CCC-Endpoint-c1",
+			Changes: json.RawMessage(`{"ipv4":{"after":"198.51.100.1"}}`)},
+	} {
+		if err := db.RecordAudit(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	token, err := db.CreateEndpointToken("CCC-Endpoint-c2", storage.TokenDeletion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := json.Marshal(models.UnregisterRequest{DeletionToken: token})
+	req, _ := http.NewRequest(http.MethodDelete, srv.URL+"/api/register", bytes.NewReader(body))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+
+	want := map[string]string{
+		"CCC-Endpoint-c2/" + models.AuditEndpointCreate:     `{"isp":{"after":"Comcast"}}`,
+		"CCC-Endpoint-c2/" + models.AuditEndpointExpire:     ``,
+		"CCC-Endpoint-c2/" + models.AuditEndpointUnregister: `{"created_at":{"before":`,
+		"CCC-Endpoint-c1/" + models.AuditEndpointCreate:     `{"ipv4":{"after":"198.51.100.1"}}`,
+	}
+	entries, _, err := db.ListAudit(storage.AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("got %d audit entries, want %d", len(entries), len(want))
+	}
+	for _, e := range entries {
+		key := e.Target + "/" + e.Action
+		w, ok := want[key]
+		if !ok {
+			t.Errorf("unexpected audit entry %s", key)
+			continue
+		}
+		if !bytes.HasPrefix(e.Changes, []byte(w)) || (w == "" && len(e.Changes) != 0) {
+			t.Errorf("%s changes = %s, want %s", key, e.Changes, w)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jonsson/ccc/internal/stream"
+)
+
+const (
+	streamKeepalive      = 25 * time.Second // Comment lines keep proxies from closing idle streams
+	streamRetryMs        = 5000             // Client reconnect delay
+	maxStreamSubscribers = 500
+)
+
+// Stream handles GET /api/stream
+// Server-Sent Events: "dashboard" snapshots after each ping cycle and "event"
+// messages as they are recorded. Clients reconnecting with Last-Event-ID get
+// the messages they missed; otherwise the current dashboard is sent first.
+func (h *Handler) Stream(w http.ResponseWriter, r *http.Request) {
+	if h.broker == nil {
+		writeError(w, http.StatusServiceUnavailable, "Live updates are not enabled")
+		return
+	}
+	if h.broker.Subscribers() >= maxStreamSubscribers {
+		w.Header().Set("Retry-After", "30")
+		writeError(w, http.StatusServiceUnavailable, "Too many live update clients")
+		return
+	}
+
+	// The server's WriteTimeout would otherwise end the stream after a few seconds
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		writeError(w, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	var lastID uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		lastID, _ = strconv.ParseUint(v, 10, 64)
+	}
+	sub := h.broker.Subscribe(lastID)
+	defer h.broker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMs)
+
+	if sub.Resumed {
+		for _, msg := range sub.Replay {
+			writeStreamMessage(w, msg)
+		}
+	} else {
+		// Fresh client (or one too far behind to resume): start from current state
+		snapshot, err := h.DashboardSnapshot()
+		if err != nil {
+			log.Printf("Failed to build dashboard snapshot: %v", err)
+		} else if data, err := json.Marshal(snapshot); err == nil {
+			writeStreamMessage(w, stream.Message{ID: sub.Head, Type: stream.TypeDashboard, Data: data})
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	keepalive := time.NewTicker(streamKeepalive)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and resumes
+				return
+			}
+			writeStreamMessage(w, msg)
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// writeStreamMessage writes one SSE message. A zero ID is omitted so the
+// client keeps its previous Last-Event-ID.
+func writeStreamMessage(w http.ResponseWriter, msg stream.Message) {
+	if msg.ID > 0 {
+		fmt.Fprintf(w, "id: %d\n", msg.ID)
+	}
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, msg.Data)
+}
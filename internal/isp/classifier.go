@@ -1,22 +1,16 @@
 package isp
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
 	"net"
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// ISPConfig represents the configuration for a single ISP
-type ISPConfig struct {
-	Display string `json:"display"`
-	Allowed bool   `json:"allowed"`
-}
-
 // Classifier handles ISP classification via ASN lookups
 type Classifier struct {
 	cache        map[string]cacheEntry
@@ -24,7 +18,13 @@ type Classifier struct {
 	cacheMu      sync.RWMutex
 	cacheTTL     time.Duration
 	maxCacheSize int
-	asnConfig    map[int]ISPConfig // ASN -> config mapping
+	generation   uint64 // Bumped with each config change; guarded by cacheMu
+	configMu     sync.RWMutex
+	ispConfig    *Config       // Replaced whole by SetConfig
+	resolvers    []ASNResolver // Tried in order on cache miss
+	useCymruInfo bool          // Fetch AS org names from Cymru DNS when a resolver lacks them
+	cacheHits    atomic.Uint64
+	cacheMisses  atomic.Uint64
 }
 
 type cacheEntry struct {
@@ -40,7 +40,22 @@ func NewClassifier() *Classifier {
 		cacheOrder:   make([]string, 0),
 		cacheTTL:     24 * time.Hour,
 		maxCacheSize: 10000,
-		asnConfig:    make(map[int]ISPConfig),
+		ispConfig:    &Config{},
+		resolvers:    []ASNResolver{NewCymruResolver()},
+		useCymruInfo: true,
+	}
+}
+
+// SetResolvers replaces the ASN resolver chain. Resolvers are tried in
+// order until one finds the address. Org-name lookups for unmapped ASNs
+// only go to Cymru DNS if a CymruResolver is part of the chain.
+func (c *Classifier) SetResolvers(resolvers ...ASNResolver) {
+	c.resolvers = resolvers
+	c.useCymruInfo = false
+	for _, r := range resolvers {
+		if _, ok := r.(*CymruResolver); ok {
+			c.useCymruInfo = true
+		}
 	}
 }
 
@@ -51,64 +66,69 @@ func (c *Classifier) LoadConfig(path string) error {
 		return fmt.Errorf("failed to read ISP config: %w", err)
 	}
 
-	// Parse JSON with string keys (ASN numbers as strings)
-	var rawConfig map[string]ISPConfig
-	if err := json.Unmarshal(data, &rawConfig); err != nil {
-		return fmt.Errorf("failed to parse ISP config: %w", err)
+	isps, err := ParseConfig(data)
+	if err != nil {
+		return err
 	}
-
-	// Convert string keys to int
-	c.asnConfig = make(map[int]ISPConfig)
-	for asnStr, config := range rawConfig {
-		var asn int
-		if _, err := fmt.Sscanf(asnStr, "%d", &asn); err != nil {
-			log.Printf("Warning: invalid ASN in config: %s", asnStr)
-			continue
-		}
-		c.asnConfig[asn] = config
+	config, err := NewConfig(isps)
+	if err != nil {
+		return fmt.Errorf("invalid ISP config: %w", err)
 	}
+	c.SetConfig(config)
 
-	log.Printf("Loaded ISP config: %d ASN mappings", len(c.asnConfig))
+	log.Printf("Loaded ISP config: %d ISPs", config.Len())
 	return nil
 }
 
+// SetConfig atomically replaces the ISP definitions. Cached
+// classifications made under the old definitions are dropped.
+func (c *Classifier) SetConfig(config *Config) {
+	if config == nil {
+		config = &Config{}
+	}
+
+	c.configMu.Lock()
+	c.ispConfig = config
+	c.configMu.Unlock()
+
+	c.cacheMu.Lock()
+	c.generation++
+	c.cache = make(map[string]cacheEntry)
+	c.cacheOrder = make([]string, 0)
+	c.cacheMu.Unlock()
+}
+
+// Config returns the current ISP definitions
+func (c *Classifier) Config() *Config {
+	c.configMu.RLock()
+	defer c.configMu.RUnlock()
+	return c.ispConfig
+}
+
 // ClassifyISP returns the ISP display name for an IP address
 func (c *Classifier) ClassifyISP(ip string) (string, error) {
 	// Check cache first
 	c.cacheMu.RLock()
 	if entry, ok := c.cache[ip]; ok && time.Now().Before(entry.expiresAt) {
 		c.cacheMu.RUnlock()
+		c.cacheHits.Add(1)
 		return entry.isp, nil
 	}
+	generation := c.generation
 	c.cacheMu.RUnlock()
+	c.cacheMisses.Add(1)
 
-	// Perform ASN lookup
-	asn, _, err := c.LookupASN(ip)
-	if err != nil {
-		return "Unknown", err
-	}
-
-	if asn == 0 {
-		return "Unknown", nil
+	ispName, err := c.classify(ip)
+	if err != nil || ispName == "Unknown" {
+		return ispName, err
 	}
 
-	// Look up ASN in config
-	var ispName string
-	if config, ok := c.asnConfig[asn]; ok {
-		ispName = config.Display
-	} else {
-		// Fallback: get org name from ASN info
-		_, org, err := c.LookupASNInfo(asn)
-		if err != nil || org == "" {
-			ispName = "Unknown"
-		} else {
-			// Use a cleaned-up version of the org name
-			ispName = cleanOrgName(org)
-		}
-	}
-
-	// Cache the result with size limit
+	// Cache the result with size limit, unless the config changed meanwhile
 	c.cacheMu.Lock()
+	if c.generation != generation {
+		c.cacheMu.Unlock()
+		return ispName, nil
+	}
 
 	// If cache is at max size, remove oldest entries
 	for len(c.cache) >= c.maxCacheSize && len(c.cacheOrder) > 0 {
@@ -127,43 +147,85 @@ func (c *Classifier) ClassifyISP(ip string) (string, error) {
 	return ispName, nil
 }
 
-// IsAllowed checks if an ISP (by display name) is allowed to register
-func (c *Classifier) IsAllowed(ispDisplay string) bool {
-	for _, config := range c.asnConfig {
-		if config.Display == ispDisplay {
-			return config.Allowed
+// classify looks up an address without the cache. Include prefixes take
+// precedence over the ASN lookup.
+func (c *Classifier) classify(ip string) (string, error) {
+	config := c.Config()
+	if parsed := net.ParseIP(ip); parsed != nil {
+		if rules := config.claim(parsed); rules != nil {
+			return rules.def.Display, nil
 		}
 	}
-	return false
+
+	// Perform ASN lookup
+	result, err := c.resolve(ip)
+	if err != nil {
+		return "Unknown", err
+	}
+
+	if result.ASN == 0 {
+		return "Unknown", nil
+	}
+
+	// Look up ASN in config
+	if rules, ok := config.byASN[result.ASN]; ok {
+		return rules.def.Display, nil
+	}
+
+	// Fallback: get org name from the resolver or ASN info
+	org := result.Org
+	if org == "" && c.useCymruInfo {
+		_, org, _ = c.LookupASNInfo(result.ASN)
+	}
+	if org == "" {
+		return "Unknown", nil
+	}
+	// Use a cleaned-up version of the org name
+	return cleanOrgName(org), nil
 }
 
-// IsASNAllowed checks if a specific ASN is allowed to register
+// IsAllowed checks if a resident of an ISP (by display name) may register
+// from an address, applying the ISP's include and exclude prefixes
+func (c *Classifier) IsAllowed(ispDisplay, ip string) bool {
+	rules, ok := c.Config().byName[ispDisplay]
+	if !ok || !rules.def.Allowed {
+		return false
+	}
+	return rules.admits(net.ParseIP(ip))
+}
+
+// Admits reports whether an address passes the include and exclude
+// prefixes of a configured ISP, whether or not the ISP is allowed
+func (c *Classifier) Admits(ispDisplay, ip string) bool {
+	rules, ok := c.Config().byName[ispDisplay]
+	return ok && rules.admits(net.ParseIP(ip))
+}
+
+// IsASNAllowed checks if the ISP of a specific ASN is allowed to register,
+// ignoring prefix rules
 func (c *Classifier) IsASNAllowed(asn int) bool {
-	if config, ok := c.asnConfig[asn]; ok {
-		return config.Allowed
+	if rules, ok := c.Config().byASN[asn]; ok {
+		return rules.def.Allowed
 	}
 	return false
 }
 
 // GetAllowedISPs returns a list of all allowed ISP display names
 func (c *Classifier) GetAllowedISPs() []string {
-	seen := make(map[string]bool)
 	var allowed []string
-	for _, config := range c.asnConfig {
-		if config.Allowed && !seen[config.Display] {
-			seen[config.Display] = true
-			allowed = append(allowed, config.Display)
+	for _, def := range c.Config().isps {
+		if def.Allowed {
+			allowed = append(allowed, def.Display)
 		}
 	}
 	return allowed
 }
 
-// GetASNForDisplay returns the first ASN for a given display name
+// GetASNForDisplay returns the primary ASN of an ISP, or 0 if the display
+// name is not configured
 func (c *Classifier) GetASNForDisplay(display string) int {
-	for asn, config := range c.asnConfig {
-		if config.Display == display {
-			return asn
-		}
+	if rules, ok := c.Config().byName[display]; ok {
+		return rules.def.PrimaryASN
 	}
 	return 0
 }
@@ -186,51 +248,35 @@ func cleanOrgName(org string) string {
 	return strings.TrimSpace(org)
 }
 
-// LookupASN queries Team Cymru DNS for ASN information
-// Query format: reverse IP octets + ".origin.asn.cymru.com"
-// Response format: "ASN | CIDR | CC | Registry | Date"
+// LookupASN returns the origin ASN and covering prefix for an IP address,
+// trying each configured resolver in order
 func (c *Classifier) LookupASN(ip string) (asn int, cidr string, err error) {
+	result, err := c.resolve(ip)
+	return result.ASN, result.Prefix, err
+}
+
+// resolve runs the resolver chain. A resolver error only fails the lookup
+// if no later resolver finds the address.
+func (c *Classifier) resolve(ip string) (ASNResult, error) {
 	// Parse and validate IP
 	parsedIP := net.ParseIP(ip)
 	if parsedIP == nil {
-		return 0, "", fmt.Errorf("invalid IP address: %s", ip)
+		return ASNResult{}, fmt.Errorf("invalid IP address: %s", ip)
 	}
 
-	// Only support IPv4 for now
-	ipv4 := parsedIP.To4()
-	if ipv4 == nil {
-		return 0, "", fmt.Errorf("IPv6 not supported: %s", ip)
-	}
-
-	// Reverse the IP octets
-	reversed := fmt.Sprintf("%d.%d.%d.%d", ipv4[3], ipv4[2], ipv4[1], ipv4[0])
-	query := reversed + ".origin.asn.cymru.com"
-
-	// Perform DNS TXT lookup
-	records, err := net.LookupTXT(query)
-	if err != nil {
-		return 0, "", fmt.Errorf("ASN lookup failed: %w", err)
-	}
-
-	if len(records) == 0 {
-		return 0, "", nil
-	}
-
-	// Parse response: "7922 | 1.2.3.0/24 | US | arin | 1997-12-01"
-	parts := strings.Split(records[0], "|")
-	if len(parts) < 2 {
-		return 0, "", fmt.Errorf("unexpected ASN response format: %s", records[0])
-	}
-
-	// Parse ASN (might have multiple ASNs, take the first)
-	asnStr := strings.TrimSpace(parts[0])
-	asnParts := strings.Fields(asnStr)
-	if len(asnParts) > 0 {
-		fmt.Sscanf(asnParts[0], "%d", &asn)
+	var lastErr error
+	for _, r := range c.resolvers {
+		result, found, err := r.Resolve(parsedIP)
+		if err != nil {
+			log.Printf("ASN resolver %s failed for %s: %v", r.Name(), ip, err)
+			lastErr = err
+			continue
+		}
+		if found {
+			return result, nil
+		}
 	}
-
-	cidr = strings.TrimSpace(parts[1])
-	return asn, cidr, nil
+	return ASNResult{}, lastErr
 }
 
 // LookupASNInfo queries Team Cymru DNS for ASN details
@@ -279,3 +325,8 @@ func (c *Classifier) CacheSize() int {
 	defer c.cacheMu.RUnlock()
 	return len(c.cache)
 }
+
+// CacheStats returns the cumulative cache hits and misses of ClassifyISP
+func (c *Classifier) CacheStats() (hits, misses uint64) {
+	return c.cacheHits.Load(), c.cacheMisses.Load()
+}
@@ -0,0 +1,249 @@
+package isp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// Config is a validated set of ISP definitions, indexed for lookups. It is
+// immutable once built, so the classifier can swap it atomically.
+type Config struct {
+	isps   []models.ISPDefinition
+	byASN  map[int]*ispRules
+	byName map[string]*ispRules
+	claims []prefixRule // Include prefixes of all ISPs, longest first
+}
+
+// ispRules is one ISP with its prefixes parsed
+type ispRules struct {
+	def     models.ISPDefinition
+	include []*net.IPNet
+	exclude []*net.IPNet
+}
+
+type prefixRule struct {
+	prefix *net.IPNet
+	isp    *ispRules
+}
+
+// NewConfig validates ISP definitions and indexes them. Definitions are
+// normalised: ASNs sorted, prefixes in canonical form and the primary ASN
+// defaulted to the lowest ASN.
+func NewConfig(isps []models.ISPDefinition) (*Config, error) {
+	cfg := &Config{
+		byASN:  make(map[int]*ispRules),
+		byName: make(map[string]*ispRules),
+	}
+	claimed := make(map[string]string)
+
+	for _, def := range isps {
+		def.Display = strings.TrimSpace(def.Display)
+		if def.Display == "" {
+			return nil, fmt.Errorf("ISP display name is required")
+		}
+		if _, ok := cfg.byName[def.Display]; ok {
+			return nil, fmt.Errorf("ISP %s is defined more than once", def.Display)
+		}
+		if len(def.ASNs) == 0 {
+			return nil, fmt.Errorf("ISP %s needs at least one ASN", def.Display)
+		}
+
+		rules := &ispRules{}
+		def.ASNs = append([]int(nil), def.ASNs...)
+		sort.Ints(def.ASNs)
+		for i, asn := range def.ASNs {
+			if asn <= 0 {
+				return nil, fmt.Errorf("ISP %s: invalid ASN %d", def.Display, asn)
+			}
+			if i > 0 && def.ASNs[i-1] == asn {
+				return nil, fmt.Errorf("ISP %s lists AS%d twice", def.Display, asn)
+			}
+			if other, ok := cfg.byASN[asn]; ok {
+				return nil, fmt.Errorf("AS%d belongs to both %s and %s", asn, other.def.Display, def.Display)
+			}
+			cfg.byASN[asn] = rules
+		}
+		if def.PrimaryASN == 0 {
+			def.PrimaryASN = def.ASNs[0]
+		} else if cfg.byASN[def.PrimaryASN] != rules {
+			return nil, fmt.Errorf("ISP %s: primary ASN %d is not one of its ASNs", def.Display, def.PrimaryASN)
+		}
+
+		var err error
+		if def.IncludePrefixes, rules.include, err = parsePrefixes(def.Display, def.IncludePrefixes); err != nil {
+			return nil, err
+		}
+		if def.ExcludePrefixes, rules.exclude, err = parsePrefixes(def.Display, def.ExcludePrefixes); err != nil {
+			return nil, err
+		}
+		for i, prefix := range def.IncludePrefixes {
+			if other, ok := claimed[prefix]; ok {
+				return nil, fmt.Errorf("prefix %s is included by both %s and %s", prefix, other, def.Display)
+			}
+			claimed[prefix] = def.Display
+			cfg.claims = append(cfg.claims, prefixRule{prefix: rules.include[i], isp: rules})
+		}
+
+		def.UpdatedAt = nil
+		rules.def = def
+		cfg.byName[def.Display] = rules
+		cfg.isps = append(cfg.isps, def)
+	}
+
+	sort.SliceStable(cfg.claims, func(i, j int) bool {
+		a, _ := cfg.claims[i].prefix.Mask.Size()
+		b, _ := cfg.claims[j].prefix.Mask.Size()
+		return a > b
+	})
+	sort.Slice(cfg.isps, func(i, j int) bool { return cfg.isps[i].Display < cfg.isps[j].Display })
+	return cfg, nil
+}
+
+// parsePrefixes parses CIDR prefixes, returning them in canonical form
+func parsePrefixes(display string, prefixes []string) ([]string, []*net.IPNet, error) {
+	if len(prefixes) == 0 {
+		return nil, nil, nil
+	}
+	canonical := make([]string, 0, len(prefixes))
+	nets := make([]*net.IPNet, 0, len(prefixes))
+	for _, p := range prefixes {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(p))
+		if err != nil {
+			return nil, nil, fmt.Errorf("ISP %s: invalid prefix %q", display, p)
+		}
+		canonical = append(canonical, ipNet.String())
+		nets = append(nets, ipNet)
+	}
+	return canonical, nets, nil
+}
+
+// ISPs returns the normalised definitions, ordered by display name
+func (cfg *Config) ISPs() []models.ISPDefinition {
+	if cfg == nil {
+		return nil
+	}
+	return append([]models.ISPDefinition(nil), cfg.isps...)
+}
+
+// Len returns the number of ISPs
+func (cfg *Config) Len() int {
+	if cfg == nil {
+		return 0
+	}
+	return len(cfg.isps)
+}
+
+// ISP returns the definition that lists asn, if any
+func (cfg *Config) ISP(asn int) (models.ISPDefinition, bool) {
+	if cfg == nil || cfg.byASN[asn] == nil {
+		return models.ISPDefinition{}, false
+	}
+	return cfg.byASN[asn].def, true
+}
+
+// claim returns the ISP whose include prefixes most specifically cover ip
+func (cfg *Config) claim(ip net.IP) *ispRules {
+	for _, rule := range cfg.claims {
+		if rule.prefix.Contains(ip) {
+			return rule.isp
+		}
+	}
+	return nil
+}
+
+// admits reports whether an address of this ISP passes its prefix rules
+func (r *ispRules) admits(ip net.IP) bool {
+	if ip == nil {
+		return len(r.include) == 0 && len(r.exclude) == 0
+	}
+	for _, n := range r.exclude {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(r.include) == 0 {
+		return true
+	}
+	for _, n := range r.include {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// configFile is the ISP config file format
+type configFile struct {
+	ISPs []models.ISPDefinition `json:"isps"`
+}
+
+// legacyEntry is one ASN of the original config format, an object keyed
+// by ASN: {"7922": {"display": "Comcast / Xfinity", "allowed": true}}
+type legacyEntry struct {
+	Display string `json:"display"`
+	Allowed bool   `json:"allowed"`
+}
+
+// ParseConfig parses an ISP config file. Besides the {"isps": [...]} format
+// it accepts the original format keyed by ASN, where ASNs sharing a display
+// name become one ISP that is allowed if any of them was.
+func ParseConfig(data []byte) ([]models.ISPDefinition, error) {
+	var top map[string]json.RawMessage
+	if err := json.Unmarshal(data, &top); err != nil {
+		return nil, fmt.Errorf("failed to parse ISP config: %w", err)
+	}
+
+	if _, ok := top["isps"]; ok {
+		var file configFile
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&file); err != nil {
+			return nil, fmt.Errorf("failed to parse ISP config: %w", err)
+		}
+		return file.ISPs, nil
+	}
+
+	var legacy map[string]legacyEntry
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, fmt.Errorf("failed to parse ISP config: %w", err)
+	}
+	byName := make(map[string]*models.ISPDefinition)
+	var isps []*models.ISPDefinition
+	for asnStr, entry := range legacy {
+		asn, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(asnStr), "AS"))
+		if err != nil || asn <= 0 {
+			return nil, fmt.Errorf("invalid ASN in ISP config: %q", asnStr)
+		}
+		def, ok := byName[entry.Display]
+		if !ok {
+			def = &models.ISPDefinition{Display: entry.Display}
+			byName[entry.Display] = def
+			isps = append(isps, def)
+		}
+		def.ASNs = append(def.ASNs, asn)
+		def.Allowed = def.Allowed || entry.Allowed
+	}
+
+	defs := make([]models.ISPDefinition, 0, len(isps))
+	for _, def := range isps {
+		defs = append(defs, *def)
+	}
+	return defs, nil
+}
+
+// MarshalConfig encodes definitions in the {"isps": [...]} file format
+func MarshalConfig(isps []models.ISPDefinition) ([]byte, error) {
+	file := configFile{ISPs: make([]models.ISPDefinition, 0, len(isps))}
+	for _, def := range isps {
+		def.UpdatedAt = nil
+		file.ISPs = append(file.ISPs, def)
+	}
+	return json.MarshalIndent(file, "", "  ")
+}
@@ -0,0 +1,294 @@
+package isp
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PrefixDB resolves ASNs offline from a local IP-to-ASN prefix dump using
+// a longest-prefix-match binary trie. Supported line formats (tab or space
+// separated, '#' comments allowed, optionally gzip-compressed):
+//
+//	1.0.0.0	24	13335                        routeviews pfx2as
+//	1.0.0.0/24	13335                          prefix/length and ASN
+//	1.0.0.0	1.0.0.255	13335	US	CLOUDFLARENET  iptoasn range TSV
+type PrefixDB struct {
+	path    string
+	v4      *trieNode
+	v6      *trieNode
+	orgs    map[int]string // ASN -> organisation, when the dump includes it
+	entries int
+}
+
+type trieNode struct {
+	child [2]*trieNode
+	asn   int // 0 = no prefix ends here
+	bits  int // prefix length of the entry stored at this node
+}
+
+// LoadPrefixDB reads a prefix dump into memory
+func LoadPrefixDB(path string) (*PrefixDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open prefix database: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress prefix database: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	db := &PrefixDB{
+		path: path,
+		v4:   &trieNode{},
+		v6:   &trieNode{},
+		orgs: make(map[int]string),
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := db.parseLine(line); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read prefix database: %w", err)
+	}
+
+	return db, nil
+}
+
+// parseLine adds the prefixes described by one line of the dump
+func (db *PrefixDB) parseLine(line string) error {
+	var fields []string
+	if strings.Contains(line, "\t") {
+		fields = strings.Split(line, "\t")
+	} else {
+		fields = strings.Fields(line)
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+
+	switch {
+	case len(fields) >= 2 && strings.Contains(fields[0], "/"):
+		// prefix/length ASN
+		prefix, err := netip.ParsePrefix(fields[0])
+		if err != nil {
+			return fmt.Errorf("invalid prefix %q", fields[0])
+		}
+		asn, err := parseOriginASN(fields[1])
+		if err != nil {
+			return err
+		}
+		db.insert(prefix, asn)
+
+	case len(fields) >= 3 && isIPField(fields[1]):
+		// start end ASN [country] [description]
+		start, err1 := netip.ParseAddr(fields[0])
+		end, err2 := netip.ParseAddr(fields[1])
+		if err1 != nil || err2 != nil || start.Is4() != end.Is4() {
+			return fmt.Errorf("invalid range %s - %s", fields[0], fields[1])
+		}
+		asn, err := parseOriginASN(fields[2])
+		if err != nil {
+			return err
+		}
+		if asn == 0 {
+			return nil // "Not routed"
+		}
+		for _, prefix := range rangeToPrefixes(start, end) {
+			db.insert(prefix, asn)
+		}
+		if len(fields) >= 5 && fields[4] != "" && fields[4] != "Not routed" {
+			db.orgs[asn] = fields[4]
+		}
+
+	case len(fields) >= 3:
+		// pfx2as: address length ASN
+		bits, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("invalid prefix length %q", fields[1])
+		}
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return fmt.Errorf("invalid address %q", fields[0])
+		}
+		prefix, err := addr.Prefix(bits)
+		if err != nil {
+			return fmt.Errorf("invalid prefix %s/%d", fields[0], bits)
+		}
+		asn, err := parseOriginASN(fields[2])
+		if err != nil {
+			return err
+		}
+		db.insert(prefix, asn)
+
+	default:
+		return fmt.Errorf("unrecognised line format")
+	}
+	return nil
+}
+
+// isIPField reports whether s is a bare IP address
+func isIPField(s string) bool {
+	_, err := netip.ParseAddr(s)
+	return err == nil
+}
+
+// parseOriginASN parses an origin ASN field. Multi-origin ("13335_1234")
+// and AS-set ("1234,5678") entries resolve to the first ASN listed.
+func parseOriginASN(s string) (int, error) {
+	s = strings.TrimPrefix(strings.ToUpper(s), "AS")
+	if idx := strings.IndexAny(s, "_,"); idx >= 0 {
+		s = s[:idx]
+	}
+	asn, err := strconv.Atoi(s)
+	if err != nil || asn < 0 {
+		return 0, fmt.Errorf("invalid ASN %q", s)
+	}
+	return asn, nil
+}
+
+// insert stores a prefix in the trie for its address family
+func (db *PrefixDB) insert(prefix netip.Prefix, asn int) {
+	if asn == 0 {
+		return
+	}
+	// Lookups unmap IPv4-mapped addresses, so store such prefixes as IPv4
+	if addr := prefix.Addr(); addr.Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(addr.Unmap(), prefix.Bits()-96)
+	}
+	prefix = prefix.Masked()
+	root := db.v6
+	if prefix.Addr().Is4() {
+		root = db.v4
+	}
+
+	addr := prefix.Addr().AsSlice()
+	node := root
+	for i := 0; i < prefix.Bits(); i++ {
+		bit := (addr[i/8] >> (7 - uint(i%8))) & 1
+		if node.child[bit] == nil {
+			node.child[bit] = &trieNode{}
+		}
+		node = node.child[bit]
+	}
+	if node.asn == 0 {
+		db.entries++
+	}
+	node.asn = asn
+	node.bits = prefix.Bits()
+}
+
+// lookup returns the longest matching prefix for addr
+func (db *PrefixDB) lookup(addr netip.Addr) (asn int, prefix netip.Prefix, found bool) {
+	addr = addr.Unmap()
+	root := db.v6
+	if addr.Is4() {
+		root = db.v4
+	}
+
+	bytes := addr.AsSlice()
+	node := root
+	for i := 0; node != nil; i++ {
+		if node.asn != 0 {
+			asn = node.asn
+			prefix, _ = addr.Prefix(node.bits)
+			found = true
+		}
+		if i >= len(bytes)*8 {
+			break
+		}
+		bit := (bytes[i/8] >> (7 - uint(i%8))) & 1
+		node = node.child[bit]
+	}
+	return asn, prefix, found
+}
+
+// Name returns the resolver name for logging
+func (db *PrefixDB) Name() string {
+	return "prefix-db:" + db.path
+}
+
+// Len returns the number of prefixes loaded
+func (db *PrefixDB) Len() int {
+	return db.entries
+}
+
+// Resolve looks up the origin ASN for ip using longest-prefix match
+func (db *PrefixDB) Resolve(ip net.IP) (ASNResult, bool, error) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return ASNResult{}, false, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	asn, prefix, found := db.lookup(addr)
+	if !found {
+		return ASNResult{}, false, nil
+	}
+	return ASNResult{
+		ASN:    asn,
+		Prefix: prefix.String(),
+		Org:    db.orgs[asn],
+	}, true, nil
+}
+
+// rangeToPrefixes splits an inclusive address range into the minimal set
+// of CIDR prefixes covering it
+func rangeToPrefixes(start, end netip.Addr) []netip.Prefix {
+	var prefixes []netip.Prefix
+	totalBits := start.BitLen()
+
+	for start.Compare(end) <= 0 {
+		// Largest block aligned at start that does not run past end
+		bits := totalBits
+		for bits > 0 {
+			candidate, _ := start.Prefix(bits - 1)
+			if candidate.Addr() != start || lastAddr(candidate).Compare(end) > 0 {
+				break
+			}
+			bits--
+		}
+
+		prefix := netip.PrefixFrom(start, bits)
+		prefixes = append(prefixes, prefix)
+
+		next := lastAddr(prefix).Next()
+		if !next.IsValid() {
+			break // Wrapped past the end of the address space
+		}
+		start = next
+	}
+	return prefixes
+}
+
+// lastAddr returns the highest address within a prefix
+func lastAddr(p netip.Prefix) netip.Addr {
+	bytes := p.Masked().Addr().AsSlice()
+	for i := p.Bits(); i < len(bytes)*8; i++ {
+		bytes[i/8] |= 1 << (7 - uint(i%8))
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
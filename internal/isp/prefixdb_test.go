@@ -0,0 +1,196 @@
+package isp
+
+import (
+	"compress/gzip"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writePrefixDB writes lines to a dump file, gzip-compressed if the name
+// ends in .gz, and loads it
+func writePrefixDB(t *testing.T, name string, lines ...string) *PrefixDB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := strings.Join(lines, "\n") + "\n"
+	if strings.HasSuffix(name, ".gz") {
+		gz := gzip.NewWriter(f)
+		gz.Write([]byte(data))
+		gz.Close()
+	} else {
+		f.WriteString(data)
+	}
+	f.Close()
+
+	db, err := LoadPrefixDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestPrefixDBFormats(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		lines  []string
+		ip     string
+		asn    int
+		prefix string
+		org    string
+	}{
+		{"pfx2as", "pfx2as.txt", []string{"1.0.0.0\t24\t13335"}, "1.0.0.77", 13335, "1.0.0.0/24", ""},
+		{"pfx2as spaces", "pfx2as.txt", []string{"1.0.0.0 24 13335"}, "1.0.0.77", 13335, "1.0.0.0/24", ""},
+		{"pfx2as multi-origin", "pfx2as.txt", []string{"1.0.4.0\t22\t38803_56203"}, "1.0.5.1", 38803, "1.0.4.0/22", ""},
+		{"pfx2as AS set", "pfx2as.txt", []string{"1.0.4.0\t22\t38803,56203"}, "1.0.5.1", 38803, "1.0.4.0/22", ""},
+		{"prefix/length", "prefixes.txt", []string{"203.0.113.0/24\tAS64500"}, "203.0.113.9", 64500, "203.0.113.0/24", ""},
+		{"prefix/length unmasked", "prefixes.txt", []string{"203.0.113.77/24 64500"}, "203.0.113.9", 64500, "203.0.113.0/24", ""},
+		{"iptoasn range", "ip2asn.tsv", []string{"1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET"}, "1.0.0.1", 13335, "1.0.0.0/24", "CLOUDFLARENET"},
+		{"iptoasn unaligned range", "ip2asn.tsv", []string{"198.51.100.5\t198.51.100.20\t64501\tUS\tEXAMPLE"}, "198.51.100.17", 64501, "198.51.100.16/30", "EXAMPLE"},
+		{"v6 prefix", "pfx2as.txt", []string{"2001:db8::\t32\t64502"}, "2001:db8:1::1", 64502, "2001:db8::/32", ""},
+		{"v6 range", "ip2asn.tsv", []string{"2001:db8::\t2001:db8:ffff:ffff:ffff:ffff:ffff:ffff\t64502\tZZ\tV6NET"}, "2001:db8::42", 64502, "2001:db8::/32", "V6NET"},
+		{"IPv4-mapped prefix", "prefixes.txt", []string{"::ffff:192.0.2.0/120\t64503"}, "192.0.2.1", 64503, "192.0.2.0/24", ""},
+		{"IPv4-mapped range", "ip2asn.tsv", []string{"::ffff:192.0.2.0\t::ffff:192.0.2.255\t64503\tUS\tMAPPED"}, "192.0.2.1", 64503, "192.0.2.0/24", "MAPPED"},
+		{"comments and blank lines", "pfx2as.txt", []string{"# routeviews", "", "1.0.0.0\t24\t13335"}, "1.0.0.1", 13335, "1.0.0.0/24", ""},
+		{"gzip", "pfx2as.txt.gz", []string{"1.0.0.0\t24\t13335"}, "1.0.0.1", 13335, "1.0.0.0/24", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := writePrefixDB(t, tt.file, tt.lines...)
+			result, found, err := db.Resolve(net.ParseIP(tt.ip))
+			if err != nil || !found {
+				t.Fatalf("Resolve(%s) found=%v err=%v", tt.ip, found, err)
+			}
+			want := ASNResult{ASN: tt.asn, Prefix: tt.prefix, Org: tt.org}
+			if result != want {
+				t.Errorf("Resolve(%s) = %+v, want %+v", tt.ip, result, want)
+			}
+		})
+	}
+}
+
+func TestPrefixDBLongestMatch(t *testing.T) {
+	db := writePrefixDB(t, "pfx2as.txt",
+		"10.0.0.0\t8\t100",
+		"10.1.0.0\t16\t200",
+		"10.1.2.0\t24\t300",
+		"10.1.2.128\t25\t400",
+		"2001:db8::\t32\t500",
+		"2001:db8:1::\t48\t600",
+		"0.0.0.0\t1\t0", // Unrouted entries are ignored
+	)
+	if db.Len() != 6 {
+		t.Errorf("Len() = %d, want 6", db.Len())
+	}
+
+	tests := []struct {
+		ip     string
+		asn    int
+		prefix string
+	}{
+		{"10.200.0.1", 100, "10.0.0.0/8"},
+		{"10.1.3.1", 200, "10.1.0.0/16"},
+		{"10.1.2.127", 300, "10.1.2.0/24"},
+		{"10.1.2.128", 400, "10.1.2.128/25"},
+		{"10.1.2.255", 400, "10.1.2.128/25"},
+		{"::ffff:10.1.2.200", 400, "10.1.2.128/25"}, // IPv4-mapped IPv6 is looked up as IPv4
+		{"2001:db8:2::1", 500, "2001:db8::/32"},
+		{"2001:db8:1:ffff::1", 600, "2001:db8:1::/48"},
+		{"11.0.0.1", 0, ""},
+		{"2001:db9::1", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			asn, prefix, found := db.lookup(netip.MustParseAddr(tt.ip))
+			if found != (tt.asn != 0) {
+				t.Fatalf("found = %v, want %v", found, tt.asn != 0)
+			}
+			if !found {
+				return
+			}
+			if asn != tt.asn || prefix.String() != tt.prefix {
+				t.Errorf("lookup = AS%d %s, want AS%d %s", asn, prefix, tt.asn, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestRangeToPrefixes(t *testing.T) {
+	tests := []struct {
+		start, end string
+		want       []string
+	}{
+		{"192.0.2.0", "192.0.2.255", []string{"192.0.2.0/24"}},
+		{"192.0.2.7", "192.0.2.7", []string{"192.0.2.7/32"}},
+		{"192.0.2.1", "192.0.2.6", []string{"192.0.2.1/32", "192.0.2.2/31", "192.0.2.4/31", "192.0.2.6/32"}},
+		{"192.0.2.255", "192.0.3.0", []string{"192.0.2.255/32", "192.0.3.0/32"}},
+		{"10.0.0.0", "10.255.255.255", []string{"10.0.0.0/8"}},
+		{"255.255.255.254", "255.255.255.255", []string{"255.255.255.254/31"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"2001:db8::", "2001:db8::ffff", []string{"2001:db8::/112"}},
+		{"2001:db8::1", "2001:db8::2", []string{"2001:db8::1/128", "2001:db8::2/128"}},
+		{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00", "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff", []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:ff00/120"}},
+		{"192.0.2.9", "192.0.2.8", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.start+"-"+tt.end, func(t *testing.T) {
+			var got []string
+			for _, p := range rangeToPrefixes(netip.MustParseAddr(tt.start), netip.MustParseAddr(tt.end)) {
+				got = append(got, p.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("rangeToPrefixes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrefixDBRangeEdges(t *testing.T) {
+	db := writePrefixDB(t, "ip2asn.tsv",
+		"198.51.100.5\t198.51.100.20\t64501\tUS\tEXAMPLE",
+		"198.51.100.21\t198.51.100.30\t0\tNone\tNot routed",
+	)
+	tests := []struct {
+		ip    string
+		found bool
+	}{
+		{"198.51.100.4", false},
+		{"198.51.100.5", true},
+		{"198.51.100.20", true},
+		{"198.51.100.21", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			_, _, found := db.lookup(netip.MustParseAddr(tt.ip))
+			if found != tt.found {
+				t.Errorf("found = %v, want %v", found, tt.found)
+			}
+		})
+	}
+}
+
+func TestLoadPrefixDBRejectsBadLines(t *testing.T) {
+	for _, line := range []string{
+		"1.0.0.0/33\t13335",
+		"1.0.0.0\t24\tnot-an-asn",
+		"1.0.0.0\t2001:db8::\t13335",
+		"1.0.0.0\t99\t13335",
+		"garbage",
+	} {
+		t.Run(line, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bad.txt")
+			os.WriteFile(path, []byte(line+"\n"), 0o600)
+			if _, err := LoadPrefixDB(path); err == nil {
+				t.Errorf("line %q loaded without error", line)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package isp
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// ASNResult is the origin AS for an IP address
+type ASNResult struct {
+	ASN    int
+	Prefix string // Covering prefix in CIDR notation, if known
+	Org    string // AS organisation name, if the backend provides one
+}
+
+// ASNResolver maps IP addresses to their origin AS.
+// Resolve returns found=false when the backend has no entry for the address;
+// err is reserved for backend failures (e.g. DNS errors).
+type ASNResolver interface {
+	Name() string
+	Resolve(ip net.IP) (result ASNResult, found bool, err error)
+}
+
+// CymruResolver looks up origin ASNs via Team Cymru's DNS service
+type CymruResolver struct{}
+
+// NewCymruResolver creates a resolver backed by origin.asn.cymru.com
+func NewCymruResolver() *CymruResolver {
+	return &CymruResolver{}
+}
+
+// Name returns the resolver name for logging
+func (r *CymruResolver) Name() string {
+	return "cymru"
+}
+
+// Resolve queries Team Cymru DNS for ASN information
+// Query format: reverse IPv4 octets + ".origin.asn.cymru.com", or reverse
+// IPv6 nibbles + ".origin6.asn.cymru.com"
+// Response format: "ASN | CIDR | CC | Registry | Date"
+func (r *CymruResolver) Resolve(ip net.IP) (ASNResult, bool, error) {
+	var query string
+	if ipv4 := ip.To4(); ipv4 != nil {
+		// Reverse the IP octets
+		reversed := fmt.Sprintf("%d.%d.%d.%d", ipv4[3], ipv4[2], ipv4[1], ipv4[0])
+		query = reversed + ".origin.asn.cymru.com"
+	} else if ipv6 := ip.To16(); ipv6 != nil {
+		query = reverseNibbles(ipv6) + ".origin6.asn.cymru.com"
+	} else {
+		return ASNResult{}, false, fmt.Errorf("invalid IP address: %s", ip)
+	}
+
+	// Perform DNS TXT lookup
+	records, err := net.LookupTXT(query)
+	if err != nil {
+		return ASNResult{}, false, fmt.Errorf("ASN lookup failed: %w", err)
+	}
+
+	if len(records) == 0 {
+		return ASNResult{}, false, nil
+	}
+
+	// Parse response: "7922 | 1.2.3.0/24 | US | arin | 1997-12-01"
+	parts := strings.Split(records[0], "|")
+	if len(parts) < 2 {
+		return ASNResult{}, false, fmt.Errorf("unexpected ASN response format: %s", records[0])
+	}
+
+	// Parse ASN (might have multiple ASNs, take the first)
+	var result ASNResult
+	asnStr := strings.TrimSpace(parts[0])
+	asnParts := strings.Fields(asnStr)
+	if len(asnParts) > 0 {
+		fmt.Sscanf(asnParts[0], "%d", &result.ASN)
+	}
+
+	result.Prefix = strings.TrimSpace(parts[1])
+	return result, result.ASN != 0, nil
+}
+
+// reverseNibbles formats an IPv6 address as dot-separated hex nibbles in
+// reverse order, e.g. 2001:db8::1 -> "1.0.0.0...8.b.d.0.1.0.0.2"
+func reverseNibbles(ip net.IP) string {
+	const hexDigits = "0123456789abcdef"
+	var b strings.Builder
+	for i := len(ip) - 1; i >= 0; i-- {
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteByte(hexDigits[ip[i]&0x0f])
+		b.WriteByte('.')
+		b.WriteByte(hexDigits[ip[i]>>4])
+	}
+	return b.String()
+}
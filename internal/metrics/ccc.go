@@ -0,0 +1,48 @@
+package metrics
+
+// Default is the registry served on /metrics
+var Default = NewRegistry()
+
+// rttBuckets covers LAN-like to badly congested residential round trips (seconds)
+var rttBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.02, 0.05, 0.1, 0.2, 0.5, 1, 2.5}
+
+// cycleBuckets covers ping cycles from a handful to thousands of endpoints (seconds)
+var cycleBuckets = []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120}
+
+// Scheduler and prober
+var (
+	EndpointsByStatus = Default.NewGaugeVec("ccc_endpoints",
+		"Monitored endpoints by ISP and status.", "isp", "status")
+	ProbesTotal = Default.NewCounterVec("ccc_probes_total",
+		"Probes sent, by ISP and result (success or failure).", "isp", "result")
+	ProbeRTT = Default.NewHistogramVec("ccc_probe_rtt_seconds",
+		"Average round-trip time of successful probes.", rttBuckets, "isp")
+	PingCycles = Default.NewCounterVec("ccc_ping_cycles_total",
+		"Completed ping cycles.")
+	PingCycleDuration = Default.NewHistogramVec("ccc_ping_cycle_duration_seconds",
+		"Time taken to aggregate statuses and analyze outages in a cycle.", cycleBuckets)
+	ProbeRechecks = Default.NewCounterVec("ccc_probe_rechecks_total",
+		"Suspect endpoints scheduled for a re-probe before the next interval.")
+	OngoingIncidents = Default.NewGaugeVec("ccc_isp_outage",
+		"1 if the ISP currently has an ongoing outage incident.", "isp")
+	MonitorOffline = Default.NewGaugeVec("ccc_monitor_offline",
+		"1 if no reference target answered in the last check.")
+)
+
+// ISP classifier
+var (
+	ClassifierCacheLookups = Default.NewCounterVec("ccc_classifier_cache_lookups_total",
+		"ISP classifier cache lookups by result (hit or miss).", "result")
+	ClassifierCacheSize = Default.NewGaugeVec("ccc_classifier_cache_entries",
+		"Entries in the ISP classifier cache.")
+	ClassifierCacheHitRatio = Default.NewGaugeVec("ccc_classifier_cache_hit_ratio",
+		"Share of ISP classifications answered from the cache since startup.")
+)
+
+// HTTP layer
+var (
+	HTTPRequests = Default.NewCounterVec("ccc_http_requests_total",
+		"HTTP requests by route pattern, method and status code.", "route", "method", "status")
+	RateLimited = Default.NewCounterVec("ccc_rate_limited_total",
+		"Requests rejected by a rate limiter.", "limiter")
+)
@@ -1,20 +1,72 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EndpointStatus is the monitored state of an endpoint. It is the single
+// vocabulary shared by the scheduler, storage aggregates and event recording.
+type EndpointStatus string
+
+const (
+	StatusUnknown  EndpointStatus = "unknown"  // Not yet probed
+	StatusUp       EndpointStatus = "up"       // Responding normally
+	StatusDegraded EndpointStatus = "degraded" // Responding, but with heavy packet loss
+	StatusDown     EndpointStatus = "down"     // Not responding
+)
+
+// IsReachable reports whether the endpoint is responding (up or degraded)
+func (s EndpointStatus) IsReachable() bool {
+	return s == StatusUp || s == StatusDegraded
+}
+
+// ParseEndpointStatus converts a stored status string, mapping the legacy
+// "unreachable" value to down and anything unrecognised to unknown
+func ParseEndpointStatus(s string) EndpointStatus {
+	switch EndpointStatus(s) {
+	case StatusUp, StatusDegraded, StatusDown:
+		return EndpointStatus(s)
+	case "unreachable":
+		return StatusDown
+	default:
+		return StatusUnknown
+	}
+}
 
 // Endpoint represents a monitored IP endpoint
 type Endpoint struct {
-	ID           string    `json:"id"`            // e.g., "CCC-Endpoint-0123"
-	IPv4         string    `json:"-"`             // Stored for monitoring, not exposed in API
-	IPHash       string    `json:"ip_hash"`       // SHA256 hash for lookup
-	ISP          string    `json:"isp"`           // "starry", "comcast", "unknown"
-	Status       string    `json:"status"`        // "up", "down", "unknown"
-	CreatedAt    time.Time `json:"created_at"`
-	LastSeen     time.Time `json:"last_seen"`
-	LastOK       time.Time `json:"last_ok"`
-	MonitoredHop string    `json:"-"`             // IP of hop being monitored (if different from IPv4)
-	HopNumber    int       `json:"hop_number"`    // TTL/hop number of monitored hop (0 = direct)
-	UseHop       bool      `json:"use_hop"`       // True if monitoring a hop instead of direct IP
+	ID            string         `json:"id"`      // e.g., "CCC-Endpoint-0123"
+	IP            string         `json:"-"`       // Primary address (IPv4 or IPv6), not exposed in API
+	SecondaryIP   string         `json:"-"`       // Other address family of a dual-stack resident, if linked
+	IPHash        string         `json:"ip_hash"` // SHA256 hash for lookup
+	ISP           string         `json:"isp"`     // "starry", "comcast", "unknown"
+	Status        EndpointStatus `json:"status"`  // "up", "degraded", "down", "unknown"
+	CreatedAt     time.Time      `json:"created_at"`
+	LastSeen      time.Time      `json:"last_seen"`
+	LastOK        time.Time      `json:"last_ok"`
+	MonitoredHop  string         `json:"-"`                      // IP of hop being monitored (if different from IP)
+	HopNumber     int            `json:"hop_number"`             // TTL/hop number of monitored hop (0 = direct)
+	UseHop        bool           `json:"use_hop"`                // True if monitoring a hop instead of direct IP
+	PausedUntil   *time.Time     `json:"paused_until,omitempty"` // Set while the resident has paused monitoring
+	ProbeStrategy string         `json:"-"`                      // Probe specs tried in order, comma-separated (empty = default)
+}
+
+// IsPaused reports whether the resident has paused monitoring at time now
+func (e Endpoint) IsPaused(now time.Time) bool {
+	return e.PausedUntil != nil && e.PausedUntil.After(now)
+}
+
+// Addresses returns every address registered for the endpoint, primary first
+func (e Endpoint) Addresses() []string {
+	if e.SecondaryIP == "" {
+		return []string{e.IP}
+	}
+	return []string{e.IP, e.SecondaryIP}
 }
 
 // ISPStatus represents aggregated status for an ISP
@@ -23,32 +75,64 @@ type ISPStatus struct {
 	ASN         int       `json:"asn,omitempty"`
 	TotalCount  int       `json:"total"`
 	UpCount     int       `json:"up"`
+	Degraded    int       `json:"degraded"`
 	DownCount   int       `json:"down"`
 	LastUpdated time.Time `json:"last_updated"`
 }
 
 // StatusResponse is returned by GET /api/status
 type StatusResponse struct {
-	ISP            string     `json:"isp"`
-	Registered     bool       `json:"registered"`
-	CanRegister    bool       `json:"can_register"`              // True if ISP is allowed to register
-	EndpointID     *string    `json:"endpoint_id"`
-	EndpointStatus string     `json:"endpoint_status,omitempty"` // "up", "down", "unreachable", "unknown"
-	ISPStatus      *ISPStatus `json:"isp_status,omitempty"`
+	ISP            string         `json:"isp"`
+	Registered     bool           `json:"registered"`
+	CanRegister    bool           `json:"can_register"`                    // True if the ISP and network may register
+	RejectReason   string         `json:"reject_reason,omitempty"`         // Admission rejection code when CanRegister is false
+	Requires       []string       `json:"registration_requires,omitempty"` // "invite" and/or "passphrase" if registering needs them
+	EndpointID     *string        `json:"endpoint_id"`
+	EndpointStatus EndpointStatus `json:"endpoint_status,omitempty"` // "up", "degraded", "down", "unknown"
+	PausedUntil    *time.Time     `json:"paused_until,omitempty"`    // Set while monitoring is paused
+	ISPStatus      *ISPStatus     `json:"isp_status,omitempty"`
+}
+
+// RegisterRequest is the optional body of POST /api/register
+type RegisterRequest struct {
+	DeviceToken string `json:"device_token,omitempty"` // Links this address to the token's endpoint
+	InviteCode  string `json:"invite_code,omitempty"`  // One-time invite, if the admission policy requires one
+	Passphrase  string `json:"passphrase,omitempty"`   // Building passphrase, if the admission policy requires it
 }
 
 // RegisterResponse is returned by POST /api/register
 type RegisterResponse struct {
-	EndpointID string `json:"endpoint_id"`
-	ISP        string `json:"isp"`
-	Message    string `json:"message"`
+	EndpointID    string `json:"endpoint_id"`
+	ISP           string `json:"isp"`
+	Message       string `json:"message"`
+	DeviceToken   string `json:"device_token,omitempty"`   // Only returned when a new endpoint is created
+	DeletionToken string `json:"deletion_token,omitempty"` // Only returned when a new endpoint is created
+}
+
+// UnregisterRequest is the optional body of DELETE /api/register. Without a
+// deletion token the endpoint registered from the caller's address is removed.
+type UnregisterRequest struct {
+	DeletionToken string `json:"deletion_token,omitempty"`
+}
+
+// PauseRequest is the optional body of POST /api/register/pause
+type PauseRequest struct {
+	Days *int `json:"days,omitempty"` // Length of the pause; 0 resumes monitoring (default 7)
+}
+
+// PauseResponse is returned by POST /api/register/pause
+type PauseResponse struct {
+	EndpointID  string     `json:"endpoint_id"`
+	PausedUntil *time.Time `json:"paused_until"` // null when monitoring was resumed
+	Message     string     `json:"message"`
 }
 
 // DashboardResponse is returned by GET /api/dashboard
 type DashboardResponse struct {
-	ISPs         []ISPStatus `json:"isps"`
-	LikelyOutage bool        `json:"likely_outage"`
-	LastUpdated  time.Time   `json:"last_updated"`
+	ISPs           []ISPStatus `json:"isps"`
+	LikelyOutage   bool        `json:"likely_outage"`
+	MonitorOffline bool        `json:"monitor_offline"` // The server lost its own connectivity; statuses are frozen
+	LastUpdated    time.Time   `json:"last_updated"`
 }
 
 // HealthResponse is returned by GET /api/health
@@ -60,35 +144,36 @@ type HealthResponse struct {
 // AdminMetrics contains comprehensive system metrics
 type AdminMetrics struct {
 	// Overview
-	TotalEndpoints   int     `json:"total_endpoints"`
-	EndpointsUp      int     `json:"endpoints_up"`
-	EndpointsDown    int     `json:"endpoints_down"`
-	EndpointsUnknown int     `json:"endpoints_unknown"`
-	OverallUptimePct float64 `json:"overall_uptime_pct"`
+	TotalEndpoints    int     `json:"total_endpoints"`
+	EndpointsUp       int     `json:"endpoints_up"`
+	EndpointsDegraded int     `json:"endpoints_degraded"`
+	EndpointsDown     int     `json:"endpoints_down"`
+	EndpointsUnknown  int     `json:"endpoints_unknown"`
+	OverallUptimePct  float64 `json:"overall_uptime_pct"`
 
 	// Per-ISP breakdown
 	ISPStats []ISPMetrics `json:"isp_stats"`
 
 	// Monitoring stats
-	LastPingTime     time.Time `json:"last_ping_time"`
-	PingInterval     string    `json:"ping_interval"`
-	NextPingTime     time.Time `json:"next_ping_time"`
-	TotalPingCycles  int64     `json:"total_ping_cycles"`
+	LastPingTime    time.Time `json:"last_ping_time"`
+	PingInterval    string    `json:"ping_interval"`
+	NextPingTime    time.Time `json:"next_ping_time"`
+	TotalPingCycles int64     `json:"total_ping_cycles"`
 
 	// Endpoint details
-	DirectMonitored  int `json:"direct_monitored"`   // Endpoints monitored directly
-	HopMonitored     int `json:"hop_monitored"`      // Endpoints monitored via hop
-	SharedHops       int `json:"shared_hops"`        // Number of shared hops
+	DirectMonitored int `json:"direct_monitored"` // Endpoints monitored directly
+	HopMonitored    int `json:"hop_monitored"`    // Endpoints monitored via hop
+	SharedHops      int `json:"shared_hops"`      // Number of shared hops
 
 	// System info
-	ServerStartTime  time.Time `json:"server_start_time"`
-	ServerUptime     string    `json:"server_uptime"`
-	Version          string    `json:"version"`
-	DatabaseSize     int64     `json:"database_size_bytes"`
-	DatabasePath     string    `json:"database_path"`
+	ServerStartTime time.Time `json:"server_start_time"`
+	ServerUptime    string    `json:"server_uptime"`
+	Version         string    `json:"version"`
+	DatabaseSize    int64     `json:"database_size_bytes"`
+	DatabasePath    string    `json:"database_path"`
 
 	// Historical (last 24h)
-	UptimeHistory    []UptimePoint `json:"uptime_history"`
+	UptimeHistory []UptimePoint `json:"uptime_history"`
 }
 
 // ISPMetrics contains per-ISP metrics
@@ -96,6 +181,7 @@ type ISPMetrics struct {
 	Name         string  `json:"name"`
 	Total        int     `json:"total"`
 	Up           int     `json:"up"`
+	Degraded     int     `json:"degraded"`
 	Down         int     `json:"down"`
 	Unknown      int     `json:"unknown"`
 	UptimePct    float64 `json:"uptime_pct"`
@@ -110,16 +196,587 @@ type UptimePoint struct {
 	Down      int       `json:"down"`
 }
 
+// ProbeResult is a single probe measurement for an endpoint
+type ProbeResult struct {
+	EndpointID string    `json:"endpoint_id"`
+	ISP        string    `json:"isp"`
+	Timestamp  time.Time `json:"timestamp"`
+	Success    bool      `json:"success"`
+	ViaHop     bool      `json:"via_hop"` // True if the monitored hop was probed instead of the endpoint
+	MinRTTMs   float64   `json:"min_rtt_ms"`
+	AvgRTTMs   float64   `json:"avg_rtt_ms"`
+	MaxRTTMs   float64   `json:"max_rtt_ms"`
+	JitterMs   float64   `json:"jitter_ms"` // Standard deviation of RTT
+	LossPct    float64   `json:"loss_pct"`
+	Method     string    `json:"method,omitempty"` // Probe spec that decided the result, e.g. "icmp" or "tcp:443"
+}
+
 // Event represents a status change or notable occurrence
 type Event struct {
 	ID         int64     `json:"id"`
 	Timestamp  time.Time `json:"timestamp"`
-	EventType  string    `json:"event_type"`  // "down", "up", "outage", "recovery", "registered"
+	EventType  string    `json:"event_type"` // "down", "up", "outage", "recovery", "registered", "ip_changed", "monitor_offline", "monitor_online", "disagreement"
 	ISP        string    `json:"isp,omitempty"`
 	EndpointID string    `json:"endpoint_id,omitempty"`
 	Message    string    `json:"message"`
 }
 
+// OutagePolicy decides when the scheduler considers an ISP to be in an
+// outage. ISPs differ: fixed wireless drops individual links far more often
+// than cable does, so one rule does not fit all.
+type OutagePolicy struct {
+	MinEndpoints          int     `json:"min_endpoints"`            // Endpoints needed before any detection
+	DownThreshold         float64 `json:"down_threshold"`           // Outage when the down fraction exceeds this (0-1)
+	PersistCycles         int     `json:"persist_cycles"`           // Consecutive ping cycles the condition must hold
+	SharedHopMinEndpoints int     `json:"shared_hop_min_endpoints"` // Down endpoints behind one failing hop that signal an outage (0 = off)
+}
+
+// DefaultOutagePolicy is the rule used before any policy is configured
+func DefaultOutagePolicy() OutagePolicy {
+	return OutagePolicy{
+		MinEndpoints:          2,
+		DownThreshold:         0.5,
+		PersistCycles:         1,
+		SharedHopMinEndpoints: 2,
+	}
+}
+
+// Validate checks that the policy can be applied
+func (p OutagePolicy) Validate() error {
+	switch {
+	case p.MinEndpoints < 1:
+		return fmt.Errorf("min_endpoints must be at least 1")
+	case p.DownThreshold < 0 || p.DownThreshold > 1:
+		return fmt.Errorf("down_threshold must be between 0 and 1")
+	case p.PersistCycles < 1:
+		return fmt.Errorf("persist_cycles must be at least 1")
+	case p.SharedHopMinEndpoints == 1 || p.SharedHopMinEndpoints < 0:
+		return fmt.Errorf("shared_hop_min_endpoints must be 0 (off) or at least 2")
+	}
+	return nil
+}
+
+// Probe methods
+const (
+	ProbeICMP  = "icmp"  // ICMP echo
+	ProbeTCP   = "tcp"   // TCP connect; a refused connection also proves the host is up
+	ProbeUDP   = "udp"   // UDP datagram; a reply or port-unreachable proves the host is up
+	ProbeHTTP  = "http"  // HTTP GET; any response counts
+	ProbeHTTPS = "https" // HTTPS GET without certificate verification; any response counts
+)
+
+// DefaultProbeStrategy is used for endpoints without their own strategy
+// unless --probe-strategy says otherwise
+const DefaultProbeStrategy = ProbeICMP
+
+// ProbeSpec is one step of a probe strategy, written "icmp", "tcp:443",
+// "udp:53", "http", "http:8080/status" or "https:8443/"
+type ProbeSpec struct {
+	Method string
+	Port   int    // Required for tcp and udp; http and https default to 80 and 443
+	Path   string // HTTP(S) request path, default "/"
+}
+
+// ParseProbeSpec parses one probe spec
+func ParseProbeSpec(s string) (ProbeSpec, error) {
+	s = strings.TrimSpace(s)
+	method, rest, _ := strings.Cut(s, ":")
+	method = strings.ToLower(method)
+	spec := ProbeSpec{Method: method}
+
+	portStr, path := rest, ""
+	if i := strings.Index(rest, "/"); i >= 0 {
+		portStr, path = rest[:i], rest[i:]
+	}
+	if portStr != "" {
+		port, err := strconv.Atoi(portStr)
+		if err != nil || port < 1 || port > 65535 {
+			return spec, fmt.Errorf("invalid port in probe %q", s)
+		}
+		spec.Port = port
+	}
+
+	switch method {
+	case ProbeICMP:
+		if rest != "" {
+			return spec, fmt.Errorf("icmp probe takes no port")
+		}
+	case ProbeTCP, ProbeUDP:
+		if spec.Port == 0 {
+			return spec, fmt.Errorf("%s probe needs a port, e.g. %s:443", method, method)
+		}
+		if path != "" {
+			return spec, fmt.Errorf("%s probe takes no path", method)
+		}
+	case ProbeHTTP, ProbeHTTPS:
+		if spec.Port == 0 {
+			spec.Port = 80
+			if method == ProbeHTTPS {
+				spec.Port = 443
+			}
+		}
+		spec.Path = path
+		if spec.Path == "" {
+			spec.Path = "/"
+		}
+	default:
+		return spec, fmt.Errorf("unknown probe method %q (icmp, tcp, udp, http, https)", method)
+	}
+	return spec, nil
+}
+
+// String returns the spec in the form ParseProbeSpec reads
+func (p ProbeSpec) String() string {
+	switch p.Method {
+	case ProbeICMP:
+		return p.Method
+	case ProbeHTTP, ProbeHTTPS:
+		return p.Method + ":" + strconv.Itoa(p.Port) + p.Path
+	}
+	return p.Method + ":" + strconv.Itoa(p.Port)
+}
+
+// ParseProbeStrategy parses a comma-separated list of probe specs. An
+// empty string is an empty strategy.
+func ParseProbeStrategy(s string) ([]ProbeSpec, error) {
+	var strategy []ProbeSpec
+	for _, part := range strings.Split(s, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		spec, err := ParseProbeSpec(part)
+		if err != nil {
+			return nil, err
+		}
+		strategy = append(strategy, spec)
+	}
+	return strategy, nil
+}
+
+// FormatProbeStrategy joins probe specs in the form ParseProbeStrategy reads
+func FormatProbeStrategy(strategy []ProbeSpec) string {
+	parts := make([]string, len(strategy))
+	for i, spec := range strategy {
+		parts[i] = spec.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// ISPDefinition describes one ISP for classification and registration.
+// Addresses belong to the ISP if their origin ASN is one of ASNs or they
+// fall in one of IncludePrefixes.
+type ISPDefinition struct {
+	Display         string     `json:"display"`                    // ISP name shown to residents and used as the endpoint's ISP
+	PrimaryASN      int        `json:"primary_asn"`                // Canonical ASN shown for the ISP (default: the lowest of ASNs)
+	ASNs            []int      `json:"asns"`                       // Every ASN the ISP announces its addresses from
+	Allowed         bool       `json:"allowed"`                    // Whether residents of this ISP may register
+	IncludePrefixes []string   `json:"include_prefixes,omitempty"` // If set, only addresses in these prefixes may register
+	ExcludePrefixes []string   `json:"exclude_prefixes,omitempty"` // Addresses in these prefixes may not register
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`       // nil for ISPs read from a file
+}
+
+// Admission rejection codes, returned as "code" in registration errors
+const (
+	RejectISPNotAllowed      = "isp_not_allowed"      // The ISP or its prefix rules do not allow registration
+	RejectNetworkNotAllowed  = "network_not_allowed"  // The address is outside the admission networks
+	RejectInviteRequired     = "invite_required"      // No invite code was given
+	RejectInviteInvalid      = "invite_invalid"       // The invite code is unknown or was revoked
+	RejectInviteUsed         = "invite_used"          // The invite code was already used
+	RejectInviteExpired      = "invite_expired"       // The invite code has expired
+	RejectPassphraseRequired = "passphrase_required"  // No passphrase was given
+	RejectPassphraseWrong    = "passphrase_incorrect" // The passphrase does not match
+)
+
+// Admission requirements listed in StatusResponse.Requires
+const (
+	RequireInvite     = "invite"
+	RequirePassphrase = "passphrase"
+)
+
+// AdmissionNetwork is one network residents may register from. With both
+// fields set, the address must be in Prefix and announced by ASN.
+type AdmissionNetwork struct {
+	ASN    int    `json:"asn,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// AdmissionPolicy restricts registration beyond the ISP check, e.g. to the
+// building. Every enabled requirement must be met.
+type AdmissionPolicy struct {
+	Networks          []AdmissionNetwork `json:"networks"`           // Register only from these networks (empty = any network)
+	RequireInvite     bool               `json:"require_invite"`     // Require a one-time invite code
+	RequirePassphrase bool               `json:"require_passphrase"` // Require the building passphrase
+}
+
+// Validate checks the networks and returns them with prefixes in
+// canonical form
+func (p AdmissionPolicy) Validate() (AdmissionPolicy, error) {
+	networks := make([]AdmissionNetwork, 0, len(p.Networks))
+	for _, n := range p.Networks {
+		if n.ASN < 0 || (n.ASN == 0 && n.Prefix == "") {
+			return p, fmt.Errorf("each network needs an asn, a prefix or both")
+		}
+		if n.Prefix != "" {
+			_, ipNet, err := net.ParseCIDR(n.Prefix)
+			if err != nil {
+				return p, fmt.Errorf("invalid prefix %q", n.Prefix)
+			}
+			n.Prefix = ipNet.String()
+		}
+		networks = append(networks, n)
+	}
+	p.Networks = networks
+	return p, nil
+}
+
+// Invite is a one-time registration code. The code itself is only shown
+// when the invite is created.
+type Invite struct {
+	ID         int64      `json:"id"`
+	Note       string     `json:"note,omitempty"` // e.g. the apartment it was handed to
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+	EndpointID string     `json:"endpoint_id,omitempty"` // Endpoint registered with the invite
+}
+
+// ReferenceTarget is a well-known address outside the building, such as a
+// public resolver or an ISP's gateway, probed every cycle to check the
+// monitoring server's own connectivity
+type ReferenceTarget struct {
+	ID            int64          `json:"id"`
+	Name          string         `json:"name"`
+	Address       string         `json:"address"`
+	ProbeStrategy []string       `json:"probe_strategy,omitempty"` // Empty = the default strategy
+	Status        EndpointStatus `json:"status"`                   // "up", "down" or "unknown"
+	LastChecked   *time.Time     `json:"last_checked,omitempty"`
+	LastOK        *time.Time     `json:"last_ok,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// VantageServer names the monitoring server itself among the vantage points
+// whose views of an endpoint are merged
+const VantageServer = "server"
+
+// ProbeAgent is a ccc-agent process that probes endpoints from another
+// vantage point, such as a second uplink or a partner building
+type ProbeAgent struct {
+	ID        int64      `json:"id"`
+	Name      string     `json:"name"`
+	ISPs      []string   `json:"isps,omitempty"` // ISPs whose endpoints it probes; empty = all
+	CreatedAt time.Time  `json:"created_at"`
+	LastSeen  *time.Time `json:"last_seen,omitempty"`
+}
+
+// AgentEndpoint is an endpoint assigned to a probe agent
+type AgentEndpoint struct {
+	ID            string   `json:"id"`
+	IP            string   `json:"ip"`
+	SecondaryIP   string   `json:"secondary_ip,omitempty"`
+	ProbeStrategy []string `json:"probe_strategy,omitempty"` // Empty = the assignment's default
+}
+
+// AgentAssignment is the response of GET /api/agent/endpoints
+type AgentAssignment struct {
+	Agent                string          `json:"agent"`
+	IntervalSeconds      int             `json:"interval_seconds"`
+	DefaultProbeStrategy []string        `json:"default_probe_strategy"`
+	Endpoints            []AgentEndpoint `json:"endpoints"`
+}
+
+// AgentResult is one endpoint's probe outcome as seen by an agent
+type AgentResult struct {
+	EndpointID string         `json:"endpoint_id"`
+	Status     EndpointStatus `json:"status"` // After the agent's own flap damping
+	Success    bool           `json:"success"`
+	RTTMs      float64        `json:"rtt_ms,omitempty"`
+	LossPct    float64        `json:"loss_pct"`
+	Method     string         `json:"method,omitempty"`
+	CheckedAt  time.Time      `json:"checked_at"`
+}
+
+// AgentReport is the body of POST /api/agent/results
+type AgentReport struct {
+	Results []AgentResult `json:"results"`
+}
+
+// AgentObservation is the latest result an agent reported for an endpoint
+type AgentObservation struct {
+	Agent string `json:"agent"`
+	AgentResult
+}
+
+// VantageDisagreement flags vantage points that disagree about an endpoint's
+// status or, with no endpoint, about whether an ISP has an outage
+type VantageDisagreement struct {
+	ISP        string    `json:"isp"`
+	EndpointID string    `json:"endpoint_id,omitempty"`
+	Down       []string  `json:"down"` // Vantage points that see it down (or the ISP in an outage)
+	Up         []string  `json:"up"`   // Vantage points that see it reachable
+	Since      time.Time `json:"since"`
+}
+
+// ReclassifyResult reports a re-classification of existing endpoints
+type ReclassifyResult struct {
+	Checked int `json:"checked"` // Endpoints looked up
+	Changed int `json:"changed"` // Endpoints moved to a different ISP
+	Failed  int `json:"failed"`  // Lookups that failed; those endpoints were left alone
+}
+
+// Incident detection methods
+const (
+	DetectionThreshold = "threshold"  // Share of down endpoints crossed the outage threshold
+	DetectionSharedHop = "shared_hop" // Several endpoints behind the same upstream hop went down together
+
+	// Every reference target failed: the monitoring server itself lost
+	// connectivity. Such incidents have no ISP.
+	DetectionMonitorOffline = "monitor_offline"
+)
+
+// Incident is an ISP-level outage from detection to recovery
+type Incident struct {
+	ID              int64      `json:"id"`
+	ISP             string     `json:"isp"` // Empty for monitor offline incidents
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"` // Nil while the incident is ongoing
+	Ongoing         bool       `json:"ongoing"`
+	DurationSeconds int64      `json:"duration_seconds"`     // Elapsed so far if ongoing
+	PeakAffected    int        `json:"peak_affected"`        // Most endpoints down at once
+	TotalEndpoints  int        `json:"total_endpoints"`      // ISP endpoint count at the peak
+	DetectionMethod string     `json:"detection_method"`     // DetectionThreshold, DetectionSharedHop or DetectionMonitorOffline
+	SharedHop       string     `json:"shared_hop,omitempty"` // Admin only; cleared for public responses
+	Note            string     `json:"note,omitempty"`
+}
+
+// FormatDuration renders an incident duration as e.g. "2h 5m" or "less than a minute"
+func FormatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	if h == 0 {
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%dh %dm", h, m)
+}
+
+// Availability report breakdowns
+const (
+	BreakdownNone    = "none"    // One row per ISP and endpoint for the whole range
+	BreakdownDaily   = "daily"   // One row per calendar day
+	BreakdownMonthly = "monthly" // One row per calendar month
+)
+
+// AvailabilityRow is the availability of one ISP or endpoint over one period.
+// Durations only count time the monitoring server itself was online.
+type AvailabilityRow struct {
+	Period                  string   `json:"period"` // "2006-01-02", "2006-01" or "from/to" for the whole range
+	ISP                     string   `json:"isp"`
+	EndpointID              string   `json:"endpoint_id,omitempty"` // Empty for ISP rows
+	MonitoredMinutes        float64  `json:"monitored_minutes"`
+	OutageMinutes           float64  `json:"outage_minutes"`
+	AvailabilityPct         *float64 `json:"availability_pct"`                    // Nil if never monitored in the period
+	EndpointAvailabilityPct *float64 `json:"endpoint_availability_pct,omitempty"` // ISP rows: share of endpoint time up
+	Failures                int      `json:"failures"`                            // Incidents (ISP) or up-to-down transitions (endpoint)
+	MTBFHours               *float64 `json:"mtbf_hours"`                          // Mean time between failures; nil without failures
+	MTTRMinutes             *float64 `json:"mttr_minutes"`                        // Mean time to recovery; nil without failures
+}
+
+// AvailabilityReport is the availability of every ISP and endpoint over a
+// date range, in the server's time zone
+type AvailabilityReport struct {
+	From        string            `json:"from"` // First day, inclusive
+	To          string            `json:"to"`   // Last day, inclusive
+	Breakdown   string            `json:"breakdown"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	ISPs        []AvailabilityRow `json:"isps"`
+	Endpoints   []AvailabilityRow `json:"endpoints"`
+}
+
+// NotifierConfig is an admin-configured alert destination
+type NotifierConfig struct {
+	ID        int64           `json:"id"`
+	Name      string          `json:"name"`
+	Type      string          `json:"type"` // "webhook", "smtp", "ntfy", "gotify"
+	Enabled   bool            `json:"enabled"`
+	Config    json.RawMessage `json:"config"` // Backend-specific settings
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// NotificationDelivery is one delivery attempt of a notification
+type NotificationDelivery struct {
+	ID         int64     `json:"id"`
+	NotifierID int64     `json:"notifier_id"`
+	Timestamp  time.Time `json:"timestamp"`
+	Event      string    `json:"event"` // "incident_opened", "incident_closed", "test"
+	IncidentID int64     `json:"incident_id,omitempty"`
+	Attempt    int       `json:"attempt"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// AdminRole is the permission level of an admin account. Each role includes
+// the permissions of the roles below it.
+type AdminRole string
+
+const (
+	RoleViewer   AdminRole = "viewer"   // Read-only access to the admin panel
+	RoleOperator AdminRole = "operator" // Manage endpoints, incidents and notifiers
+	RoleOwner    AdminRole = "owner"    // Change settings and site content
+)
+
+// rank orders roles; unknown roles rank below viewer
+func (r AdminRole) rank() int {
+	switch r {
+	case RoleViewer:
+		return 1
+	case RoleOperator:
+		return 2
+	case RoleOwner:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// Valid reports whether r is a known role
+func (r AdminRole) Valid() bool {
+	return r.rank() > 0
+}
+
+// AtLeast reports whether r grants the permissions of min
+func (r AdminRole) AtLeast(min AdminRole) bool {
+	return r.Valid() && r.rank() >= min.rank()
+}
+
+// AdminUser is an admin panel account
+type AdminUser struct {
+	ID        int64      `json:"id"`
+	Username  string     `json:"username"`
+	Role      AdminRole  `json:"role"`
+	Disabled  bool       `json:"disabled"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastLogin *time.Time `json:"last_login,omitempty"`
+}
+
+// Audit actions
+const (
+	AuditEndpointCreate     = "endpoint.create"
+	AuditEndpointDelete     = "endpoint.delete"
+	AuditEndpointProbes     = "endpoint.probes"     // Probe strategy changed
+	AuditEndpointExpire     = "endpoint.expire"     // Removed by the scheduler after --expire-days
+	AuditEndpointUnregister = "endpoint.unregister" // Removed by the resident
+	AuditIncidentAnnotate   = "incident.annotate"
+	AuditISPCreate          = "isp.create"
+	AuditISPUpdate          = "isp.update"
+	AuditISPDelete          = "isp.delete"
+	AuditISPImport          = "isp.import"
+	AuditISPReclassify      = "isp.reclassify"
+	AuditAdmissionUpdate    = "admission.update"
+	AuditInviteCreate       = "invite.create"
+	AuditInviteDelete       = "invite.delete"
+	AuditReferenceCreate    = "reference.create"
+	AuditReferenceUpdate    = "reference.update"
+	AuditReferenceDelete    = "reference.delete"
+	AuditAgentCreate        = "agent.create"
+	AuditAgentUpdate        = "agent.update"
+	AuditAgentRotateKey     = "agent.rotate_key"
+	AuditAgentDelete        = "agent.delete"
+	AuditNotifierCreate     = "notifier.create"
+	AuditNotifierUpdate     = "notifier.update"
+	AuditNotifierDelete     = "notifier.delete"
+	AuditSettingsUpdate     = "settings.update"
+	AuditSiteConfigUpdate   = "site_config.update"
+	AuditUserCreate         = "user.create"
+	AuditUserUpdate         = "user.update"
+	AuditBackupCreate       = "backup.create"
+	AuditBackupDownload     = "backup.download"
+)
+
+// Audit actors that are not admin accounts
+const (
+	AuditActorSystem   = "system"   // Automatic changes, e.g. endpoint expiry
+	AuditActorCLI      = "cli"      // The "ccc-api user" subcommands
+	AuditActorResident = "resident" // Self-service changes to a resident's own endpoint
+)
+
+// AuditEntry records one change made by an admin or by the system
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	Timestamp time.Time       `json:"timestamp"`
+	Actor     string          `json:"actor"` // Admin username, or "system" / "cli"
+	ClientIP  string          `json:"client_ip,omitempty"`
+	Action    string          `json:"action"`           // e.g. "endpoint.delete"
+	Target    string          `json:"target,omitempty"` // e.g. the endpoint or notifier ID
+	Changes   json.RawMessage `json:"changes,omitempty"`
+}
+
+// AuditChange is the before and after value of one changed field
+type AuditChange struct {
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// AuditDiff compares the JSON encodings of before and after field by field
+// and returns the changed fields as {"field": {"before": ..., "after": ...}}.
+// Either side may be nil for creations and deletions. Values that are not
+// JSON objects are compared as a single "value" field.
+func AuditDiff(before, after interface{}) (json.RawMessage, error) {
+	b, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]AuditChange)
+	for k, v := range b {
+		if string(a[k]) != string(v) {
+			changes[k] = AuditChange{Before: v, After: a[k]}
+		}
+	}
+	for k, v := range a {
+		if _, ok := b[k]; !ok {
+			changes[k] = AuditChange{After: v}
+		}
+	}
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(changes)
+}
+
+// auditFields splits the JSON encoding of v into its top-level fields
+func auditFields(v interface{}) (map[string]json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit value: %w", err)
+	}
+	if string(data) == "null" {
+		return nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return map[string]json.RawMessage{"value": data}, nil
+	}
+	return fields, nil
+}
+
+// BackupInfo describes a database backup file
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // SiteConfig contains customizable site content
 type SiteConfig struct {
 	SiteName        string   `json:"site_name"`
@@ -0,0 +1,157 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// agentStaleCycles is for how many ping intervals a result reported by a
+// probe agent counts. Agents that stop reporting drop out of the analysis.
+const agentStaleCycles = 3
+
+// agentObservations returns the recent results reported by probe agents, by
+// endpoint ID
+func (s *Scheduler) agentObservations() map[string][]models.AgentObservation {
+	since := time.Now().Add(-agentStaleCycles * s.pingInterval)
+	observations, err := s.db.GetAgentObservations(since)
+	if err != nil {
+		log.Printf("Failed to load agent observations: %v", err)
+		return nil
+	}
+	return observations
+}
+
+// vantageViews splits an ISP's endpoints into what each vantage point sees:
+// the server all of them with its own statuses, each agent the ones it
+// reported on with its statuses. Upstream hops are the server's, so agent
+// views leave them out.
+func vantageViews(eps []models.Endpoint, observations map[string][]models.AgentObservation) map[string][]models.Endpoint {
+	views := map[string][]models.Endpoint{models.VantageServer: eps}
+	for _, ep := range eps {
+		for _, o := range observations[ep.ID] {
+			seen := ep
+			seen.Status = o.Status
+			seen.UseHop, seen.MonitoredHop = false, ""
+			views[o.Agent] = append(views[o.Agent], seen)
+		}
+	}
+	return views
+}
+
+// compareOutageViews applies an ISP's outage policy to each vantage point's
+// view on its own. Returns a disagreement if some see an outage and others
+// do not. Vantage points that see fewer than min_endpoints endpoints cannot
+// tell and are left out.
+func compareOutageViews(isp string, views map[string][]models.Endpoint, policy models.OutagePolicy) *models.VantageDisagreement {
+	var down, up []string
+	for _, name := range slices.Sorted(maps.Keys(views)) {
+		view := views[name]
+		if len(view) < policy.MinEndpoints {
+			continue
+		}
+		if _, ok := detectOutage(view, policy); ok {
+			down = append(down, name)
+		} else {
+			up = append(up, name)
+		}
+	}
+	if len(down) == 0 || len(up) == 0 {
+		return nil
+	}
+	return &models.VantageDisagreement{ISP: isp, Down: down, Up: up}
+}
+
+// mergeVantages returns the endpoints with the status most vantage points
+// agree on. On a tie the server's own status stands. Endpoints the vantage
+// points disagree about are returned as disagreements.
+func mergeVantages(eps []models.Endpoint, observations map[string][]models.AgentObservation) ([]models.Endpoint, []models.VantageDisagreement) {
+	merged := make([]models.Endpoint, len(eps))
+	var disagreements []models.VantageDisagreement
+
+	for i, ep := range eps {
+		merged[i] = ep
+		obs := observations[ep.ID]
+		if len(obs) == 0 {
+			continue
+		}
+
+		var down, up []string
+		vote := func(name string, status models.EndpointStatus) {
+			if status == models.StatusDown {
+				down = append(down, name)
+			} else if status.IsReachable() {
+				up = append(up, name)
+			}
+		}
+		vote(models.VantageServer, ep.Status)
+		for _, o := range obs {
+			vote(o.Agent, o.Status)
+		}
+
+		switch {
+		case len(down) > len(up):
+			merged[i].Status = models.StatusDown
+		case len(up) > len(down) && !ep.Status.IsReachable():
+			merged[i].Status = models.StatusUp
+		}
+
+		if len(down) > 0 && len(up) > 0 {
+			disagreements = append(disagreements, models.VantageDisagreement{
+				ISP:        ep.ISP,
+				EndpointID: ep.ID,
+				Down:       down,
+				Up:         up,
+			})
+		}
+	}
+	return merged, disagreements
+}
+
+// trackDisagreements replaces the current disagreements, keeping when each
+// began. An ISP-level disagreement that is new records an event.
+func (s *Scheduler) trackDisagreements(found []models.VantageDisagreement) {
+	now := time.Now()
+	current := make(map[string]models.VantageDisagreement, len(found))
+	var started []models.VantageDisagreement
+
+	s.outagesMu.Lock()
+	for _, d := range found {
+		key := d.ISP + "\x00" + d.EndpointID
+		if prev, ok := s.disagreements[key]; ok {
+			d.Since = prev.Since
+		} else {
+			d.Since = now
+			if d.EndpointID == "" {
+				started = append(started, d)
+			}
+		}
+		current[key] = d
+	}
+	s.disagreements = current
+	s.outagesMu.Unlock()
+
+	for _, d := range started {
+		msg := fmt.Sprintf("%s outage seen from %s but not from %s", d.ISP, strings.Join(d.Down, ", "), strings.Join(d.Up, ", "))
+		log.Printf("Vantage points disagree: %s", msg)
+		s.recordEvent("disagreement", d.ISP, "", msg)
+	}
+}
+
+// VantageDisagreements returns the ISPs and endpoints the server and probe
+// agents currently disagree about
+func (s *Scheduler) VantageDisagreements() []models.VantageDisagreement {
+	s.outagesMu.RLock()
+	defer s.outagesMu.RUnlock()
+
+	result := make([]models.VantageDisagreement, 0, len(s.disagreements))
+	for _, key := range slices.Sorted(maps.Keys(s.disagreements)) {
+		result = append(result, s.disagreements[key])
+	}
+	return result
+}
@@ -0,0 +1,167 @@
+package monitor
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+func endpoint(id string, status models.EndpointStatus) models.Endpoint {
+	return models.Endpoint{ID: id, ISP: "Comcast", Status: status}
+}
+
+func observation(agent, endpointID string, status models.EndpointStatus) models.AgentObservation {
+	return models.AgentObservation{Agent: agent, AgentResult: models.AgentResult{EndpointID: endpointID, Status: status}}
+}
+
+func TestMergeVantages(t *testing.T) {
+	tests := []struct {
+		name         string
+		server       models.EndpointStatus
+		observations []models.AgentObservation
+		want         models.EndpointStatus
+		disagreement *models.VantageDisagreement
+	}{
+		{
+			name:   "no agents keeps the server status",
+			server: models.StatusDown,
+			want:   models.StatusDown,
+		},
+		{
+			name:         "agents agree",
+			server:       models.StatusUp,
+			observations: []models.AgentObservation{observation("north", "ep", models.StatusUp)},
+			want:         models.StatusUp,
+		},
+		{
+			name:   "majority sees it up",
+			server: models.StatusDown,
+			observations: []models.AgentObservation{
+				observation("north", "ep", models.StatusUp),
+				observation("south", "ep", models.StatusDegraded),
+			},
+			want:         models.StatusUp,
+			disagreement: &models.VantageDisagreement{ISP: "Comcast", EndpointID: "ep", Down: []string{"server"}, Up: []string{"north", "south"}},
+		},
+		{
+			name:   "majority sees it down",
+			server: models.StatusUp,
+			observations: []models.AgentObservation{
+				observation("north", "ep", models.StatusDown),
+				observation("south", "ep", models.StatusDown),
+			},
+			want:         models.StatusDown,
+			disagreement: &models.VantageDisagreement{ISP: "Comcast", EndpointID: "ep", Down: []string{"north", "south"}, Up: []string{"server"}},
+		},
+		{
+			name:         "tie keeps a down server status",
+			server:       models.StatusDown,
+			observations: []models.AgentObservation{observation("north", "ep", models.StatusUp)},
+			want:         models.StatusDown,
+			disagreement: &models.VantageDisagreement{ISP: "Comcast", EndpointID: "ep", Down: []string{"server"}, Up: []string{"north"}},
+		},
+		{
+			name:         "tie keeps a degraded server status",
+			server:       models.StatusDegraded,
+			observations: []models.AgentObservation{observation("north", "ep", models.StatusDown)},
+			want:         models.StatusDegraded,
+			disagreement: &models.VantageDisagreement{ISP: "Comcast", EndpointID: "ep", Down: []string{"north"}, Up: []string{"server"}},
+		},
+		{
+			name:         "unknown server status does not vote",
+			server:       models.StatusUnknown,
+			observations: []models.AgentObservation{observation("north", "ep", models.StatusDown)},
+			want:         models.StatusDown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eps := []models.Endpoint{endpoint("ep", tt.server), endpoint("other", models.StatusUp)}
+			observations := map[string][]models.AgentObservation{"ep": tt.observations}
+
+			merged, disagreements := mergeVantages(eps, observations)
+			if merged[0].Status != tt.want {
+				t.Errorf("status = %s, want %s", merged[0].Status, tt.want)
+			}
+			if merged[1].Status != models.StatusUp {
+				t.Errorf("endpoint without observations changed to %s", merged[1].Status)
+			}
+			if eps[0].Status != tt.server {
+				t.Error("input endpoints were modified")
+			}
+
+			var want []models.VantageDisagreement
+			if tt.disagreement != nil {
+				want = []models.VantageDisagreement{*tt.disagreement}
+			}
+			if !reflect.DeepEqual(disagreements, want) {
+				t.Errorf("disagreements = %+v, want %+v", disagreements, want)
+			}
+		})
+	}
+}
+
+func TestCompareOutageViews(t *testing.T) {
+	policy := models.OutagePolicy{MinEndpoints: 2, DownThreshold: 0.5, PersistCycles: 1}
+	down := []models.Endpoint{endpoint("a", models.StatusDown), endpoint("b", models.StatusDown)}
+	up := []models.Endpoint{endpoint("a", models.StatusUp), endpoint("b", models.StatusUp)}
+
+	tests := []struct {
+		name  string
+		views map[string][]models.Endpoint
+		want  *models.VantageDisagreement
+	}{
+		{
+			name:  "all see an outage",
+			views: map[string][]models.Endpoint{"server": down, "north": down},
+		},
+		{
+			name:  "none see an outage",
+			views: map[string][]models.Endpoint{"server": up, "north": up},
+		},
+		{
+			name:  "only an agent sees an outage",
+			views: map[string][]models.Endpoint{"server": up, "north": down, "south": up},
+			want:  &models.VantageDisagreement{ISP: "Comcast", Down: []string{"north"}, Up: []string{"server", "south"}},
+		},
+		{
+			name:  "only the server sees an outage",
+			views: map[string][]models.Endpoint{"server": down, "north": up},
+			want:  &models.VantageDisagreement{ISP: "Comcast", Down: []string{"server"}, Up: []string{"north"}},
+		},
+		{
+			name:  "too few endpoints cannot tell",
+			views: map[string][]models.Endpoint{"server": down, "north": up[:1]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareOutageViews("Comcast", tt.views, policy)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVantageViews(t *testing.T) {
+	eps := []models.Endpoint{
+		{ID: "a", Status: models.StatusUp, UseHop: true, MonitoredHop: "10.0.0.1"},
+		{ID: "b", Status: models.StatusUp},
+	}
+	views := vantageViews(eps, map[string][]models.AgentObservation{
+		"a": {observation("north", "a", models.StatusDown)},
+	})
+
+	if len(views[models.VantageServer]) != 2 {
+		t.Errorf("server view has %d endpoints, want 2", len(views[models.VantageServer]))
+	}
+	north := views["north"]
+	if len(north) != 1 || north[0].ID != "a" || north[0].Status != models.StatusDown {
+		t.Fatalf("north view = %+v", north)
+	}
+	if north[0].UseHop || north[0].MonitoredHop != "" {
+		t.Error("agent view kept the server's monitored hop")
+	}
+}
@@ -0,0 +1,211 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+const (
+	// defaultHopRefresh is how often a monitored hop is re-discovered
+	defaultHopRefresh = 6 * time.Hour
+
+	// hopDiscoveryFailures is the number of consecutive failed pings before
+	// an established endpoint is traced (new endpoints are traced at once)
+	hopDiscoveryFailures = 3
+
+	// hopQueueSize bounds the number of pending traceroutes
+	hopQueueSize = 256
+)
+
+// noteHopFailure counts a failed direct ping and queues hop discovery for
+// endpoints that are new or have been unreachable for several cycles
+func (s *Scheduler) noteHopFailure(ep *models.Endpoint) {
+	if s.tracer == nil {
+		return
+	}
+
+	s.hopMu.Lock()
+	s.hopFailures[ep.ID]++
+	failures := s.hopFailures[ep.ID]
+	s.hopMu.Unlock()
+
+	neverSeen := ep.Status == models.StatusUnknown && ep.LastOK.IsZero()
+	if neverSeen || failures >= hopDiscoveryFailures {
+		s.queueHopDiscovery(*ep)
+	}
+}
+
+// resetHopFailures clears the failure count after a successful direct ping
+func (s *Scheduler) resetHopFailures(id string) {
+	if s.tracer == nil {
+		return
+	}
+
+	s.hopMu.Lock()
+	delete(s.hopFailures, id)
+	s.hopMu.Unlock()
+}
+
+// forgetHop drops the hop discovery state of an endpoint
+func (s *Scheduler) forgetHop(id string) {
+	s.hopMu.Lock()
+	delete(s.hopPending, id)
+	delete(s.hopFailures, id)
+	delete(s.hopTraced, id)
+	s.hopMu.Unlock()
+}
+
+// scheduleHopRefresh queues re-discovery for hop-monitored endpoints whose
+// path has not been traced within the refresh interval
+func (s *Scheduler) scheduleHopRefresh(ep models.Endpoint) {
+	if s.tracer == nil || !ep.UseHop {
+		return
+	}
+
+	s.hopMu.Lock()
+	traced, ok := s.hopTraced[ep.ID]
+	if !ok {
+		// Hop was discovered before this process started; count from now
+		s.hopTraced[ep.ID] = time.Now()
+	}
+	s.hopMu.Unlock()
+
+	if ok && time.Since(traced) >= s.hopRefresh {
+		s.queueHopDiscovery(ep)
+	}
+}
+
+// queueHopDiscovery adds an endpoint to the traceroute queue unless it is
+// already pending. Drops the request if the queue is full.
+func (s *Scheduler) queueHopDiscovery(ep models.Endpoint) {
+	s.hopMu.Lock()
+	if s.hopPending[ep.ID] {
+		s.hopMu.Unlock()
+		return
+	}
+	s.hopPending[ep.ID] = true
+	s.hopMu.Unlock()
+
+	select {
+	case s.hopQueue <- ep:
+	default:
+		s.hopMu.Lock()
+		delete(s.hopPending, ep.ID)
+		s.hopMu.Unlock()
+	}
+}
+
+// hopDiscoveryLoop runs queued traceroutes one at a time. The Tracer reads
+// every ICMP reply on its socket, so concurrent traces would mix results.
+func (s *Scheduler) hopDiscoveryLoop(ctx context.Context) {
+	defer s.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case ep := <-s.hopQueue:
+			s.discoverHop(ep)
+
+			s.hopMu.Lock()
+			delete(s.hopPending, ep.ID)
+			s.hopFailures[ep.ID] = 0
+			s.hopTraced[ep.ID] = time.Now()
+			s.hopMu.Unlock()
+		}
+	}
+}
+
+// discoverHop traces the path to an endpoint and stores the last responding
+// upstream hop, or clears it if the endpoint answers directly. The endpoint
+// is reloaded around the trace, as its address may have changed since it
+// was queued.
+func (s *Scheduler) discoverHop(queued models.Endpoint) {
+	ep, ok := s.reloadForHop(queued.ID, "")
+	if !ok {
+		return
+	}
+	tracedIP := ep.IP
+	hopIP, hopNum, reached := s.tracer.FindLastRespondingHop(tracedIP)
+
+	// The path found belongs to the traced address only
+	if ep, ok = s.reloadForHop(queued.ID, tracedIP); !ok {
+		return
+	}
+
+	if reached && hopIP == ep.IP {
+		// Endpoint answers traceroute; monitor it directly again
+		if ep.UseHop {
+			s.setMonitoredHop(ep, "", 0)
+		}
+		return
+	}
+
+	if hopIP == "" || hopIP == ep.IP || !isUsableHop(hopIP) {
+		log.Printf("Hop discovery for %s found no usable upstream hop", ep.ID)
+		return
+	}
+
+	if ep.UseHop && ep.MonitoredHop == hopIP && ep.HopNumber == hopNum {
+		return
+	}
+
+	s.setMonitoredHop(ep, hopIP, hopNum)
+}
+
+// reloadForHop loads an endpoint for hop discovery. It fails if the
+// endpoint is gone or, when ip is set, no longer has that address.
+func (s *Scheduler) reloadForHop(id, ip string) (models.Endpoint, bool) {
+	ep, err := s.db.FindByID(id)
+	if err != nil {
+		log.Printf("Failed to load endpoint %s for hop discovery: %v", id, err)
+		return models.Endpoint{}, false
+	}
+	if ep == nil {
+		return models.Endpoint{}, false
+	}
+	if ip != "" && ep.IP != ip {
+		log.Printf("Address of %s changed during hop discovery; discarding the trace", id)
+		return models.Endpoint{}, false
+	}
+	return *ep, true
+}
+
+// setMonitoredHop persists a new monitored hop and records a hop_changed
+// event. The event message only mentions hop numbers, never addresses.
+func (s *Scheduler) setMonitoredHop(ep models.Endpoint, hopIP string, hopNum int) {
+	if err := s.db.UpdateMonitoredHop(ep.ID, hopIP, hopNum); err != nil {
+		log.Printf("Failed to update monitored hop for %s: %v", ep.ID, err)
+		return
+	}
+
+	var msg string
+	switch {
+	case hopIP == "":
+		msg = ep.ISP + " endpoint now monitored directly"
+	case !ep.UseHop:
+		msg = fmt.Sprintf("%s endpoint now monitored via hop %d", ep.ISP, hopNum)
+	default:
+		msg = fmt.Sprintf("%s endpoint monitored hop changed (hop %d -> hop %d)", ep.ISP, ep.HopNumber, hopNum)
+	}
+
+	log.Printf("Hop changed for %s: %s", ep.ID, msg)
+	s.recordEvent("hop_changed", ep.ISP, ep.ID, msg)
+}
+
+// isUsableHop rejects hops inside the monitoring server's own network,
+// which would report every endpoint as up
+func isUsableHop(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	return !parsed.IsPrivate() && !parsed.IsLoopback() && !parsed.IsLinkLocalUnicast() && !parsed.IsUnspecified()
+}
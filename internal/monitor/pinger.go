@@ -9,7 +9,11 @@ import (
 // PingResult contains the result of a ping attempt
 type PingResult struct {
 	Success  bool
-	RTT      time.Duration
+	RTT      time.Duration // Average round-trip time
+	MinRTT   time.Duration
+	MaxRTT   time.Duration
+	Jitter   time.Duration // Standard deviation of round-trip times
+	Loss     float64       // Packet loss percentage (0-100)
 	Error    error
 }
 
@@ -33,7 +37,7 @@ func NewPinger(timeout time.Duration, privileged bool) *Pinger {
 func (p *Pinger) Ping(ip string) PingResult {
 	pinger, err := probing.NewPinger(ip)
 	if err != nil {
-		return PingResult{Success: false, Error: err}
+		return PingResult{Success: false, Loss: 100, Error: err}
 	}
 
 	pinger.Count = p.count
@@ -42,7 +46,7 @@ func (p *Pinger) Ping(ip string) PingResult {
 
 	err = pinger.Run()
 	if err != nil {
-		return PingResult{Success: false, Error: err}
+		return PingResult{Success: false, Loss: 100, Error: err}
 	}
 
 	stats := pinger.Statistics()
@@ -53,6 +57,10 @@ func (p *Pinger) Ping(ip string) PingResult {
 	return PingResult{
 		Success: success,
 		RTT:     stats.AvgRtt,
+		MinRTT:  stats.MinRtt,
+		MaxRTT:  stats.MaxRtt,
+		Jitter:  stats.StdDevRtt,
+		Loss:    stats.PacketLoss,
 		Error:   nil,
 	}
 }
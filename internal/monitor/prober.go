@@ -0,0 +1,232 @@
+package monitor
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// Prober checks whether an address answers. ICMP ping is one
+// implementation; TCP, UDP and HTTP(S) probes reach endpoints whose CPE
+// drops echo requests.
+type Prober interface {
+	Probe(ip string) PingResult
+}
+
+// Probe implements Prober with ICMP echo requests
+func (p *Pinger) Probe(ip string) PingResult {
+	return p.Ping(ip)
+}
+
+// TCPProber connects to a TCP port. A refused connection (RST) proves the
+// host is up just as well as an accepted one.
+type TCPProber struct {
+	Port    int
+	Timeout time.Duration // Budget for all attempts
+	Count   int
+}
+
+// NewTCPProber creates a TCP prober that makes 3 connection attempts
+func NewTCPProber(port int, timeout time.Duration) *TCPProber {
+	return &TCPProber{Port: port, Timeout: timeout, Count: 3}
+}
+
+// Probe connects to the port Count times
+func (p *TCPProber) Probe(ip string) PingResult {
+	addr := net.JoinHostPort(ip, strconv.Itoa(p.Port))
+	return measure(p.Count, p.Timeout, func(deadline time.Time) error {
+		conn, err := net.DialTimeout("tcp", addr, time.Until(deadline))
+		if err != nil {
+			if errors.Is(err, syscall.ECONNREFUSED) {
+				return nil
+			}
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// UDPProber sends a datagram to a UDP port. A reply or an ICMP
+// port-unreachable proves the host is up; silence does not, since most
+// hosts drop datagrams to ports nothing listens on.
+type UDPProber struct {
+	Port    int
+	Payload []byte
+	Timeout time.Duration // Budget for all attempts
+	Count   int
+}
+
+// NewUDPProber creates a UDP prober that sends 3 empty datagrams
+func NewUDPProber(port int, timeout time.Duration) *UDPProber {
+	return &UDPProber{Port: port, Timeout: timeout, Count: 3}
+}
+
+// Probe sends Count datagrams, each waiting for its answer
+func (p *UDPProber) Probe(ip string) PingResult {
+	addr := net.JoinHostPort(ip, strconv.Itoa(p.Port))
+	return measure(p.Count, p.Timeout, func(deadline time.Time) error {
+		conn, err := net.DialTimeout("udp", addr, time.Until(deadline))
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		conn.SetDeadline(deadline)
+
+		if _, err := conn.Write(p.Payload); err != nil {
+			return err
+		}
+		buf := make([]byte, 1)
+		if _, err := conn.Read(buf); err != nil && !errors.Is(err, syscall.ECONNREFUSED) {
+			return err
+		}
+		return nil
+	})
+}
+
+// HTTPProber sends one GET request. Any response, whatever its status,
+// proves the host is up. Certificates are not verified, since CPE web
+// interfaces rarely have valid ones.
+type HTTPProber struct {
+	Scheme string // "http" or "https"
+	Port   int
+	Path   string
+	client *http.Client
+}
+
+// NewHTTPProber creates an HTTP(S) prober
+func NewHTTPProber(scheme string, port int, path string, timeout time.Duration) *HTTPProber {
+	return &HTTPProber{
+		Scheme: scheme,
+		Port:   port,
+		Path:   path,
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				DisableKeepAlives: true,
+			},
+			// A redirect is an answer; do not follow it elsewhere
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Probe requests the path once
+func (p *HTTPProber) Probe(ip string) PingResult {
+	url := p.Scheme + "://" + net.JoinHostPort(ip, strconv.Itoa(p.Port)) + p.Path
+	return measure(1, p.client.Timeout, func(time.Time) error {
+		resp, err := p.client.Get(url)
+		if err != nil {
+			return err
+		}
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		return resp.Body.Close()
+	})
+}
+
+// measure runs up to count attempts within timeout and summarises their
+// round-trip times like a ping. An attempt returning an error is lost.
+func measure(count int, timeout time.Duration, attempt func(deadline time.Time) error) PingResult {
+	deadline := time.Now().Add(timeout)
+	var rtts []time.Duration
+	var lastErr error
+
+	for i := 0; i < count; i++ {
+		if !time.Now().Before(deadline) {
+			break
+		}
+		start := time.Now()
+		if err := attempt(deadline); err != nil {
+			lastErr = err
+			continue
+		}
+		rtts = append(rtts, time.Since(start))
+	}
+
+	if len(rtts) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no response within %s", timeout)
+		}
+		return PingResult{Success: false, Loss: 100, Error: lastErr}
+	}
+
+	result := PingResult{
+		Success: true,
+		MinRTT:  rtts[0],
+		MaxRTT:  rtts[0],
+		Loss:    float64(count-len(rtts)) / float64(count) * 100,
+	}
+	var sum time.Duration
+	for _, rtt := range rtts {
+		sum += rtt
+		result.MinRTT = min(result.MinRTT, rtt)
+		result.MaxRTT = max(result.MaxRTT, rtt)
+	}
+	result.RTT = sum / time.Duration(len(rtts))
+
+	var variance float64
+	for _, rtt := range rtts {
+		d := float64(rtt - result.RTT)
+		variance += d * d
+	}
+	result.Jitter = time.Duration(math.Sqrt(variance / float64(len(rtts))))
+	return result
+}
+
+// Probers turns probe specs into probers, sharing the ICMP pinger and its
+// timeout
+type Probers struct {
+	pinger  *Pinger
+	timeout time.Duration
+}
+
+// NewProbers creates probers whose TCP, UDP and HTTP probes use the
+// pinger's timeout
+func NewProbers(pinger *Pinger) *Probers {
+	return &Probers{pinger: pinger, timeout: pinger.timeout}
+}
+
+// For returns the prober for one probe spec
+func (p *Probers) For(spec models.ProbeSpec) Prober {
+	switch spec.Method {
+	case models.ProbeTCP:
+		return NewTCPProber(spec.Port, p.timeout)
+	case models.ProbeUDP:
+		return NewUDPProber(spec.Port, p.timeout)
+	case models.ProbeHTTP, models.ProbeHTTPS:
+		return NewHTTPProber(spec.Method, spec.Port, spec.Path, p.timeout)
+	}
+	return p.pinger
+}
+
+// Run tries the probes of a strategy in order and stops at the first that
+// succeeds. If none does, the first probe's result is returned. The spec
+// that decided the result is returned with it.
+func (p *Probers) Run(strategy []models.ProbeSpec, ip string) (PingResult, models.ProbeSpec) {
+	if len(strategy) == 0 {
+		strategy = []models.ProbeSpec{{Method: models.ProbeICMP}}
+	}
+
+	var first PingResult
+	for i, spec := range strategy {
+		result := p.For(spec).Probe(ip)
+		if result.Success {
+			return result, spec
+		}
+		if i == 0 {
+			first = result
+		}
+	}
+	return first, strategy[0]
+}
@@ -0,0 +1,241 @@
+package monitor
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+const testProbeTimeout = 500 * time.Millisecond
+
+// closedTCPPort returns a loopback port nothing listens on
+func closedTCPPort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+// closedUDPPort returns a loopback UDP port nothing listens on
+func closedUDPPort(t *testing.T) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+	return port
+}
+
+// udpListener returns a loopback UDP port that echoes datagrams back if
+// echo is set, or silently drops them
+func udpListener(t *testing.T, echo bool) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if echo {
+				conn.WriteTo(buf[:n], addr)
+			}
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func serverPort(t *testing.T, srv *httptest.Server) int {
+	t.Helper()
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+func TestTCPProber(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	tests := []struct {
+		name string
+		port int
+	}{
+		{"open port", ln.Addr().(*net.TCPAddr).Port},
+		{"refused port", closedTCPPort(t)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NewTCPProber(tt.port, testProbeTimeout).Probe("127.0.0.1")
+			if !result.Success {
+				t.Fatalf("expected success, got %v", result.Error)
+			}
+			if result.Loss != 0 {
+				t.Errorf("loss = %v, want 0", result.Loss)
+			}
+			if result.MinRTT > result.RTT || result.RTT > result.MaxRTT {
+				t.Errorf("RTTs out of order: min %v avg %v max %v", result.MinRTT, result.RTT, result.MaxRTT)
+			}
+		})
+	}
+}
+
+func TestUDPProber(t *testing.T) {
+	tests := []struct {
+		name string
+		port int
+		up   bool
+	}{
+		{"closed port answers unreachable", closedUDPPort(t), true},
+		{"echo", udpListener(t, true), true},
+		{"silent port", udpListener(t, false), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NewUDPProber(tt.port, testProbeTimeout).Probe("127.0.0.1")
+			if result.Success != tt.up {
+				t.Fatalf("success = %v, want %v (error: %v)", result.Success, tt.up, result.Error)
+			}
+			if !tt.up && result.Loss != 100 {
+				t.Errorf("loss = %v, want 100", result.Loss)
+			}
+		})
+	}
+}
+
+func TestHTTPProberServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	result := NewHTTPProber("http", serverPort(t, srv), "/", testProbeTimeout).Probe("127.0.0.1")
+	if !result.Success {
+		t.Fatalf("a 500 response should count as up, got %v", result.Error)
+	}
+}
+
+func TestHTTPProberDoesNotFollowRedirects(t *testing.T) {
+	var followed atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/elsewhere", http.StatusFound)
+	})
+	mux.HandleFunc("/elsewhere", func(w http.ResponseWriter, r *http.Request) {
+		followed.Store(true)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	result := NewHTTPProber("http", serverPort(t, srv), "/", testProbeTimeout).Probe("127.0.0.1")
+	if !result.Success {
+		t.Fatalf("a redirect should count as up, got %v", result.Error)
+	}
+	if followed.Load() {
+		t.Error("redirect was followed")
+	}
+}
+
+func TestHTTPSProberSkipsVerification(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	result := NewHTTPProber("https", serverPort(t, srv), "/", testProbeTimeout).Probe("127.0.0.1")
+	if !result.Success {
+		t.Fatalf("self-signed HTTPS should count as up, got %v", result.Error)
+	}
+}
+
+func TestMeasureLoss(t *testing.T) {
+	attempts := 0
+	result := measure(4, time.Second, func(time.Time) error {
+		attempts++
+		if attempts%2 == 0 {
+			return net.ErrClosed
+		}
+		return nil
+	})
+	if !result.Success {
+		t.Fatal("expected success with half the attempts answered")
+	}
+	if result.Loss != 50 {
+		t.Errorf("loss = %v, want 50", result.Loss)
+	}
+}
+
+func TestProbersRunFallsThrough(t *testing.T) {
+	probers := NewProbers(NewPinger(testProbeTimeout, false))
+	silent := udpListener(t, false)
+	otherSilent := udpListener(t, false)
+	echo := udpListener(t, true)
+	refused := closedTCPPort(t)
+
+	tests := []struct {
+		name     string
+		strategy []models.ProbeSpec
+		up       bool
+		decided  models.ProbeSpec
+	}{
+		{
+			name:     "first probe answers",
+			strategy: []models.ProbeSpec{{Method: models.ProbeUDP, Port: echo}, {Method: models.ProbeUDP, Port: silent}},
+			up:       true,
+			decided:  models.ProbeSpec{Method: models.ProbeUDP, Port: echo},
+		},
+		{
+			name:     "falls through to a later probe",
+			strategy: []models.ProbeSpec{{Method: models.ProbeUDP, Port: silent}, {Method: models.ProbeTCP, Port: refused}},
+			up:       true,
+			decided:  models.ProbeSpec{Method: models.ProbeTCP, Port: refused},
+		},
+		{
+			name:     "none answers reports the first probe",
+			strategy: []models.ProbeSpec{{Method: models.ProbeUDP, Port: silent}, {Method: models.ProbeUDP, Port: otherSilent}},
+			up:       false,
+			decided:  models.ProbeSpec{Method: models.ProbeUDP, Port: silent},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, spec := probers.Run(tt.strategy, "127.0.0.1")
+			if result.Success != tt.up {
+				t.Fatalf("success = %v, want %v (error: %v)", result.Success, tt.up, result.Error)
+			}
+			if spec != tt.decided {
+				t.Errorf("decided by %v, want %v", spec, tt.decided)
+			}
+		})
+	}
+}
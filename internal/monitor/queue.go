@@ -0,0 +1,280 @@
+package monitor
+
+import (
+	"container/heap"
+	"context"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jonsson/ccc/internal/metrics"
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// DefaultProbeWorkers is the default number of endpoints probed at once
+const DefaultProbeWorkers = 50
+
+// DefaultRecheckInterval is the default first backoff for re-probing an
+// endpoint whose last probe failed
+const DefaultRecheckInterval = 5 * time.Second
+
+// intervalJitter is the share of the interval by which a healthy endpoint's
+// next probe is moved earlier or later, so probes do not drift into bursts
+const intervalJitter = 0.1
+
+// queueItem is one endpoint's place in the probe queue
+type queueItem struct {
+	id       string
+	due      time.Time
+	suspects int // Consecutive probes that left the endpoint suspect
+	index    int // Position in the heap, -1 while being probed
+}
+
+// probeQueue orders endpoints by when their next probe is due. Endpoints
+// being probed stay tracked but leave the heap until they are rescheduled.
+type probeQueue struct {
+	heap  queueHeap
+	items map[string]*queueItem
+}
+
+func newProbeQueue() *probeQueue {
+	return &probeQueue{items: make(map[string]*queueItem)}
+}
+
+// add starts tracking an endpoint, probing it at due. Returns false if it is
+// already tracked.
+func (q *probeQueue) add(id string, due time.Time) bool {
+	if _, ok := q.items[id]; ok {
+		return false
+	}
+	item := &queueItem{id: id, due: due}
+	q.items[id] = item
+	heap.Push(&q.heap, item)
+	return true
+}
+
+// remove stops tracking an endpoint
+func (q *probeQueue) remove(id string) {
+	item, ok := q.items[id]
+	if !ok {
+		return
+	}
+	delete(q.items, id)
+	if item.index >= 0 {
+		heap.Remove(&q.heap, item.index)
+	}
+}
+
+// popDue takes the endpoints whose probe is due out of the heap
+func (q *probeQueue) popDue(now time.Time) []*queueItem {
+	var due []*queueItem
+	for len(q.heap) > 0 && !q.heap[0].due.After(now) {
+		due = append(due, heap.Pop(&q.heap).(*queueItem))
+	}
+	return due
+}
+
+// reschedule puts a probed endpoint back into the heap. Returns false if it
+// stopped being tracked while it was probed.
+func (q *probeQueue) reschedule(item *queueItem, due time.Time) bool {
+	if q.items[item.id] != item {
+		return false
+	}
+	item.due = due
+	heap.Push(&q.heap, item)
+	return true
+}
+
+// next returns when the earliest probe is due, or false if none is queued
+func (q *probeQueue) next() (time.Time, bool) {
+	if len(q.heap) == 0 {
+		return time.Time{}, false
+	}
+	return q.heap[0].due, true
+}
+
+// queueHeap implements heap.Interface ordered by due time
+type queueHeap []*queueItem
+
+func (h queueHeap) Len() int           { return len(h) }
+func (h queueHeap) Less(i, j int) bool { return h[i].due.Before(h[j].due) }
+
+func (h queueHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *queueHeap) Push(x any) {
+	item := x.(*queueItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *queueHeap) Pop() any {
+	old := *h
+	item := old[len(old)-1]
+	old[len(old)-1] = nil
+	item.index = -1
+	*h = old[:len(old)-1]
+	return item
+}
+
+// syncQueue matches the probe queue to the active endpoints. On the first
+// sync endpoints are spread at random across one interval; endpoints that
+// appear later (new registrations, ended pauses) are probed right away.
+func (s *Scheduler) syncQueue(endpoints []models.Endpoint) {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	now := time.Now()
+	active := make(map[string]bool, len(endpoints))
+	added := false
+	for _, ep := range endpoints {
+		active[ep.ID] = true
+		due := now
+		if !s.queueSynced {
+			due = now.Add(time.Duration(rand.Int64N(int64(s.pingInterval))))
+		}
+		if s.queue.add(ep.ID, due) {
+			added = true
+		}
+	}
+	for id := range s.queue.items {
+		if !active[id] {
+			s.queue.remove(id)
+		}
+	}
+	s.queueSynced = true
+
+	if added {
+		s.wakeDispatcher()
+	}
+}
+
+// wakeDispatcher makes the dispatcher look at the queue again
+func (s *Scheduler) wakeDispatcher() {
+	select {
+	case s.queueWake <- struct{}{}:
+	default:
+	}
+}
+
+// probeLoop hands due endpoints to a pool of probe workers. Each worker
+// applies its result as soon as the probe finishes and reschedules the
+// endpoint: healthy ones one jittered interval later, suspect ones after a
+// short backoff.
+func (s *Scheduler) probeLoop(ctx context.Context) {
+	defer s.wg.Done()
+
+	jobs := make(chan *queueItem)
+	for i := 0; i < s.workers; i++ {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			for item := range jobs {
+				s.probe(item)
+			}
+		}()
+	}
+	defer close(jobs)
+
+	timer := time.NewTimer(s.pingInterval)
+	defer timer.Stop()
+
+	for {
+		s.queueMu.Lock()
+		now := time.Now()
+		due := s.queue.popDue(now)
+		next, queued := s.queue.next()
+		s.queueMu.Unlock()
+
+		for _, item := range due {
+			select {
+			case jobs <- item:
+			case <-ctx.Done():
+				return
+			case <-s.stopCh:
+				return
+			}
+		}
+		if len(due) > 0 {
+			// Dispatching may have taken a while; look at the queue again
+			continue
+		}
+
+		wait := s.pingInterval
+		if queued {
+			wait = next.Sub(now)
+		}
+		timer.Reset(wait)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-s.queueWake:
+		case <-timer.C:
+		}
+	}
+}
+
+// probe checks one endpoint, applies the result and reschedules it
+func (s *Scheduler) probe(item *queueItem) {
+	delay := s.pingInterval
+
+	ep, err := s.db.FindByID(item.id)
+	if err != nil {
+		log.Printf("Failed to load endpoint %s for probing: %v", item.id, err)
+	} else if ep == nil || ep.IsPaused(time.Now()) {
+		// Deleted or paused since the last sync
+		s.queueMu.Lock()
+		s.queue.remove(item.id)
+		s.queueMu.Unlock()
+		return
+	} else if result, ok := s.monitorEndpoint(ep); ok {
+		s.applyResult(result)
+		if suspect(result) {
+			item.suspects++
+			delay = s.recheckDelay(item.suspects)
+			metrics.ProbeRechecks.Inc()
+		} else {
+			item.suspects = 0
+			delay = s.jitteredInterval()
+		}
+	}
+
+	s.queueMu.Lock()
+	rescheduled := s.queue.reschedule(item, time.Now().Add(delay))
+	s.queueMu.Unlock()
+	if rescheduled {
+		s.wakeDispatcher()
+	}
+}
+
+// suspect reports whether an endpoint's status is not yet settled: its
+// probe failed, or it answered but has not recovered yet
+func suspect(result pingResult) bool {
+	return !result.probe.Success || result.newStatus == models.StatusDown
+}
+
+// recheckDelay doubles the recheck interval for each consecutive suspect
+// probe, capped at the base interval
+func (s *Scheduler) recheckDelay(suspects int) time.Duration {
+	delay := s.recheck
+	for i := 1; i < suspects && delay < s.pingInterval; i++ {
+		delay *= 2
+	}
+	return min(delay, s.pingInterval)
+}
+
+// jitteredInterval returns the base interval moved by up to intervalJitter
+// either way
+func (s *Scheduler) jitteredInterval() time.Duration {
+	spread := int64(float64(s.pingInterval) * intervalJitter)
+	if spread <= 0 {
+		return s.pingInterval
+	}
+	return s.pingInterval + time.Duration(rand.Int64N(2*spread+1)-spread)
+}
@@ -2,26 +2,71 @@ package monitor
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"sync"
 	"time"
 
+	"github.com/jonsson/ccc/internal/metrics"
 	"github.com/jonsson/ccc/internal/models"
 	"github.com/jonsson/ccc/internal/storage"
+	"github.com/jonsson/ccc/internal/stream"
 )
 
+// probeRetention is how long raw per-probe measurements are kept
+const probeRetention = 30 * 24 * time.Hour
+
+// deliveryRetention is how long the notification delivery log is kept
+const deliveryRetention = 30 * 24 * time.Hour
+
 // Scheduler manages periodic monitoring tasks
 type Scheduler struct {
 	db           *storage.DB
 	pinger       *Pinger
+	probers      *Probers
+	strategy     []models.ProbeSpec // Default probe strategy
 	pingInterval time.Duration
+	workers      int           // Endpoints probed at once
+	recheck      time.Duration // First backoff for re-probing a suspect endpoint
 	expireDays   int
 	stopCh       chan struct{}
 	wg           sync.WaitGroup
+	status       *StatusTracker
+	notifier     IncidentNotifier
+	broker       *stream.Broker
+	dashboard    func() (models.DashboardResponse, error)
+
+	// Hop discovery (traceroute fallback for endpoints that drop ICMP)
+	tracer      *Tracer
+	hopRefresh  time.Duration
+	hopQueue    chan models.Endpoint
+	hopMu       sync.Mutex
+	hopPending  map[string]bool      // endpoint ID -> queued for discovery
+	hopTraced   map[string]time.Time // endpoint ID -> last discovery time
+	hopFailures map[string]int       // endpoint ID -> consecutive failed pings
+
+	// Probe queue (endpoints ordered by when their next probe is due)
+	queueMu     sync.Mutex
+	queue       *probeQueue
+	queueSynced bool
+	queueWake   chan struct{}
+	analyzeCh   chan struct{} // Signalled when an endpoint changes status
+
+	// Last reference target check, shared by the cycle and the probe workers
+	vantageMu      sync.Mutex
+	vantageChecked time.Time
+
+	// Rotating database backups (disabled if backupDir is empty)
+	backupDir      string
+	backupInterval time.Duration
+	backupKeep     int
 
 	// Outage analysis results (updated after each ping cycle)
-	outagesMu sync.RWMutex
-	outages   map[string]bool // ISP -> likely outage
+	outagesMu      sync.RWMutex
+	outages        map[string]bool                       // ISP -> likely outage
+	outageStreaks  map[string]int                        // ISP -> consecutive cycles the outage condition held
+	monitorOffline bool                                  // No reference target answered in the last cycle
+	disagreements  map[string]models.VantageDisagreement // ISP and endpoint ID -> vantage points at odds
 
 	// Last ping cycle timestamp
 	lastPingMu   sync.RWMutex
@@ -38,26 +83,122 @@ func NewScheduler(db *storage.DB, pinger *Pinger, pingInterval time.Duration, ex
 	return &Scheduler{
 		db:           db,
 		pinger:       pinger,
+		probers:      NewProbers(pinger),
+		strategy:     []models.ProbeSpec{{Method: models.ProbeICMP}},
 		pingInterval: pingInterval,
+		workers:      DefaultProbeWorkers,
+		recheck:      DefaultRecheckInterval,
 		expireDays:   expireDays,
 		stopCh:       make(chan struct{}),
 		startTime:    time.Now(),
+		status:       NewStatusTracker(DefaultStatusThresholds()),
+		hopRefresh:   defaultHopRefresh,
+		hopQueue:     make(chan models.Endpoint, hopQueueSize),
+		hopPending:   make(map[string]bool),
+		hopTraced:    make(map[string]time.Time),
+		hopFailures:  make(map[string]int),
+		queue:        newProbeQueue(),
+		queueWake:    make(chan struct{}, 1),
+		analyzeCh:    make(chan struct{}, 1),
+
+		outageStreaks: make(map[string]int),
+	}
+}
+
+// SetStatusThresholds configures how many consecutive probes must agree
+// before an endpoint changes status
+func (s *Scheduler) SetStatusThresholds(t StatusThresholds) {
+	s.status = NewStatusTracker(t)
+}
+
+// SetProbeStrategy sets the probes tried, in order, for endpoints without a
+// strategy of their own
+func (s *Scheduler) SetProbeStrategy(strategy []models.ProbeSpec) {
+	if len(strategy) > 0 {
+		s.strategy = strategy
+	}
+}
+
+// SetProbeWorkers sets how many endpoints are probed at once
+func (s *Scheduler) SetProbeWorkers(n int) {
+	if n > 0 {
+		s.workers = n
+	}
+}
+
+// SetRecheckInterval sets how soon an endpoint whose probe failed is probed
+// again. The delay doubles while it keeps failing, up to the ping interval.
+func (s *Scheduler) SetRecheckInterval(d time.Duration) {
+	if d > 0 {
+		s.recheck = d
 	}
 }
 
+// IncidentNotifier is told when ISP incidents open and close
+type IncidentNotifier interface {
+	IncidentOpened(inc models.Incident)
+	IncidentClosed(inc models.Incident)
+}
+
+// SetNotifier sets the receiver for incident open/close alerts
+func (s *Scheduler) SetNotifier(n IncidentNotifier) {
+	s.notifier = n
+}
+
+// SetBroker enables live updates: recorded events are published as they
+// happen, and a dashboard snapshot from the given builder after each cycle
+func (s *Scheduler) SetBroker(b *stream.Broker, dashboard func() (models.DashboardResponse, error)) {
+	s.broker = b
+	s.dashboard = dashboard
+}
+
+// SetTracer enables hop discovery. Endpoints that do not answer ping are
+// traced and their last responding upstream hop is monitored instead.
+// A refresh of 0 keeps the default re-discovery interval.
+func (s *Scheduler) SetTracer(t *Tracer, refresh time.Duration) {
+	s.tracer = t
+	if refresh > 0 {
+		s.hopRefresh = refresh
+	}
+}
+
+// SetBackups enables rotating database backups into dir every interval,
+// keeping the newest keep files
+func (s *Scheduler) SetBackups(dir string, interval time.Duration, keep int) {
+	s.backupDir = dir
+	s.backupInterval = interval
+	s.backupKeep = keep
+}
+
 // Start begins the monitoring loops
 func (s *Scheduler) Start(ctx context.Context) {
-	log.Printf("Starting monitoring scheduler (interval: %s, expire: %d days)",
-		s.pingInterval, s.expireDays)
+	log.Printf("Starting monitoring scheduler (interval: %s, recheck: %s, workers: %d, expire: %d days)",
+		s.pingInterval, s.recheck, s.workers, s.expireDays)
 
-	// Start ping loop
+	// Start ping loop (per-cycle aggregation and outage analysis)
 	s.wg.Add(1)
 	go s.pingLoop(ctx)
 
+	// Start probe loop (endpoints probed as they come due)
+	s.wg.Add(1)
+	go s.probeLoop(ctx)
+
 	// Start cleanup loop (runs daily)
 	s.wg.Add(1)
 	go s.cleanupLoop(ctx)
 
+	// Start hop discovery loop (traceroutes run one at a time)
+	if s.tracer != nil {
+		s.wg.Add(1)
+		go s.hopDiscoveryLoop(ctx)
+	}
+
+	// Start backup loop
+	if s.backupDir != "" && s.backupInterval > 0 {
+		s.wg.Add(1)
+		go s.backupLoop(ctx)
+	}
+
 	// Run initial cleanup
 	s.runCleanup()
 }
@@ -86,108 +227,87 @@ func (s *Scheduler) pingLoop(ctx context.Context) {
 			return
 		case <-ticker.C:
 			s.runPingCycle()
+		case <-s.analyzeCh:
+			// Status changes confirm outages without waiting for the cycle
+			s.analyzeOutages(false)
+		}
+	}
+}
+
+// activeEndpoints drops endpoints whose resident has paused monitoring
+func activeEndpoints(endpoints []models.Endpoint) []models.Endpoint {
+	now := time.Now()
+	active := endpoints[:0]
+	for _, ep := range endpoints {
+		if !ep.IsPaused(now) {
+			active = append(active, ep)
 		}
 	}
+	return active
 }
 
 // pingResult holds the result of pinging an endpoint
 type pingResult struct {
 	endpoint  models.Endpoint
-	oldStatus string
-	newStatus string
+	oldStatus models.EndpointStatus
+	newStatus models.EndpointStatus
 	lastOK    time.Time
+	probe     PingResult // Measurement that decided the new status
+	method    string     // Probe spec of that measurement
+	viaHop    bool       // True if probe was sent to the monitored hop
 }
 
+// runPingCycle runs once per ping interval. Endpoints are probed by the
+// probe loop as they come due; the cycle checks the server's own
+// connectivity, picks up new and removed endpoints, records the uptime
+// snapshot and analyzes ISP outages.
 func (s *Scheduler) runPingCycle() {
+	// When the server itself is offline every endpoint would look down, so
+	// statuses and outage analysis are left as they were until it recovers
+	if s.vantageOffline(0) {
+		log.Printf("No reference target answers; skipping ping cycle")
+		s.publishDashboard()
+		return
+	}
+
 	endpoints, err := s.db.ListAll()
 	if err != nil {
 		log.Printf("Failed to list endpoints for ping cycle: %v", err)
 		return
 	}
+	endpoints = activeEndpoints(endpoints)
+	s.syncQueue(endpoints)
 
 	if len(endpoints) == 0 {
 		return
 	}
 
-	log.Printf("Starting ping cycle for %d endpoints (parallel)", len(endpoints))
-
-	// Use worker pool for parallel pinging
-	numWorkers := 50 // Concurrent ping workers
-	if numWorkers > len(endpoints) {
-		numWorkers = len(endpoints)
-	}
-
-	jobs := make(chan models.Endpoint, len(endpoints))
-	results := make(chan pingResult, len(endpoints))
-
-	// Start workers
-	var workerWg sync.WaitGroup
-	for i := 0; i < numWorkers; i++ {
-		workerWg.Add(1)
-		go func() {
-			defer workerWg.Done()
-			for ep := range jobs {
-				oldStatus := ep.Status
-				status, lastOK := s.monitorEndpoint(&ep)
-				results <- pingResult{
-					endpoint:  ep,
-					oldStatus: oldStatus,
-					newStatus: status,
-					lastOK:    lastOK,
-				}
-			}
-		}()
-	}
+	cycleStart := time.Now()
 
-	// Send jobs
+	overall := uptimeCounts{}
+	ispCounts := make(map[string]*uptimeCounts)
+	seen := make(map[string]bool, len(endpoints))
 	for _, ep := range endpoints {
-		jobs <- ep
-	}
-	close(jobs)
-
-	// Wait for workers to finish, then close results
-	go func() {
-		workerWg.Wait()
-		close(results)
-	}()
+		seen[ep.ID] = true
 
-	// Collect results
-	var upCount, downCount int
-	for result := range results {
-		if result.newStatus == "up" {
-			upCount++
-		} else {
-			downCount++
+		counts, ok := ispCounts[ep.ISP]
+		if !ok {
+			counts = &uptimeCounts{}
+			ispCounts[ep.ISP] = counts
 		}
+		overall.add(ep.Status)
+		counts.add(ep.Status)
+	}
 
-		// Record status change events
-		if result.oldStatus != result.newStatus && result.oldStatus != "unknown" {
-			if result.newStatus == "down" {
-				msg := result.endpoint.ISP + " endpoint went down"
-				if err := s.db.RecordEvent("down", result.endpoint.ISP, result.endpoint.ID, msg); err != nil {
-					log.Printf("Failed to record down event: %v", err)
-				}
-			} else if result.newStatus == "up" && result.oldStatus == "down" {
-				msg := result.endpoint.ISP + " endpoint recovered"
-				if err := s.db.RecordEvent("up", result.endpoint.ISP, result.endpoint.ID, msg); err != nil {
-					log.Printf("Failed to record up event: %v", err)
-				}
-			}
-		}
+	s.status.Prune(seen)
 
-		if err := s.db.UpdateStatus(result.endpoint.ID, result.newStatus, result.lastOK); err != nil {
-			log.Printf("Failed to update status for %s: %v", result.endpoint.ID, err)
-		}
+	log.Printf("Ping cycle: %d endpoints, %d up, %d down", overall.total, overall.up, overall.down)
 
-		// Update last_seen when endpoint responds to ping (prevents expiration)
-		if result.newStatus == "up" {
-			if err := s.db.UpdateLastSeen(result.endpoint.ID); err != nil {
-				log.Printf("Failed to update last_seen for %s: %v", result.endpoint.ID, err)
-			}
-		}
-	}
+	s.recordUptimeHistory(overall, ispCounts)
 
-	log.Printf("Ping cycle complete: %d up, %d down", upCount, downCount)
+	if err := s.db.RecordAvailability(cycleStart, s.pingInterval.Seconds(), endpoints); err != nil {
+		log.Printf("Failed to record availability: %v", err)
+	}
 
 	// Record ping cycle completion time and increment counter
 	s.lastPingMu.Lock()
@@ -205,29 +325,136 @@ func (s *Scheduler) runPingCycle() {
 		log.Printf("Cleaned up %d old events", deleted)
 	}
 
-	// Analyze for ISP-level outages
-	oldOutages := s.outages
-	outages := s.analyzeISPOutages()
+	s.analyzeOutages(true)
 
-	// Record outage/recovery events
-	for isp, isOutage := range outages {
-		wasOutage := oldOutages[isp]
-		if isOutage && !wasOutage {
-			msg := isp + " ISP outage detected"
-			if err := s.db.RecordEvent("outage", isp, "", msg); err != nil {
-				log.Printf("Failed to record outage event: %v", err)
-			}
-		} else if !isOutage && wasOutage {
-			msg := isp + " ISP recovered from outage"
-			if err := s.db.RecordEvent("recovery", isp, "", msg); err != nil {
-				log.Printf("Failed to record recovery event: %v", err)
-			}
-		}
+	metrics.PingCycles.Inc()
+	metrics.PingCycleDuration.Observe(time.Since(cycleStart).Seconds())
+}
+
+// analyzeOutages applies the outage policies to the current endpoint
+// statuses, updates incidents and publishes the dashboard. Only the ping
+// cycle advances the persist_cycles count.
+func (s *Scheduler) analyzeOutages(cycle bool) {
+	if s.MonitorOffline() {
+		return
 	}
 
+	detected := s.analyzeISPOutages(cycle)
+	s.trackIncidents(detected)
+
+	outages := make(map[string]bool, len(detected))
+	for isp := range detected {
+		outages[isp] = true
+	}
 	s.outagesMu.Lock()
 	s.outages = outages
 	s.outagesMu.Unlock()
+
+	s.publishDashboard()
+}
+
+// applyResult stores one endpoint's probe outcome. A status change records
+// an event and triggers outage analysis right away.
+func (s *Scheduler) applyResult(result pingResult) {
+	// Record status change events
+	if result.oldStatus != result.newStatus && result.oldStatus != models.StatusUnknown {
+		if result.newStatus == models.StatusDown {
+			msg := result.endpoint.ISP + " endpoint went down"
+			s.recordEvent("down", result.endpoint.ISP, result.endpoint.ID, msg)
+			if err := s.db.RecordEndpointFailure(time.Now(), result.endpoint); err != nil {
+				log.Printf("Failed to record failure for %s: %v", result.endpoint.ID, err)
+			}
+		} else if result.newStatus.IsReachable() && result.oldStatus == models.StatusDown {
+			msg := result.endpoint.ISP + " endpoint recovered"
+			s.recordEvent("up", result.endpoint.ISP, result.endpoint.ID, msg)
+		}
+	}
+
+	if err := s.db.UpdateStatus(result.endpoint.ID, result.newStatus, result.lastOK); err != nil {
+		log.Printf("Failed to update status for %s: %v", result.endpoint.ID, err)
+	}
+
+	// Update last_seen when endpoint responds to ping (prevents expiration)
+	if !result.lastOK.IsZero() {
+		if err := s.db.UpdateLastSeen(result.endpoint.ID); err != nil {
+			log.Printf("Failed to update last_seen for %s: %v", result.endpoint.ID, err)
+		}
+	}
+
+	s.recordProbe(result)
+
+	// Periodically re-discover the hop in case the path changed
+	s.scheduleHopRefresh(result.endpoint)
+
+	if result.oldStatus != result.newStatus {
+		select {
+		case s.analyzeCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// recordEvent stores an event and publishes it to live subscribers
+func (s *Scheduler) recordEvent(eventType, isp, endpointID, message string) {
+	event, err := s.db.RecordEvent(eventType, isp, endpointID, message)
+	if err != nil {
+		log.Printf("Failed to record %s event: %v", eventType, err)
+		return
+	}
+
+	if s.broker != nil {
+		if err := s.broker.Publish(stream.TypeEvent, event); err != nil {
+			log.Printf("Failed to publish event: %v", err)
+		}
+	}
+}
+
+// publishDashboard pushes a fresh dashboard snapshot to live subscribers
+func (s *Scheduler) publishDashboard() {
+	if s.broker == nil || s.dashboard == nil {
+		return
+	}
+
+	snapshot, err := s.dashboard()
+	if err != nil {
+		log.Printf("Failed to build dashboard snapshot: %v", err)
+		return
+	}
+	if err := s.broker.Publish(stream.TypeDashboard, snapshot); err != nil {
+		log.Printf("Failed to publish dashboard snapshot: %v", err)
+	}
+}
+
+// uptimeCounts tallies endpoint statuses for one ping cycle.
+// Degraded endpoints are reachable, so they count as up.
+type uptimeCounts struct {
+	total, up, down int
+}
+
+func (c *uptimeCounts) add(status models.EndpointStatus) {
+	c.total++
+	if status.IsReachable() {
+		c.up++
+	} else if status == models.StatusDown {
+		c.down++
+	}
+}
+
+// recordUptimeHistory stores this cycle's overall and per-ISP snapshots,
+// then compacts any completed buckets into the rollup tiers
+func (s *Scheduler) recordUptimeHistory(overall uptimeCounts, ispCounts map[string]*uptimeCounts) {
+	if err := s.db.RecordUptimeSnapshot("", overall.total, overall.up, overall.down); err != nil {
+		log.Printf("Failed to record uptime snapshot: %v", err)
+	}
+	for isp, counts := range ispCounts {
+		if err := s.db.RecordUptimeSnapshot(isp, counts.total, counts.up, counts.down); err != nil {
+			log.Printf("Failed to record uptime snapshot for %s: %v", isp, err)
+		}
+	}
+
+	if err := s.db.RollupUptimeHistory(time.Now()); err != nil {
+		log.Printf("Failed to roll up uptime history: %v", err)
+	}
 }
 
 // LastPingTime returns the time of the last completed ping cycle
@@ -264,6 +491,14 @@ func (s *Scheduler) StartTime() time.Time {
 	return s.startTime
 }
 
+// ForgetEndpoint drops the failure streak and hop discovery state kept
+// for an endpoint, e.g. after its address changed, so probes of the new
+// address are judged on their own
+func (s *Scheduler) ForgetEndpoint(id string) {
+	s.status.Forget(id)
+	s.forgetHop(id)
+}
+
 // IsISPOutage returns true if the specified ISP is likely experiencing an outage
 func (s *Scheduler) IsISPOutage(isp string) bool {
 	s.outagesMu.RLock()
@@ -283,92 +518,311 @@ func (s *Scheduler) HasAnyOutage() bool {
 	return false
 }
 
-// monitorEndpoint monitors a single endpoint via direct ping
-func (s *Scheduler) monitorEndpoint(ep *models.Endpoint) (status string, lastOK time.Time) {
-	result := s.pinger.Ping(ep.IPv4)
+// endpointStrategy returns the probes to try for an endpoint
+func (s *Scheduler) endpointStrategy(ep *models.Endpoint) []models.ProbeSpec {
+	if ep.ProbeStrategy == "" {
+		return s.strategy
+	}
+	strategy, err := models.ParseProbeStrategy(ep.ProbeStrategy)
+	if err != nil || len(strategy) == 0 {
+		log.Printf("Invalid probe strategy %q for %s, using default: %v", ep.ProbeStrategy, ep.ID, err)
+		return s.strategy
+	}
+	return strategy
+}
+
+// monitorEndpoint probes a single endpoint with its probe strategy, falling
+// back to pinging its monitored hop when the endpoint itself does not answer.
+// Returns false if nothing answered because the server itself is offline.
+func (s *Scheduler) monitorEndpoint(ep *models.Endpoint) (pingResult, bool) {
+	pr := pingResult{endpoint: *ep, oldStatus: ep.Status}
 
-	if result.Success {
-		return "up", time.Now()
+	strategy := s.endpointStrategy(ep)
+	result, spec := s.probers.Run(strategy, ep.IP)
+	pr.probe, pr.method = result, spec.String()
+
+	// Dual-stack residents count as up if either address family answers
+	if !result.Success && ep.SecondaryIP != "" {
+		if secondary, spec := s.probers.Run(strategy, ep.SecondaryIP); secondary.Success {
+			pr.probe, pr.method = secondary, spec.String()
+		}
 	}
 
-	// Ping failed - mark as unreachable (user can still view dashboard)
+	if pr.probe.Success {
+		s.resetHopFailures(ep.ID)
+		return s.settle(pr), true
+	}
+
+	// Endpoint drops ICMP - judge it by its last responding upstream hop
+	if ep.UseHop && ep.MonitoredHop != "" {
+		hopResult := s.pinger.Ping(ep.MonitoredHop)
+		pr.probe, pr.method, pr.viaHop = hopResult, models.ProbeICMP, true
+		if hopResult.Success {
+			return s.settle(pr), true
+		}
+	}
+
+	// A failure only counts if the reference targets still answer
+	if s.vantageOffline(s.recheck) {
+		return pr, false
+	}
+
+	// Ping failed (user can still view dashboard)
 	if result.Error != nil {
 		log.Printf("Ping failed for %s (%s): %v", ep.ID, ep.ISP, result.Error)
 	}
 
-	return "unreachable", time.Time{}
+	if !ep.UseHop {
+		s.noteHopFailure(ep)
+	}
+
+	return s.settle(pr), true
+}
+
+// settle runs the probe outcome through the flap-damping state machine
+func (s *Scheduler) settle(pr pingResult) pingResult {
+	pr.newStatus = s.status.Observe(pr.endpoint.ID, pr.oldStatus, pr.probe)
+	if pr.probe.Success {
+		pr.lastOK = time.Now()
+	}
+	return pr
+}
+
+// recordProbe persists the RTT and packet loss measured for an endpoint
+func (s *Scheduler) recordProbe(result pingResult) {
+	probe := models.ProbeResult{
+		EndpointID: result.endpoint.ID,
+		ISP:        result.endpoint.ISP,
+		Timestamp:  time.Now(),
+		Success:    result.probe.Success,
+		ViaHop:     result.viaHop,
+		MinRTTMs:   durationMs(result.probe.MinRTT),
+		AvgRTTMs:   durationMs(result.probe.RTT),
+		MaxRTTMs:   durationMs(result.probe.MaxRTT),
+		JitterMs:   durationMs(result.probe.Jitter),
+		LossPct:    result.probe.Loss,
+		Method:     result.method,
+	}
+	if err := s.db.RecordProbeResult(probe); err != nil {
+		log.Printf("Failed to record probe result for %s: %v", result.endpoint.ID, err)
+	}
+
+	if result.probe.Success {
+		metrics.ProbesTotal.Inc(probe.ISP, "success")
+		metrics.ProbeRTT.Observe(result.probe.RTT.Seconds(), probe.ISP)
+	} else {
+		metrics.ProbesTotal.Inc(probe.ISP, "failure")
+	}
 }
 
-// analyzeISPOutages checks for common hop failures across endpoints from the same ISP
-// Returns a map of ISP -> likely outage (true if multiple endpoints share a failing hop)
-func (s *Scheduler) analyzeISPOutages() map[string]bool {
+// durationMs converts a duration to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// outageDetection describes why an ISP is considered to be in an outage
+type outageDetection struct {
+	method    string // models.DetectionThreshold or models.DetectionSharedHop
+	sharedHop string // Failing hop, for shared-hop detections
+	hopDown   int    // Endpoints down behind the failing hop
+	affected  int    // Endpoints currently down
+	total     int    // Endpoints on the ISP
+}
+
+// analyzeISPOutages applies each ISP's outage policy to the endpoint
+// statuses. Returns a map of ISP -> detection for ISPs that are likely in an
+// outage. A condition must hold for the policy's number of consecutive
+// cycles before it counts, unless the ISP already has an open incident.
+// Analyses between cycles count as the next cycle but do not advance it.
+// Endpoint statuses reported by probe agents are merged in first.
+func (s *Scheduler) analyzeISPOutages(cycle bool) map[string]outageDetection {
 	endpoints, err := s.db.ListAll()
 	if err != nil {
 		log.Printf("Failed to analyze ISP outages: %v", err)
 		return nil
 	}
+	policies, err := s.db.GetOutagePolicies()
+	if err != nil {
+		log.Printf("Failed to load outage policies, using defaults: %v", err)
+	}
+	open, err := s.db.GetOpenIncidents()
+	if err != nil {
+		log.Printf("Failed to load open incidents: %v", err)
+	}
 
 	// Group endpoints by ISP
 	byISP := make(map[string][]models.Endpoint)
-	for _, ep := range endpoints {
+	for _, ep := range activeEndpoints(endpoints) {
 		byISP[ep.ISP] = append(byISP[ep.ISP], ep)
 	}
 
-	outages := make(map[string]bool)
+	observations := s.agentObservations()
+	var disagreements []models.VantageDisagreement
+
+	outages := make(map[string]outageDetection)
+	streaks := make(map[string]int)
 
 	for isp, eps := range byISP {
-		if len(eps) < 2 {
-			// Need at least 2 endpoints to compare
+		policy := policies.For(isp)
+		if len(observations) > 0 {
+			if d := compareOutageViews(isp, vantageViews(eps, observations), policy); d != nil {
+				disagreements = append(disagreements, *d)
+			}
+			var split []models.VantageDisagreement
+			eps, split = mergeVantages(eps, observations)
+			disagreements = append(disagreements, split...)
+		}
+
+		d, ok := detectOutage(eps, policy)
+		if !ok {
 			continue
 		}
 
-		// Count how many are down and using hops
-		downCount := 0
-		hopDownCount := 0
-		sharedHops := make(map[string]int) // hop IP -> count of endpoints using it
-
-		for _, ep := range eps {
-			if ep.Status == "down" {
-				downCount++
-				if ep.UseHop && ep.MonitoredHop != "" {
-					hopDownCount++
-					sharedHops[ep.MonitoredHop]++
-				}
-			}
-			// Also track all shared hops (even for up endpoints)
-			if ep.UseHop && ep.MonitoredHop != "" {
-				sharedHops[ep.MonitoredHop]++
+		streaks[isp] = s.outageStreaks[isp] + 1
+		if _, isOpen := open[isp]; !isOpen && streaks[isp] < policy.PersistCycles {
+			if cycle {
+				log.Printf("Possible %s outage (%s), cycle %d of %d", isp, d.method, streaks[isp], policy.PersistCycles)
 			}
+			continue
 		}
 
-		// Heuristic: If >50% of endpoints are down, likely ISP outage
-		if float64(downCount)/float64(len(eps)) > 0.5 {
-			outages[isp] = true
-			log.Printf("Likely %s outage: %d/%d endpoints down", isp, downCount, len(eps))
+		outages[isp] = d
+		if !cycle {
 			continue
 		}
+		if d.method == models.DetectionSharedHop {
+			log.Printf("Likely %s outage: shared hop %s down for %d endpoints", isp, d.sharedHop, d.hopDown)
+		} else {
+			log.Printf("Likely %s outage: %d/%d endpoints down", isp, d.affected, d.total)
+		}
+	}
 
-		// Check if multiple endpoints share the same failing hop
-		for hop, count := range sharedHops {
-			if count >= 2 {
-				// Check if this shared hop is down for all users
-				hopEndpoints, _ := s.db.GetEndpointsByMonitoredHop(hop)
-				allDown := true
-				for _, he := range hopEndpoints {
-					if he.Status == "up" {
-						allDown = false
-						break
-					}
-				}
-				if allDown && len(hopEndpoints) >= 2 {
-					outages[isp] = true
-					log.Printf("Likely %s outage: shared hop %s down for %d endpoints", isp, hop, count)
+	s.trackDisagreements(disagreements)
+
+	// ISPs whose condition cleared start counting again from zero
+	if cycle {
+		s.outageStreaks = streaks
+	}
+	return outages
+}
+
+// detectOutage checks one ISP's endpoints against its policy: either the
+// share of down endpoints exceeds the threshold, or enough endpoints behind
+// one upstream hop are all down
+func detectOutage(eps []models.Endpoint, policy models.OutagePolicy) (outageDetection, bool) {
+	if len(eps) < policy.MinEndpoints {
+		return outageDetection{}, false
+	}
+
+	downCount := 0
+	hopTotal := make(map[string]int) // hop IP -> endpoints monitored through it
+	hopDown := make(map[string]int)  // hop IP -> of which down
+	for _, ep := range eps {
+		hop := ""
+		if ep.UseHop && ep.MonitoredHop != "" {
+			hop = ep.MonitoredHop
+			hopTotal[hop]++
+		}
+		if ep.Status == models.StatusDown {
+			downCount++
+			if hop != "" {
+				hopDown[hop]++
+			}
+		}
+	}
+
+	if float64(downCount)/float64(len(eps)) > policy.DownThreshold {
+		return outageDetection{
+			method:   models.DetectionThreshold,
+			affected: downCount,
+			total:    len(eps),
+		}, true
+	}
+
+	// Several endpoints behind the same upstream hop, all down
+	if policy.SharedHopMinEndpoints > 0 {
+		for hop, down := range hopDown {
+			if down >= policy.SharedHopMinEndpoints && down == hopTotal[hop] {
+				return outageDetection{
+					method:    models.DetectionSharedHop,
+					sharedHop: hop,
+					hopDown:   down,
+					affected:  downCount,
+					total:     len(eps),
+				}, true
+			}
+		}
+	}
+	return outageDetection{}, false
+}
+
+// trackIncidents opens, updates and closes incidents to match this cycle's
+// outage detections. Open incidents are read back from the database so an
+// outage that spans a restart stays a single incident.
+func (s *Scheduler) trackIncidents(detected map[string]outageDetection) {
+	open, err := s.db.GetOpenIncidents()
+	if err != nil {
+		log.Printf("Failed to load open incidents: %v", err)
+		return
+	}
+
+	for isp, d := range detected {
+		if inc, ok := open[isp]; ok {
+			if d.affected > inc.PeakAffected {
+				if err := s.db.UpdateIncidentPeak(inc.ID, d.affected, d.total); err != nil {
+					log.Printf("Failed to update incident %d: %v", inc.ID, err)
 				}
 			}
+			continue
 		}
+
+		id, err := s.db.OpenIncident(isp, d.method, d.sharedHop, d.affected, d.total)
+		if err != nil {
+			log.Printf("Failed to open incident for %s: %v", isp, err)
+			continue
+		}
+		log.Printf("Opened incident %d for %s (%s)", id, isp, d.method)
+		s.notifyIncident(id, true)
+
+		msg := isp + " ISP outage detected"
+		s.recordEvent("outage", isp, "", msg)
 	}
 
-	return outages
+	for isp, inc := range open {
+		if _, ok := detected[isp]; ok {
+			continue
+		}
+
+		if err := s.db.CloseIncident(inc.ID); err != nil {
+			log.Printf("Failed to close incident %d: %v", inc.ID, err)
+			continue
+		}
+		duration := time.Since(inc.StartedAt).Round(time.Minute)
+		log.Printf("Closed incident %d for %s after %s", inc.ID, isp, duration)
+		s.notifyIncident(inc.ID, false)
+
+		msg := fmt.Sprintf("%s ISP recovered from outage after %s", isp, models.FormatDuration(duration))
+		s.recordEvent("recovery", isp, "", msg)
+	}
+}
+
+// notifyIncident passes an opened or closed incident to the notifier
+func (s *Scheduler) notifyIncident(id int64, opened bool) {
+	if s.notifier == nil {
+		return
+	}
+
+	inc, err := s.db.GetIncident(id)
+	if err != nil || inc == nil {
+		log.Printf("Failed to load incident %d for notification: %v", id, err)
+		return
+	}
+
+	if opened {
+		s.notifier.IncidentOpened(*inc)
+	} else {
+		s.notifier.IncidentClosed(*inc)
+	}
 }
 
 func (s *Scheduler) cleanupLoop(ctx context.Context) {
@@ -390,14 +844,104 @@ func (s *Scheduler) cleanupLoop(ctx context.Context) {
 	}
 }
 
+// backupLoop takes a rotating backup every backupInterval. The first one is
+// due one interval after the newest existing backup, so frequent restarts
+// neither skip nor multiply backups.
+func (s *Scheduler) backupLoop(ctx context.Context) {
+	defer s.wg.Done()
+
+	delay := time.Duration(0)
+	if backups, err := storage.ListBackups(s.backupDir); err == nil && len(backups) > 0 {
+		delay = s.backupInterval - time.Since(backups[0].CreatedAt)
+	}
+
+	timer := time.NewTimer(max(delay, 0))
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-timer.C:
+			s.runBackup()
+			timer.Reset(s.backupInterval)
+		}
+	}
+}
+
+func (s *Scheduler) runBackup() {
+	start := time.Now()
+	info, err := s.db.RotateBackup(s.backupDir, s.backupKeep)
+	if err != nil {
+		log.Printf("Failed to back up database: %v", err)
+		return
+	}
+	log.Printf("Backed up database to %s (%d bytes in %s)", info.Name, info.Size, time.Since(start).Round(time.Millisecond))
+}
+
 func (s *Scheduler) runCleanup() {
+	if deleted, err := s.db.CleanupHistoryTiers(); err != nil {
+		log.Printf("Failed to cleanup uptime history: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Cleaned up %d old uptime history rows", deleted)
+	}
+
+	if deleted, err := s.db.CleanupOldProbeResults(probeRetention); err != nil {
+		log.Printf("Failed to cleanup old probe results: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Cleaned up %d old probe results", deleted)
+	}
+
+	if deleted, err := s.db.CleanupOldNotificationDeliveries(deliveryRetention); err != nil {
+		log.Printf("Failed to cleanup notification delivery log: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Cleaned up %d old notification deliveries", deleted)
+	}
+
+	if deleted, err := s.db.CleanupAgentObservations(); err != nil {
+		log.Printf("Failed to cleanup agent observations: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Cleaned up %d agent observations of removed endpoints", deleted)
+	}
+
+	if deleted, err := s.db.CleanupExpiredAdminSessions(); err != nil {
+		log.Printf("Failed to cleanup expired admin sessions: %v", err)
+	} else if deleted > 0 {
+		log.Printf("Cleaned up %d expired admin sessions", deleted)
+	}
+
+	// Endpoints cannot be seen while the server is offline
+	if s.MonitorOffline() {
+		log.Printf("Monitoring server offline; not expiring endpoints")
+		return
+	}
+
 	deleted, err := s.db.DeleteExpired(s.expireDays)
 	if err != nil {
 		log.Printf("Failed to cleanup expired endpoints: %v", err)
-		return
 	}
 
-	if deleted > 0 {
-		log.Printf("Cleaned up %d expired endpoints (not seen in %d days)", deleted, s.expireDays)
+	// Audited so that an expired endpoint can be told apart from an admin
+	// deletion, without addresses since audit entries outlive the endpoint
+	for _, e := range deleted {
+		changes, _ := models.AuditDiff(map[string]interface{}{
+			"isp":        e.ISP,
+			"created_at": e.CreatedAt,
+			"last_seen":  e.LastSeen,
+		}, nil)
+		if err := s.db.RecordAudit(models.AuditEntry{
+			Actor:   models.AuditActorSystem,
+			Action:  models.AuditEndpointExpire,
+			Target:  e.ID,
+			Changes: changes,
+		}); err != nil {
+			log.Printf("Failed to audit expiry of %s: %v", e.ID, err)
+		}
+	}
+
+	if len(deleted) > 0 {
+		log.Printf("Cleaned up %d expired endpoints (not seen in %d days)", len(deleted), s.expireDays)
 	}
 }
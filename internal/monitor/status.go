@@ -0,0 +1,107 @@
+package monitor
+
+import (
+	"sync"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// StatusThresholds controls how many consecutive probes must agree before
+// an endpoint changes between reachable and down
+type StatusThresholds struct {
+	FailThreshold    int     // Consecutive failed probes before up/degraded -> down
+	RecoverThreshold int     // Consecutive successful probes before down -> up/degraded
+	DegradedLossPct  float64 // Packet loss (%) at or above which a reachable endpoint is degraded
+}
+
+// DefaultStatusThresholds returns the default flap-damping thresholds
+func DefaultStatusThresholds() StatusThresholds {
+	return StatusThresholds{
+		FailThreshold:    2,
+		RecoverThreshold: 2,
+		DegradedLossPct:  30,
+	}
+}
+
+// StatusTracker is a per-endpoint state machine that damps status flapping.
+// A single dropped cycle does not take an endpoint down, and a single lucky
+// reply does not bring a down endpoint back up.
+type StatusTracker struct {
+	mu         sync.Mutex
+	thresholds StatusThresholds
+	streaks    map[string]*streak // endpoint ID -> consecutive probe outcomes
+}
+
+type streak struct {
+	failures  int
+	successes int
+}
+
+// NewStatusTracker creates a tracker with the given thresholds.
+// Thresholds below 1 are treated as 1 (no damping).
+func NewStatusTracker(t StatusThresholds) *StatusTracker {
+	if t.FailThreshold < 1 {
+		t.FailThreshold = 1
+	}
+	if t.RecoverThreshold < 1 {
+		t.RecoverThreshold = 1
+	}
+	return &StatusTracker{
+		thresholds: t,
+		streaks:    make(map[string]*streak),
+	}
+}
+
+// Observe feeds one probe result for an endpoint and returns its new status
+func (t *StatusTracker) Observe(id string, current models.EndpointStatus, result PingResult) models.EndpointStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st, ok := t.streaks[id]
+	if !ok {
+		st = &streak{}
+		t.streaks[id] = st
+	}
+
+	if !result.Success {
+		st.failures++
+		st.successes = 0
+
+		if current == models.StatusDown || st.failures >= t.thresholds.FailThreshold {
+			return models.StatusDown
+		}
+		return current
+	}
+
+	st.successes++
+	st.failures = 0
+
+	observed := models.StatusUp
+	if result.Loss >= t.thresholds.DegradedLossPct {
+		observed = models.StatusDegraded
+	}
+
+	if current == models.StatusDown && st.successes < t.thresholds.RecoverThreshold {
+		return models.StatusDown
+	}
+	return observed
+}
+
+// Prune drops the state kept for endpoints not in keep (e.g. deleted ones)
+func (t *StatusTracker) Prune(keep map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for id := range t.streaks {
+		if !keep[id] {
+			delete(t.streaks, id)
+		}
+	}
+}
+
+// Forget drops the state kept for one endpoint, so its next probe starts a
+// fresh streak
+func (t *StatusTracker) Forget(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.streaks, id)
+}
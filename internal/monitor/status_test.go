@@ -0,0 +1,118 @@
+package monitor
+
+import (
+	"testing"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+func TestStatusTrackerObserve(t *testing.T) {
+	thresholds := StatusThresholds{FailThreshold: 3, RecoverThreshold: 2, DegradedLossPct: 20}
+	failed := PingResult{Success: false, Loss: 100}
+	ok := PingResult{Success: true}
+	lossy := PingResult{Success: true, Loss: 20}
+	slightlyLossy := PingResult{Success: true, Loss: 19.9}
+
+	tests := []struct {
+		name    string
+		start   models.EndpointStatus
+		results []PingResult
+		want    []models.EndpointStatus // Status after each result
+	}{
+		{
+			name:    "single failure keeps up",
+			start:   models.StatusUp,
+			results: []PingResult{failed, ok},
+			want:    []models.EndpointStatus{models.StatusUp, models.StatusUp},
+		},
+		{
+			name:    "fail threshold failures give down",
+			start:   models.StatusUp,
+			results: []PingResult{failed, failed, failed, failed},
+			want:    []models.EndpointStatus{models.StatusUp, models.StatusUp, models.StatusDown, models.StatusDown},
+		},
+		{
+			name:    "a success breaks the failure streak",
+			start:   models.StatusUp,
+			results: []PingResult{failed, failed, ok, failed, failed},
+			want:    []models.EndpointStatus{models.StatusUp, models.StatusUp, models.StatusUp, models.StatusUp, models.StatusUp},
+		},
+		{
+			name:    "down needs recover threshold successes",
+			start:   models.StatusDown,
+			results: []PingResult{ok, ok, ok},
+			want:    []models.EndpointStatus{models.StatusDown, models.StatusUp, models.StatusUp},
+		},
+		{
+			name:    "a failure restarts recovery",
+			start:   models.StatusDown,
+			results: []PingResult{ok, failed, ok, ok},
+			want:    []models.EndpointStatus{models.StatusDown, models.StatusDown, models.StatusDown, models.StatusUp},
+		},
+		{
+			name:    "loss at the threshold gives degraded",
+			start:   models.StatusUp,
+			results: []PingResult{lossy, slightlyLossy},
+			want:    []models.EndpointStatus{models.StatusDegraded, models.StatusUp},
+		},
+		{
+			name:    "recovering with loss gives degraded",
+			start:   models.StatusDown,
+			results: []PingResult{lossy, lossy},
+			want:    []models.EndpointStatus{models.StatusDown, models.StatusDegraded},
+		},
+		{
+			name:    "unknown first failure stays unknown",
+			start:   models.StatusUnknown,
+			results: []PingResult{failed, failed, failed},
+			want:    []models.EndpointStatus{models.StatusUnknown, models.StatusUnknown, models.StatusDown},
+		},
+		{
+			name:    "unknown first success is up at once",
+			start:   models.StatusUnknown,
+			results: []PingResult{ok},
+			want:    []models.EndpointStatus{models.StatusUp},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewStatusTracker(thresholds)
+			status := tt.start
+			for i, result := range tt.results {
+				status = tracker.Observe("ep", status, result)
+				if status != tt.want[i] {
+					t.Fatalf("after result %d: status = %s, want %s", i+1, status, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStatusTrackerNoDamping(t *testing.T) {
+	// Thresholds below 1 act as 1
+	tracker := NewStatusTracker(StatusThresholds{DegradedLossPct: 50})
+	if got := tracker.Observe("ep", models.StatusUp, PingResult{Success: false, Loss: 100}); got != models.StatusDown {
+		t.Errorf("first failure: status = %s, want down", got)
+	}
+	if got := tracker.Observe("ep", models.StatusDown, PingResult{Success: true}); got != models.StatusUp {
+		t.Errorf("first success: status = %s, want up", got)
+	}
+}
+
+func TestStatusTrackerForget(t *testing.T) {
+	tracker := NewStatusTracker(StatusThresholds{FailThreshold: 2, RecoverThreshold: 1, DegradedLossPct: 50})
+	failed := PingResult{Success: false, Loss: 100}
+
+	if got := tracker.Observe("ep", models.StatusUp, failed); got != models.StatusUp {
+		t.Fatalf("one failure: status = %s, want up", got)
+	}
+
+	// A new address starts a fresh streak, so one more failure is not enough
+	tracker.Forget("ep")
+	if got := tracker.Observe("ep", models.StatusUp, failed); got != models.StatusUp {
+		t.Errorf("first failure after Forget: status = %s, want up", got)
+	}
+	if got := tracker.Observe("ep", models.StatusUp, failed); got != models.StatusDown {
+		t.Errorf("second failure after Forget: status = %s, want down", got)
+	}
+}
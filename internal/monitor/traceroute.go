@@ -7,6 +7,7 @@ import (
 
 	"golang.org/x/net/icmp"
 	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
 )
 
 // Hop represents a single hop in a traceroute
@@ -25,6 +26,45 @@ type TracerouteResult struct {
 	Error      error
 }
 
+// icmpFamily holds the per-address-family details of an ICMP traceroute
+type icmpFamily struct {
+	network      string // Raw socket network for icmp.ListenPacket
+	listenAddr   string
+	protocol     int // IANA protocol number for icmp.ParseMessage
+	echo         icmp.Type
+	echoReply    icmp.Type
+	timeExceeded icmp.Type
+	unreachable  icmp.Type
+	setTTL       func(conn *icmp.PacketConn, ttl int) error
+}
+
+var (
+	icmpV4 = icmpFamily{
+		network:      "ip4:icmp",
+		listenAddr:   "0.0.0.0",
+		protocol:     1, // ICMP
+		echo:         ipv4.ICMPTypeEcho,
+		echoReply:    ipv4.ICMPTypeEchoReply,
+		timeExceeded: ipv4.ICMPTypeTimeExceeded,
+		unreachable:  ipv4.ICMPTypeDestinationUnreachable,
+		setTTL: func(conn *icmp.PacketConn, ttl int) error {
+			return conn.IPv4PacketConn().SetTTL(ttl)
+		},
+	}
+	icmpV6 = icmpFamily{
+		network:      "ip6:ipv6-icmp",
+		listenAddr:   "::",
+		protocol:     58, // ICMPv6
+		echo:         ipv6.ICMPTypeEchoRequest,
+		echoReply:    ipv6.ICMPTypeEchoReply,
+		timeExceeded: ipv6.ICMPTypeTimeExceeded,
+		unreachable:  ipv6.ICMPTypeDestinationUnreachable,
+		setTTL: func(conn *icmp.PacketConn, ttl int) error {
+			return conn.IPv6PacketConn().SetHopLimit(ttl)
+		},
+	}
+)
+
 // Tracer handles traceroute operations
 type Tracer struct {
 	timeout    time.Duration
@@ -48,8 +88,13 @@ func (t *Tracer) Traceroute(destIP string) TracerouteResult {
 		return TracerouteResult{Error: fmt.Errorf("invalid IP address: %s", destIP)}
 	}
 
+	family := icmpV6
+	if dst.To4() != nil {
+		family = icmpV4
+	}
+
 	// Use ICMP (requires root/CAP_NET_RAW)
-	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	conn, err := icmp.ListenPacket(family.network, family.listenAddr)
 	if err != nil {
 		return TracerouteResult{Error: fmt.Errorf("failed to listen: %w", err)}
 	}
@@ -59,7 +104,7 @@ func (t *Tracer) Traceroute(destIP string) TracerouteResult {
 	var lastResponding *Hop
 
 	for ttl := 1; ttl <= t.maxHops; ttl++ {
-		hop := t.probeHop(conn, dst, ttl)
+		hop := t.probeHop(conn, family, dst, ttl)
 		hops = append(hops, hop)
 
 		if hop.Address != "" {
@@ -84,17 +129,17 @@ func (t *Tracer) Traceroute(destIP string) TracerouteResult {
 	}
 }
 
-func (t *Tracer) probeHop(conn *icmp.PacketConn, dst net.IP, ttl int) Hop {
+func (t *Tracer) probeHop(conn *icmp.PacketConn, family icmpFamily, dst net.IP, ttl int) Hop {
 	hop := Hop{TTL: ttl}
 
-	// Set TTL
-	if err := conn.IPv4PacketConn().SetTTL(ttl); err != nil {
+	// Set TTL (hop limit for IPv6)
+	if err := family.setTTL(conn, ttl); err != nil {
 		return hop
 	}
 
 	// Create ICMP echo request
 	msg := icmp.Message{
-		Type: ipv4.ICMPTypeEcho,
+		Type: family.echo,
 		Code: 0,
 		Body: &icmp.Echo{
 			ID:   ttl, // Use TTL as ID for simplicity
@@ -132,19 +177,19 @@ func (t *Tracer) probeHop(conn *icmp.PacketConn, dst net.IP, ttl int) Hop {
 	hop.Address = peer.String()
 
 	// Parse the ICMP response
-	rm, err := icmp.ParseMessage(1, reply[:n]) // 1 = ICMP for IPv4
+	rm, err := icmp.ParseMessage(family.protocol, reply[:n])
 	if err != nil {
 		return hop
 	}
 
 	switch rm.Type {
-	case ipv4.ICMPTypeEchoReply:
+	case family.echoReply:
 		// We've reached the destination
 		hop.Reached = true
-	case ipv4.ICMPTypeTimeExceeded:
+	case family.timeExceeded:
 		// Intermediate hop (TTL expired in transit)
 		hop.Reached = false
-	case ipv4.ICMPTypeDestinationUnreachable:
+	case family.unreachable:
 		// Destination unreachable but we know there's a hop here
 		hop.Reached = true // Consider this as reaching the edge
 	}
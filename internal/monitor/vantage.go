@@ -0,0 +1,120 @@
+package monitor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonsson/ccc/internal/metrics"
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// vantageOffline reports whether the monitoring server is offline, checking
+// the reference targets again if the last check is older than maxAge. Probe
+// workers pass the recheck interval, so a burst of failures costs one check.
+func (s *Scheduler) vantageOffline(maxAge time.Duration) bool {
+	s.vantageMu.Lock()
+	defer s.vantageMu.Unlock()
+
+	if !s.vantageChecked.IsZero() && time.Since(s.vantageChecked) < maxAge {
+		return s.MonitorOffline()
+	}
+	offline := s.checkVantage()
+	s.vantageChecked = time.Now()
+	s.trackMonitorIncident(offline)
+	return offline
+}
+
+// checkVantage probes the reference targets and reports whether the
+// monitoring server itself is offline, i.e. none of them answered. Without
+// reference targets the server is assumed to be online.
+func (s *Scheduler) checkVantage() bool {
+	targets, err := s.db.ListReferenceTargets()
+	if err != nil {
+		log.Printf("Failed to list reference targets: %v", err)
+		return false
+	}
+	if len(targets) == 0 {
+		return false
+	}
+
+	answered := make([]bool, len(targets))
+	var wg sync.WaitGroup
+	for i := range targets {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			t := targets[i]
+			strategy := s.strategy
+			if len(t.ProbeStrategy) > 0 {
+				if parsed, err := models.ParseProbeStrategy(strings.Join(t.ProbeStrategy, ",")); err == nil {
+					strategy = parsed
+				}
+			}
+			result, _ := s.probers.Run(strategy, t.Address)
+			answered[i] = result.Success
+			if err := s.db.RecordReferenceCheck(t.ID, result.Success); err != nil {
+				log.Printf("Failed to record check of reference target %s: %v", t.Name, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, ok := range answered {
+		if ok {
+			return false
+		}
+	}
+	return true
+}
+
+// trackMonitorIncident opens a monitor offline incident when the server
+// loses its connectivity and closes it once a reference target answers again
+func (s *Scheduler) trackMonitorIncident(offline bool) {
+	s.outagesMu.Lock()
+	s.monitorOffline = offline
+	s.outagesMu.Unlock()
+	if offline {
+		metrics.MonitorOffline.Set(1)
+	} else {
+		metrics.MonitorOffline.Set(0)
+	}
+
+	inc, err := s.db.GetOpenMonitorIncident()
+	if err != nil {
+		log.Printf("Failed to load monitor incident: %v", err)
+		return
+	}
+
+	switch {
+	case offline && inc == nil:
+		id, err := s.db.OpenIncident("", models.DetectionMonitorOffline, "", 0, 0)
+		if err != nil {
+			log.Printf("Failed to open monitor offline incident: %v", err)
+			return
+		}
+		log.Printf("Opened incident %d: no reference target answers, pausing endpoint monitoring", id)
+		s.notifyIncident(id, true)
+		s.recordEvent("monitor_offline", "", "", "Monitoring server lost connectivity; endpoint statuses are paused")
+
+	case !offline && inc != nil:
+		if err := s.db.CloseIncident(inc.ID); err != nil {
+			log.Printf("Failed to close incident %d: %v", inc.ID, err)
+			return
+		}
+		duration := time.Since(inc.StartedAt).Round(time.Minute)
+		log.Printf("Closed incident %d: monitoring server back online after %s", inc.ID, duration)
+		s.notifyIncident(inc.ID, false)
+		s.recordEvent("monitor_online", "", "", fmt.Sprintf("Monitoring server back online after %s", models.FormatDuration(duration)))
+	}
+}
+
+// MonitorOffline reports whether the last reference target check found the
+// monitoring server without connectivity
+func (s *Scheduler) MonitorOffline() bool {
+	s.outagesMu.RLock()
+	defer s.outagesMu.RUnlock()
+	return s.monitorOffline
+}
@@ -0,0 +1,176 @@
+package notify
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/storage"
+)
+
+const (
+	maxAttempts    = 4               // Delivery attempts per notifier before giving up
+	initialBackoff = 5 * time.Second // Doubled after each failed attempt
+	queueSize      = 64
+)
+
+// Manager fans incident transitions out to every enabled notifier, retrying
+// failed deliveries with exponential backoff and logging each attempt
+type Manager struct {
+	db      *storage.DB
+	queue   chan Message
+	backoff time.Duration
+	stopCh  chan struct{}
+	wg      sync.WaitGroup
+}
+
+// NewManager creates a notification manager. Notifiers are read from the
+// database for every message, so admin changes apply immediately.
+func NewManager(db *storage.DB) *Manager {
+	return &Manager{
+		db:      db,
+		queue:   make(chan Message, queueSize),
+		backoff: initialBackoff,
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Start begins the delivery loop
+func (m *Manager) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	go func() {
+		<-m.stopCh
+		cancel()
+	}()
+
+	m.wg.Add(1)
+	go m.loop(ctx)
+}
+
+// Stop cancels pending retries and waits for in-flight deliveries
+func (m *Manager) Stop() {
+	close(m.stopCh)
+	m.wg.Wait()
+	log.Println("Notification manager stopped")
+}
+
+// IncidentOpened queues an alert for a newly opened incident
+func (m *Manager) IncidentOpened(inc models.Incident) {
+	m.enqueue(incidentMessage(EventIncidentOpened, publicIncident(inc)))
+}
+
+// IncidentClosed queues an alert for a recovered incident
+func (m *Manager) IncidentClosed(inc models.Incident) {
+	m.enqueue(incidentMessage(EventIncidentClosed, publicIncident(inc)))
+}
+
+// SendTest delivers a test message to one notifier with a single attempt,
+// so the admin API can report the result directly
+func (m *Manager) SendTest(ctx context.Context, cfg models.NotifierConfig) error {
+	n, err := New(cfg)
+	if err != nil {
+		return err
+	}
+	return m.attempt(ctx, cfg.ID, n, testMessage(), 1)
+}
+
+// publicIncident strips details that should not leave the server; ntfy
+// topics in particular are often publicly readable
+func publicIncident(inc models.Incident) models.Incident {
+	inc.SharedHop = ""
+	return inc
+}
+
+func (m *Manager) enqueue(msg Message) {
+	select {
+	case m.queue <- msg:
+	default:
+		log.Printf("Notification queue full, dropping %s notification", msg.Event)
+	}
+}
+
+func (m *Manager) loop(ctx context.Context) {
+	defer m.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-m.queue:
+			m.dispatch(ctx, msg)
+		}
+	}
+}
+
+// dispatch starts a delivery to every enabled notifier
+func (m *Manager) dispatch(ctx context.Context, msg Message) {
+	configs, err := m.db.ListNotifiers(true)
+	if err != nil {
+		log.Printf("Failed to load notifiers: %v", err)
+		return
+	}
+
+	for _, cfg := range configs {
+		n, err := New(cfg)
+		if err != nil {
+			log.Printf("Skipping notifier %d (%s): %v", cfg.ID, cfg.Name, err)
+			continue
+		}
+
+		m.wg.Add(1)
+		go func(cfg models.NotifierConfig, n Notifier) {
+			defer m.wg.Done()
+			m.deliver(ctx, cfg, n, msg)
+		}(cfg, n)
+	}
+}
+
+// deliver sends msg to one notifier, retrying with exponential backoff
+func (m *Manager) deliver(ctx context.Context, cfg models.NotifierConfig, n Notifier, msg Message) {
+	backoff := m.backoff
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		err := m.attempt(ctx, cfg.ID, n, msg, attempt)
+		if err == nil {
+			return
+		}
+		log.Printf("Notifier %d (%s) attempt %d/%d failed: %v", cfg.ID, cfg.Name, attempt, maxAttempts, err)
+
+		if attempt == maxAttempts {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	log.Printf("Giving up on %s notification for notifier %d (%s)", msg.Event, cfg.ID, cfg.Name)
+}
+
+// attempt makes one delivery and records the outcome in the delivery log
+func (m *Manager) attempt(ctx context.Context, notifierID int64, n Notifier, msg Message, attempt int) error {
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+
+	err := n.Send(sendCtx, msg)
+
+	delivery := models.NotificationDelivery{
+		NotifierID: notifierID,
+		Event:      msg.Event,
+		Attempt:    attempt,
+		Success:    err == nil,
+	}
+	if msg.Incident != nil {
+		delivery.IncidentID = msg.Incident.ID
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+	if logErr := m.db.RecordNotificationDelivery(delivery); logErr != nil {
+		log.Printf("Failed to record notification delivery: %v", logErr)
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/jonsson/ccc/internal/storage"
+)
+
+// flakyNotifier fails its first failures sends
+type flakyNotifier struct {
+	failures int
+	calls    atomic.Int32
+}
+
+func (f *flakyNotifier) Send(ctx context.Context, msg Message) error {
+	if int(f.calls.Add(1)) <= f.failures {
+		return errors.New("stand-in failure")
+	}
+	return nil
+}
+
+func TestManagerDeliverRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		attempts  int
+		delivered bool
+	}{
+		{"first attempt succeeds", 0, 1, true},
+		{"succeeds after retries", 2, 3, true},
+		{"gives up", maxAttempts, maxAttempts, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := storage.New(filepath.Join(t.TempDir(), "ccc.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			cfg := models.NotifierConfig{Name: "stand-in", Type: TypeWebhook, Enabled: true, Config: []byte(`{"url": "http://127.0.0.1"}`)}
+			if cfg.ID, err = db.CreateNotifier(cfg); err != nil {
+				t.Fatal(err)
+			}
+
+			m := NewManager(db)
+			m.backoff = time.Millisecond
+			n := &flakyNotifier{failures: tt.failures}
+			m.deliver(context.Background(), cfg, n, testIncidentMessage())
+
+			if got := int(n.calls.Load()); got != tt.attempts {
+				t.Errorf("attempts = %d, want %d", got, tt.attempts)
+			}
+
+			deliveries, err := db.ListNotificationDeliveries(cfg.ID, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deliveries) != tt.attempts {
+				t.Fatalf("logged %d deliveries, want %d", len(deliveries), tt.attempts)
+			}
+			// Newest first
+			for i, d := range deliveries {
+				attempt := tt.attempts - i
+				success := tt.delivered && i == 0
+				if d.Attempt != attempt || d.Success != success || d.Event != EventIncidentOpened || d.IncidentID != 7 {
+					t.Errorf("delivery %d = %+v, want attempt %d success %v", i, d, attempt, success)
+				}
+				if !success && d.Error == "" {
+					t.Errorf("failed delivery %d has no error", i)
+				}
+			}
+		})
+	}
+}
+
+func TestManagerDeliverStopsOnCancel(t *testing.T) {
+	db, err := storage.New(filepath.Join(t.TempDir(), "ccc.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := NewManager(db)
+	m.backoff = time.Hour
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &flakyNotifier{failures: maxAttempts}
+
+	done := make(chan struct{})
+	go func() {
+		m.deliver(ctx, models.NotifierConfig{ID: 1}, n, testMessage())
+		close(done)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("deliver kept waiting after cancel")
+	}
+	if got := n.calls.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
@@ -0,0 +1,134 @@
+// Package notify delivers incident alerts to admin-configured destinations
+// such as webhooks, email and push services.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// Notification events
+const (
+	EventIncidentOpened = "incident_opened"
+	EventIncidentClosed = "incident_closed"
+	EventTest           = "test"
+)
+
+// Notifier types
+const (
+	TypeWebhook = "webhook"
+	TypeSMTP    = "smtp"
+	TypeNtfy    = "ntfy"
+	TypeGotify  = "gotify"
+)
+
+// sendTimeout bounds a single delivery attempt
+const sendTimeout = 15 * time.Second
+
+// Message is a notification about an incident transition
+type Message struct {
+	Event     string           `json:"event"`
+	Title     string           `json:"title"`
+	Body      string           `json:"message"`
+	Timestamp time.Time        `json:"timestamp"`
+	Incident  *models.Incident `json:"incident,omitempty"`
+}
+
+// Notifier delivers messages to one destination
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New builds a notifier from its stored configuration
+func New(cfg models.NotifierConfig) (Notifier, error) {
+	switch cfg.Type {
+	case TypeWebhook:
+		return newWebhook(cfg.Config)
+	case TypeSMTP:
+		return newSMTP(cfg.Config)
+	case TypeNtfy:
+		return newNtfy(cfg.Config)
+	case TypeGotify:
+		return newGotify(cfg.Config)
+	default:
+		return nil, fmt.Errorf("unknown notifier type %q", cfg.Type)
+	}
+}
+
+// decodeConfig parses a backend configuration, rejecting unknown fields so
+// typos surface when the notifier is saved rather than when an alert fires
+func decodeConfig(raw json.RawMessage, v interface{}) error {
+	if len(raw) == 0 {
+		raw = []byte("{}")
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
+
+// incidentMessage describes an incident transition for humans
+func incidentMessage(event string, inc models.Incident) Message {
+	msg := Message{
+		Event:     event,
+		Timestamp: time.Now(),
+		Incident:  &inc,
+	}
+
+	if inc.DetectionMethod == models.DetectionMonitorOffline {
+		switch event {
+		case EventIncidentOpened:
+			msg.Title = "Monitoring server offline"
+			msg.Body = "No reference target answers, so the monitoring server has lost its own connectivity. Endpoint statuses and outage detection are paused until it recovers."
+		case EventIncidentClosed:
+			msg.Title = "Monitoring server back online"
+			msg.Body = fmt.Sprintf("The monitoring server was offline for %s. Monitoring has resumed.",
+				models.FormatDuration(time.Duration(inc.DurationSeconds)*time.Second))
+		}
+		return msg
+	}
+
+	switch event {
+	case EventIncidentOpened:
+		msg.Title = inc.ISP + " outage detected"
+		msg.Body = fmt.Sprintf("%d of %d %s endpoints are down.", inc.PeakAffected, inc.TotalEndpoints, inc.ISP)
+		if inc.DetectionMethod == models.DetectionSharedHop {
+			msg.Body += " Several endpoints share the same failing upstream hop."
+		}
+	case EventIncidentClosed:
+		msg.Title = inc.ISP + " recovered"
+		msg.Body = fmt.Sprintf("The %s outage lasted %s. At its peak %d of %d endpoints were down.",
+			inc.ISP, models.FormatDuration(time.Duration(inc.DurationSeconds)*time.Second),
+			inc.PeakAffected, inc.TotalEndpoints)
+	}
+	return msg
+}
+
+// testMessage is sent by the admin "test notifier" action
+func testMessage() Message {
+	return Message{
+		Event:     EventTest,
+		Title:     "CCC test notification",
+		Body:      "This notifier is configured correctly.",
+		Timestamp: time.Now(),
+	}
+}
+
+// httpClient is shared by the HTTP-based backends
+var httpClient = &http.Client{Timeout: sendTimeout}
+
+// checkResponse turns a non-2xx HTTP response into an error
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response status: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// NtfyConfig configures an ntfy topic
+type NtfyConfig struct {
+	Server   string `json:"server,omitempty"` // Defaults to https://ntfy.sh
+	Topic    string `json:"topic"`
+	Token    string `json:"token,omitempty"`    // Access token for protected topics
+	Priority int    `json:"priority,omitempty"` // 1-5, 0 = server default
+}
+
+// Ntfy publishes messages to an ntfy topic
+type Ntfy struct {
+	cfg NtfyConfig
+}
+
+func newNtfy(raw json.RawMessage) (*Ntfy, error) {
+	var cfg NtfyConfig
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Server == "" {
+		cfg.Server = "https://ntfy.sh"
+	}
+	if err := validateHTTPURL(cfg.Server); err != nil {
+		return nil, err
+	}
+	if cfg.Topic == "" || strings.Contains(cfg.Topic, "/") {
+		return nil, fmt.Errorf("invalid config: topic is required and must not contain '/'")
+	}
+	if cfg.Priority < 0 || cfg.Priority > 5 {
+		return nil, fmt.Errorf("invalid config: priority must be between 1 and 5")
+	}
+	return &Ntfy{cfg: cfg}, nil
+}
+
+// Send delivers the message
+func (n *Ntfy) Send(ctx context.Context, msg Message) error {
+	topicURL := strings.TrimRight(n.cfg.Server, "/") + "/" + n.cfg.Topic
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, topicURL, strings.NewReader(msg.Body))
+	if err != nil {
+		return fmt.Errorf("failed to create ntfy request: %w", err)
+	}
+	req.Header.Set("Title", msg.Title)
+	if n.cfg.Priority > 0 {
+		req.Header.Set("Priority", strconv.Itoa(n.cfg.Priority))
+	}
+	switch msg.Event {
+	case EventIncidentOpened:
+		req.Header.Set("Tags", "warning")
+	case EventIncidentClosed:
+		req.Header.Set("Tags", "white_check_mark")
+	}
+	if n.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+n.cfg.Token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("ntfy request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
+
+// GotifyConfig configures a Gotify application
+type GotifyConfig struct {
+	Server   string `json:"server"`
+	Token    string `json:"token"` // Application token
+	Priority int    `json:"priority,omitempty"`
+}
+
+// Gotify pushes messages to a Gotify server
+type Gotify struct {
+	cfg GotifyConfig
+}
+
+func newGotify(raw json.RawMessage) (*Gotify, error) {
+	var cfg GotifyConfig
+	if err := decodeConfig(raw, &cfg); err != nil {
+		return nil, err
+	}
+	if err := validateHTTPURL(cfg.Server); err != nil {
+		return nil, err
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("invalid config: token is required")
+	}
+	return &Gotify{cfg: cfg}, nil
+}
+
+// Send delivers the message
+func (g *Gotify) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"title":    msg.Title,
+		"message":  msg.Body,
+		"priority": g.cfg.Priority,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode gotify payload: %w", err)
+	}
+
+	messageURL := strings.TrimRight(g.cfg.Server, "/") + "/message"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, messageURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create gotify request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gotify-Key", g.cfg.Token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("gotify request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
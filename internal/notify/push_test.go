@@ -0,0 +1,100 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+func TestNtfySend(t *testing.T) {
+	srv, requests := standIn(t, http.StatusOK)
+	cfg, _ := json.Marshal(NtfyConfig{Server: srv.URL + "/", Topic: "building-alerts", Token: "tk", Priority: 4})
+	n, err := New(models.NotifierConfig{Type: TypeNtfy, Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := testIncidentMessage()
+	if err := n.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/building-alerts" {
+		t.Errorf("got %s %s, want POST /building-alerts", req.method, req.path)
+	}
+	if string(req.body) != msg.Body {
+		t.Errorf("body = %q, want %q", req.body, msg.Body)
+	}
+	for header, want := range map[string]string{
+		"Title":         msg.Title,
+		"Priority":      "4",
+		"Tags":          "warning",
+		"Authorization": "Bearer tk",
+	} {
+		if got := req.header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestNtfyConfigValidation(t *testing.T) {
+	for _, raw := range []string{`{}`, `{"topic": "a/b"}`, `{"topic": "alerts", "priority": 6}`} {
+		if _, err := New(models.NotifierConfig{Type: TypeNtfy, Config: json.RawMessage(raw)}); err == nil {
+			t.Errorf("config %s accepted", raw)
+		}
+	}
+}
+
+func TestGotifySend(t *testing.T) {
+	srv, requests := standIn(t, http.StatusOK)
+	cfg, _ := json.Marshal(GotifyConfig{Server: srv.URL, Token: "app-token", Priority: 8})
+	n, err := New(models.NotifierConfig{Type: TypeGotify, Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := testIncidentMessage()
+	if err := n.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/message" {
+		t.Errorf("got %s %s, want POST /message", req.method, req.path)
+	}
+	if got := req.header.Get("X-Gotify-Key"); got != "app-token" {
+		t.Errorf("X-Gotify-Key = %q", got)
+	}
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	var payload struct {
+		Title    string `json:"title"`
+		Message  string `json:"message"`
+		Priority int    `json:"priority"`
+	}
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	if payload.Title != msg.Title || payload.Message != msg.Body || payload.Priority != 8 {
+		t.Errorf("payload = %+v", payload)
+	}
+}
+
+func TestGotifyErrorStatus(t *testing.T) {
+	srv, _ := standIn(t, http.StatusUnauthorized)
+	cfg, _ := json.Marshal(GotifyConfig{Server: srv.URL, Token: "wrong"})
+	n, err := New(models.NotifierConfig{Type: TypeGotify, Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Send(context.Background(), testMessage()); err == nil {
+		t.Error("expected an error for a 401 response")
+	}
+}
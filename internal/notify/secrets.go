@@ -0,0 +1,63 @@
+package notify
+
+import "encoding/json"
+
+// RedactedValue replaces secrets in configs returned by the admin API.
+// Sending it back unchanged in an update keeps the stored secret.
+const RedactedValue = "********"
+
+// secretFields are config keys whose values are never returned
+var secretFields = []string{"password", "token", "secret"}
+
+// RedactConfig masks secret values (and all webhook header values, which
+// commonly carry credentials) in a notifier config
+func RedactConfig(raw json.RawMessage) json.RawMessage {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return json.RawMessage("{}")
+	}
+
+	for _, key := range secretFields {
+		if v, ok := fields[key].(string); ok && v != "" {
+			fields[key] = RedactedValue
+		}
+	}
+	if headers, ok := fields["headers"].(map[string]interface{}); ok {
+		for k := range headers {
+			headers[k] = RedactedValue
+		}
+	}
+
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return json.RawMessage("{}")
+	}
+	return redacted
+}
+
+// MergeSecrets restores secrets that an update sent back as RedactedValue
+// from the currently stored config
+func MergeSecrets(updated, existing json.RawMessage) (json.RawMessage, error) {
+	var newFields, oldFields map[string]interface{}
+	if err := json.Unmarshal(updated, &newFields); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(existing, &oldFields); err != nil {
+		oldFields = map[string]interface{}{}
+	}
+
+	for _, key := range secretFields {
+		if newFields[key] == RedactedValue {
+			newFields[key] = oldFields[key]
+		}
+	}
+	newHeaders, _ := newFields["headers"].(map[string]interface{})
+	oldHeaders, _ := oldFields["headers"].(map[string]interface{})
+	for k, v := range newHeaders {
+		if v == RedactedValue {
+			newHeaders[k] = oldHeaders[k]
+		}
+	}
+
+	return json.Marshal(newFields)
+}
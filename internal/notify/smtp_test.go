@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// smtpSession is what a fake SMTP server received in one session
+type smtpSession struct {
+	commands []string
+	auth     string // Decoded AUTH PLAIN credentials
+	data     string
+}
+
+// fakeSMTP accepts one session on a loopback port, answering just enough
+// of the protocol for net/smtp. It offers AUTH PLAIN but not STARTTLS.
+func fakeSMTP(t *testing.T) (int, chan smtpSession) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	sessions := make(chan smtpSession, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var s smtpSession
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP fake")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				sessions <- s
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			s.commands = append(s.commands, line)
+
+			verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch verb {
+			case "EHLO":
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case "AUTH":
+				fields := strings.Fields(line)
+				decoded, _ := base64.StdEncoding.DecodeString(fields[len(fields)-1])
+				s.auth = string(decoded)
+				reply("235 Authenticated")
+			case "DATA":
+				reply("354 Go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil || l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				s.data = data.String()
+				reply("250 Queued")
+			case "QUIT":
+				reply("221 Bye")
+				sessions <- s
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, sessions
+}
+
+func TestSMTPSend(t *testing.T) {
+	port, sessions := fakeSMTP(t)
+	cfg, _ := json.Marshal(SMTPConfig{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Username: "alerts",
+		Password: "pw",
+		From:     "ccc@example.org",
+		To:       []string{"board@example.org", "ops@example.org"},
+	})
+	n, err := New(models.NotifierConfig{Type: TypeSMTP, Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := testIncidentMessage()
+	if err := n.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	s := <-sessions
+	if s.auth != "\x00alerts\x00pw" {
+		t.Errorf("AUTH PLAIN credentials = %q", s.auth)
+	}
+	for _, want := range []string{
+		"MAIL FROM:<ccc@example.org>",
+		"RCPT TO:<board@example.org>",
+		"RCPT TO:<ops@example.org>",
+		"QUIT",
+	} {
+		found := false
+		for _, c := range s.commands {
+			if strings.HasPrefix(c, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("missing command %q in %q", want, s.commands)
+		}
+	}
+	for _, want := range []string{
+		"From: ccc@example.org\r\n",
+		"To: board@example.org, ops@example.org\r\n",
+		"Subject: " + msg.Title + "\r\n",
+		"\r\n\r\n" + msg.Body + "\r\n",
+	} {
+		if !strings.Contains(s.data, want) {
+			t.Errorf("email lacks %q:\n%s", want, s.data)
+		}
+	}
+}
+
+func TestSMTPConfigValidation(t *testing.T) {
+	for _, raw := range []string{
+		`{"host": "mail.example.org", "from": "a@example.org"}`,
+		`{"host": "mail.example.org", "from": "a@example.org\r\nBcc: x@example.org", "to": ["b@example.org"]}`,
+	} {
+		if _, err := New(models.NotifierConfig{Type: TypeSMTP, Config: json.RawMessage(raw)}); err == nil {
+			t.Errorf("config %q accepted", raw)
+		}
+	}
+}
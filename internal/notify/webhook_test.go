@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// captured is one request received by a stand-in server
+type captured struct {
+	method string
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// standIn starts a server that records each request and answers with
+// status
+func standIn(t *testing.T, status int) (*httptest.Server, chan captured) {
+	t.Helper()
+	requests := make(chan captured, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- captured{r.Method, r.URL.Path, r.Header.Clone(), body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func testIncidentMessage() Message {
+	return incidentMessage(EventIncidentOpened, models.Incident{
+		ID:              7,
+		ISP:             "Comcast",
+		StartedAt:       time.Now(),
+		PeakAffected:    3,
+		TotalEndpoints:  4,
+		DetectionMethod: models.DetectionThreshold,
+	})
+}
+
+func TestWebhookSend(t *testing.T) {
+	srv, requests := standIn(t, http.StatusNoContent)
+	cfg, _ := json.Marshal(WebhookConfig{
+		URL:     srv.URL + "/hook",
+		Headers: map[string]string{"Authorization": "Bearer abc"},
+		Secret:  "s3cret",
+	})
+	n, err := New(models.NotifierConfig{Type: TypeWebhook, Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := testIncidentMessage()
+	if err := n.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := <-requests
+	if req.method != http.MethodPost || req.path != "/hook" {
+		t.Errorf("got %s %s, want POST /hook", req.method, req.path)
+	}
+	if got := req.header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got := req.header.Get("Authorization"); got != "Bearer abc" {
+		t.Errorf("Authorization = %q", got)
+	}
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(req.body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.header.Get("X-CCC-Signature") != want {
+		t.Errorf("X-CCC-Signature = %q, want %q", req.header.Get("X-CCC-Signature"), want)
+	}
+
+	var payload Message
+	if err := json.Unmarshal(req.body, &payload); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	if payload.Event != EventIncidentOpened || payload.Title != msg.Title || payload.Body != msg.Body {
+		t.Errorf("payload = %+v, want %+v", payload, msg)
+	}
+	if payload.Incident == nil || payload.Incident.ID != 7 {
+		t.Errorf("payload incident = %+v", payload.Incident)
+	}
+}
+
+func TestWebhookUnsigned(t *testing.T) {
+	srv, requests := standIn(t, http.StatusOK)
+	cfg, _ := json.Marshal(WebhookConfig{URL: srv.URL})
+	n, err := New(models.NotifierConfig{Type: TypeWebhook, Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Send(context.Background(), testMessage()); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if req := <-requests; req.header.Get("X-CCC-Signature") != "" {
+		t.Error("unsigned webhook sent a signature")
+	}
+}
+
+func TestWebhookErrorStatus(t *testing.T) {
+	srv, _ := standIn(t, http.StatusInternalServerError)
+	cfg, _ := json.Marshal(WebhookConfig{URL: srv.URL})
+	n, err := New(models.NotifierConfig{Type: TypeWebhook, Config: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Send(context.Background(), testMessage()); err == nil {
+		t.Error("expected an error for a 500 response")
+	}
+}
+
+func TestWebhookConfigValidation(t *testing.T) {
+	for _, raw := range []string{`{}`, `{"url": "ftp://example.org"}`, `{"url": "https://example.org", "typo": 1}`} {
+		if _, err := New(models.NotifierConfig{Type: TypeWebhook, Config: json.RawMessage(raw)}); err == nil {
+			t.Errorf("config %s accepted", raw)
+		}
+	}
+}
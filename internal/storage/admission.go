@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	settingAdmissionPolicy     = "admission_policy"
+	settingAdmissionPassphrase = "admission_passphrase_hash"
+)
+
+// GetAdmissionPolicy returns the registration admission policy. The zero
+// policy admits any resident of an allowed ISP.
+func (db *DB) GetAdmissionPolicy() (models.AdmissionPolicy, error) {
+	policy := models.AdmissionPolicy{Networks: []models.AdmissionNetwork{}}
+	val, err := db.GetSetting(settingAdmissionPolicy)
+	if err != nil || val == "" {
+		return policy, err
+	}
+	if err := json.Unmarshal([]byte(val), &policy); err != nil {
+		return policy, fmt.Errorf("failed to parse admission policy: %w", err)
+	}
+	return policy, nil
+}
+
+// SetAdmissionPolicy saves the registration admission policy
+func (db *DB) SetAdmissionPolicy(policy models.AdmissionPolicy) error {
+	data, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to serialize admission policy: %w", err)
+	}
+	return db.SetSetting(settingAdmissionPolicy, string(data))
+}
+
+// SetAdmissionPassphrase sets the building passphrase; "" removes it
+func (db *DB) SetAdmissionPassphrase(passphrase string) error {
+	if passphrase == "" {
+		if _, err := db.conn.Exec(`DELETE FROM settings WHERE key = ?`, settingAdmissionPassphrase); err != nil {
+			return fmt.Errorf("failed to remove passphrase: %w", err)
+		}
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(passphrase), bcrypt.DefaultCost)
+	if err != nil {
+		return fmt.Errorf("failed to hash passphrase: %w", err)
+	}
+	return db.SetSetting(settingAdmissionPassphrase, string(hash))
+}
+
+// HasAdmissionPassphrase reports whether a building passphrase is set
+func (db *DB) HasAdmissionPassphrase() (bool, error) {
+	hash, err := db.GetSetting(settingAdmissionPassphrase)
+	return hash != "", err
+}
+
+// CheckAdmissionPassphrase reports whether passphrase matches the building
+// passphrase. It is false if none is set.
+func (db *DB) CheckAdmissionPassphrase(passphrase string) (bool, error) {
+	hash, err := db.GetSetting(settingAdmissionPassphrase)
+	if err != nil || hash == "" {
+		return false, err
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(passphrase)) == nil, nil
+}
+
+// inviteEncoding spells invite codes without padding or lowercase letters
+var inviteEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// normalizeInviteCode ignores case, spaces and dashes in typed codes
+func normalizeInviteCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// CreateInvite issues a one-time invite code. The code is returned once,
+// grouped as XXXX-XXXX-XXXX-XXXX; only its hash is stored.
+func (db *DB) CreateInvite(note, createdBy string, expiresAt *time.Time) (*models.Invite, string, error) {
+	bytes := make([]byte, 10)
+	if _, err := rand.Read(bytes); err != nil {
+		return nil, "", fmt.Errorf("failed to generate invite code: %w", err)
+	}
+	raw := inviteEncoding.EncodeToString(bytes)
+	code := raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+
+	invite := &models.Invite{Note: note, CreatedBy: createdBy, CreatedAt: time.Now(), ExpiresAt: expiresAt}
+	var expires interface{}
+	if expiresAt != nil {
+		expires = *expiresAt
+	}
+	result, err := db.conn.Exec(`
+		INSERT INTO invites (code_hash, note, created_by, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, hashToken(raw), nullString(note), createdBy, invite.CreatedAt, expires)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to save invite: %w", err)
+	}
+	invite.ID, _ = result.LastInsertId()
+	return invite, code, nil
+}
+
+// ListInvites returns all invites, newest first
+func (db *DB) ListInvites() ([]models.Invite, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, COALESCE(note, ''), created_by, created_at, COALESCE(expires_at, ''), COALESCE(used_at, ''), COALESCE(endpoint_id, '')
+		FROM invites ORDER BY id DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invites: %w", err)
+	}
+	defer rows.Close()
+
+	var invites []models.Invite
+	for rows.Next() {
+		var inv models.Invite
+		var createdAt, expiresAt, usedAt string
+		if err := rows.Scan(&inv.ID, &inv.Note, &inv.CreatedBy, &createdAt, &expiresAt, &usedAt, &inv.EndpointID); err != nil {
+			return nil, fmt.Errorf("failed to scan invite: %w", err)
+		}
+		inv.CreatedAt = parseTime(createdAt)
+		if expiresAt != "" {
+			t := parseTime(expiresAt)
+			inv.ExpiresAt = &t
+		}
+		if usedAt != "" {
+			t := parseTime(usedAt)
+			inv.UsedAt = &t
+		}
+		invites = append(invites, inv)
+	}
+	return invites, rows.Err()
+}
+
+// DeleteInvite revokes an invite, reporting whether it existed
+func (db *DB) DeleteInvite(id int64) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM invites WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete invite: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// ClaimInvite marks an invite code as used by endpointID. It returns ""
+// on success, or the rejection code (models.RejectInvite*) explaining why
+// the code cannot be used.
+func (db *DB) ClaimInvite(code, endpointID string) (string, error) {
+	codeHash := hashToken(normalizeInviteCode(code))
+
+	var id int64
+	var expiresAt, usedAt string
+	err := db.conn.QueryRow(`
+		SELECT id, COALESCE(expires_at, ''), COALESCE(used_at, '') FROM invites WHERE code_hash = ?
+	`, codeHash).Scan(&id, &expiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return models.RejectInviteInvalid, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up invite: %w", err)
+	}
+	if usedAt != "" {
+		return models.RejectInviteUsed, nil
+	}
+	if expiresAt != "" && time.Now().After(parseTime(expiresAt)) {
+		return models.RejectInviteExpired, nil
+	}
+
+	// Conditional so that two registrations racing for one code cannot both win
+	result, err := db.conn.Exec(`
+		UPDATE invites SET used_at = ?, endpoint_id = ? WHERE id = ? AND used_at IS NULL
+	`, time.Now(), endpointID, id)
+	if err != nil {
+		return "", fmt.Errorf("failed to claim invite: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return models.RejectInviteUsed, nil
+	}
+	return "", nil
+}
+
+// ReleaseInvite makes the invite claimed by endpointID usable again, for
+// when registration fails after the claim
+func (db *DB) ReleaseInvite(endpointID string) error {
+	if _, err := db.conn.Exec(`
+		UPDATE invites SET used_at = NULL, endpoint_id = NULL WHERE endpoint_id = ?
+	`, endpointID); err != nil {
+		return fmt.Errorf("failed to release invite: %w", err)
+	}
+	return nil
+}
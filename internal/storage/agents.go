@@ -0,0 +1,261 @@
+package storage
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+const agentColumns = `id, name, COALESCE(isps, ''), created_at, last_seen`
+
+// newAgentKey generates a random agent key. Only its hash is stored.
+func newAgentKey() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate agent key: %w", err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// agentISPs encodes an agent's ISP list; NULL means every ISP
+func agentISPs(isps []string) (sql.NullString, error) {
+	if len(isps) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(isps)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+// ListProbeAgents returns all probe agents, ordered by name
+func (db *DB) ListProbeAgents() ([]models.ProbeAgent, error) {
+	rows, err := db.conn.Query(`SELECT ` + agentColumns + ` FROM probe_agents ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list probe agents: %w", err)
+	}
+	defer rows.Close()
+
+	var agents []models.ProbeAgent
+	for rows.Next() {
+		a, err := scanProbeAgent(rows)
+		if err != nil {
+			return nil, err
+		}
+		agents = append(agents, *a)
+	}
+	return agents, rows.Err()
+}
+
+// GetProbeAgent returns one probe agent, or nil if it does not exist
+func (db *DB) GetProbeAgent(id int64) (*models.ProbeAgent, error) {
+	row := db.conn.QueryRow(`SELECT `+agentColumns+` FROM probe_agents WHERE id = ?`, id)
+	a, err := scanProbeAgent(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return a, err
+}
+
+// AuthenticateProbeAgent returns the agent a key belongs to and records
+// that it was seen, or returns nil if the key is unknown
+func (db *DB) AuthenticateProbeAgent(key string) (*models.ProbeAgent, error) {
+	if key == "" {
+		return nil, nil
+	}
+
+	row := db.conn.QueryRow(`SELECT `+agentColumns+` FROM probe_agents WHERE key_hash = ?`, hashToken(key))
+	a, err := scanProbeAgent(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if _, err := db.conn.Exec(`UPDATE probe_agents SET last_seen = ? WHERE id = ?`, now, a.ID); err != nil {
+		return nil, fmt.Errorf("failed to update agent last_seen: %w", err)
+	}
+	a.LastSeen = &now
+	return a, nil
+}
+
+// CreateProbeAgent stores a new probe agent and sets its ID. The agent's
+// key is returned once and never stored.
+func (db *DB) CreateProbeAgent(a *models.ProbeAgent) (string, error) {
+	key, err := newAgentKey()
+	if err != nil {
+		return "", err
+	}
+	isps, err := agentISPs(a.ISPs)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode agent ISPs: %w", err)
+	}
+
+	a.CreatedAt = time.Now()
+	result, err := db.conn.Exec(`
+		INSERT INTO probe_agents (name, key_hash, isps, created_at)
+		VALUES (?, ?, ?, ?)
+	`, a.Name, hashToken(key), isps, a.CreatedAt)
+	if err != nil {
+		return "", fmt.Errorf("failed to create probe agent: %w", err)
+	}
+	a.ID, err = result.LastInsertId()
+	return key, err
+}
+
+// UpdateProbeAgent changes the name and ISPs of a probe agent.
+// Returns false if the agent does not exist.
+func (db *DB) UpdateProbeAgent(a models.ProbeAgent) (bool, error) {
+	isps, err := agentISPs(a.ISPs)
+	if err != nil {
+		return false, fmt.Errorf("failed to encode agent ISPs: %w", err)
+	}
+
+	result, err := db.conn.Exec(`UPDATE probe_agents SET name = ?, isps = ? WHERE id = ?`, a.Name, isps, a.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update probe agent: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return count > 0, nil
+}
+
+// RotateProbeAgentKey replaces an agent's key, locking out the old one.
+// Returns "" if the agent does not exist.
+func (db *DB) RotateProbeAgentKey(id int64) (string, error) {
+	key, err := newAgentKey()
+	if err != nil {
+		return "", err
+	}
+
+	result, err := db.conn.Exec(`UPDATE probe_agents SET key_hash = ? WHERE id = ?`, hashToken(key), id)
+	if err != nil {
+		return "", fmt.Errorf("failed to rotate agent key: %w", err)
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		return "", nil
+	}
+	return key, nil
+}
+
+// DeleteProbeAgent removes a probe agent and its observations.
+// Returns false if it does not exist.
+func (db *DB) DeleteProbeAgent(id int64) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM agent_observations WHERE agent_id = ?`, id); err != nil {
+		return false, fmt.Errorf("failed to delete agent observations: %w", err)
+	}
+	result, err := tx.Exec(`DELETE FROM probe_agents WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete probe agent: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return count > 0, tx.Commit()
+}
+
+// RecordAgentResults stores the latest result an agent reported for each
+// endpoint, replacing older ones
+func (db *DB) RecordAgentResults(agentID int64, results []models.AgentResult) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO agent_observations (agent_id, endpoint_id, status, success, rtt_ms, loss_pct, method, checked_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (agent_id, endpoint_id) DO UPDATE SET
+			status = excluded.status, success = excluded.success, rtt_ms = excluded.rtt_ms,
+			loss_pct = excluded.loss_pct, method = excluded.method, checked_at = excluded.checked_at
+		WHERE excluded.checked_at >= agent_observations.checked_at
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare agent results: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, r := range results {
+		rtt := sql.NullFloat64{Float64: r.RTTMs, Valid: r.Success}
+		if _, err := stmt.Exec(agentID, r.EndpointID, r.Status, r.Success, rtt, r.LossPct, nullString(r.Method), r.CheckedAt); err != nil {
+			return fmt.Errorf("failed to record agent result: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAgentObservations returns the results agents reported since the given
+// time, by endpoint ID. Results for endpoints that no longer exist are
+// left out.
+func (db *DB) GetAgentObservations(since time.Time) (map[string][]models.AgentObservation, error) {
+	rows, err := db.conn.Query(`
+		SELECT a.name, o.endpoint_id, o.status, o.success, COALESCE(o.rtt_ms, 0), o.loss_pct, COALESCE(o.method, ''), o.checked_at
+		FROM agent_observations o
+		JOIN probe_agents a ON a.id = o.agent_id
+		JOIN endpoints e ON e.id = o.endpoint_id
+		WHERE o.checked_at >= ?
+		ORDER BY a.name
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get agent observations: %w", err)
+	}
+	defer rows.Close()
+
+	observations := make(map[string][]models.AgentObservation)
+	for rows.Next() {
+		var o models.AgentObservation
+		var checkedAt string
+		if err := rows.Scan(&o.Agent, &o.EndpointID, &o.Status, &o.Success, &o.RTTMs, &o.LossPct, &o.Method, &checkedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan agent observation: %w", err)
+		}
+		o.CheckedAt = parseTime(checkedAt)
+		observations[o.EndpointID] = append(observations[o.EndpointID], o)
+	}
+	return observations, rows.Err()
+}
+
+// CleanupAgentObservations removes results reported for endpoints that no
+// longer exist
+func (db *DB) CleanupAgentObservations() (int, error) {
+	result, err := db.conn.Exec(`DELETE FROM agent_observations WHERE endpoint_id NOT IN (SELECT id FROM endpoints)`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup agent observations: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+func scanProbeAgent(row rowScanner) (*models.ProbeAgent, error) {
+	var a models.ProbeAgent
+	var isps, createdAt string
+	var lastSeen sql.NullString
+	if err := row.Scan(&a.ID, &a.Name, &isps, &createdAt, &lastSeen); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan probe agent: %w", err)
+	}
+	if isps != "" {
+		if err := json.Unmarshal([]byte(isps), &a.ISPs); err != nil {
+			return nil, fmt.Errorf("failed to parse ISPs of agent %s: %w", a.Name, err)
+		}
+	}
+	a.CreatedAt = parseTime(createdAt)
+	if lastSeen.Valid {
+		t := parseTime(lastSeen.String)
+		a.LastSeen = &t
+	}
+	return &a, nil
+}
@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// AuditFilter selects audit log entries. Zero fields match everything.
+type AuditFilter struct {
+	Actor  string
+	Action string // Exact action ("endpoint.delete") or category ("endpoint")
+	Target string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+	Offset int
+}
+
+// RecordAudit appends an entry to the audit log
+func (db *DB) RecordAudit(e models.AuditEntry) error {
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+	var changes sql.NullString
+	if len(e.Changes) > 0 {
+		changes = sql.NullString{String: string(e.Changes), Valid: true}
+	}
+
+	_, err := db.conn.Exec(`
+		INSERT INTO audit_log (timestamp, actor, client_ip, action, target, changes)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, e.Timestamp, e.Actor, nullString(e.ClientIP), e.Action, nullString(e.Target), changes)
+	if err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	return nil
+}
+
+// ListAudit returns matching audit entries, newest first, and the total
+// number of matches for pagination
+func (db *DB) ListAudit(f AuditFilter) ([]models.AuditEntry, int, error) {
+	var conditions []string
+	var args []interface{}
+	if f.Actor != "" {
+		conditions = append(conditions, "actor = ?")
+		args = append(args, f.Actor)
+	}
+	if f.Action != "" {
+		conditions = append(conditions, "(action = ? OR action LIKE ? || '.%')")
+		args = append(args, f.Action, f.Action)
+	}
+	if f.Target != "" {
+		conditions = append(conditions, "target = ?")
+		args = append(args, f.Target)
+	}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, "timestamp >= ?")
+		args = append(args, f.Since.Local()) // Stored timestamps are local time strings
+	}
+	if !f.Until.IsZero() {
+		conditions = append(conditions, "timestamp < ?")
+		args = append(args, f.Until.Local())
+	}
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := db.conn.QueryRow(`SELECT COUNT(*) FROM audit_log `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count audit entries: %w", err)
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT id, timestamp, actor, COALESCE(client_ip, ''), action, COALESCE(target, ''), COALESCE(changes, '')
+		FROM audit_log `+where+`
+		ORDER BY id DESC
+		LIMIT ? OFFSET ?
+	`, append(args, f.Limit, f.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list audit entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		var ts, changes string
+		if err := rows.Scan(&e.ID, &ts, &e.Actor, &e.ClientIP, &e.Action, &e.Target, &changes); err != nil {
+			return nil, 0, fmt.Errorf("failed to scan audit entry: %w", err)
+		}
+		e.Timestamp = parseTime(ts)
+		if changes != "" {
+			e.Changes = []byte(changes)
+		}
+		entries = append(entries, e)
+	}
+	return entries, total, rows.Err()
+}
+
+// RedactAuditAddresses removes endpoint addresses from the audit entries of
+// a target. Entries recorded before endpoint audits left addresses out
+// still hold them.
+func (db *DB) RedactAuditAddresses(target string) (int, error) {
+	result, err := db.conn.Exec(`
+		UPDATE audit_log
+		SET changes = NULLIF(json_remove(changes, '$.ipv4', '$.ipv6', '$.addresses'), '{}')
+		WHERE target = ? AND json_valid(changes)
+			AND (json_type(changes, '$.ipv4') IS NOT NULL
+				OR json_type(changes, '$.ipv6') IS NOT NULL
+				OR json_type(changes, '$.addresses') IS NOT NULL)
+	`, target)
+	if err != nil {
+		return 0, fmt.Errorf("failed to redact audit entries: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
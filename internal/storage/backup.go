@@ -0,0 +1,278 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+	"github.com/mattn/go-sqlite3"
+)
+
+const (
+	backupPrefix     = "ccc-"
+	backupSuffix     = ".db"
+	backupTimeLayout = "20060102-150405.000" // Milliseconds keep manual and scheduled backups apart
+)
+
+// Backup writes a consistent snapshot of the database to path using
+// VACUUM INTO. It is safe while the server is running: the snapshot sees
+// a single point in time, including changes still in the WAL. The snapshot
+// is written to a temporary file first so path never holds a partial copy.
+func (db *DB) Backup(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("backup file %s already exists", path)
+	}
+
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	if _, err := db.conn.Exec(`VACUUM INTO ?`, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to move snapshot into place: %w", err)
+	}
+	return nil
+}
+
+// RotateBackup writes a timestamped backup into dir, then deletes the
+// oldest backups so that at most keep remain (keep <= 0 keeps all)
+func (db *DB) RotateBackup(dir string, keep int) (models.BackupInfo, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return models.BackupInfo{}, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	name := backupPrefix + time.Now().Format(backupTimeLayout) + backupSuffix
+	path := filepath.Join(dir, name)
+	if err := db.Backup(path); err != nil {
+		return models.BackupInfo{}, err
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return models.BackupInfo{}, err
+	}
+	if keep > 0 && len(backups) > keep {
+		// ListBackups is newest first
+		for _, old := range backups[keep:] {
+			if err := os.Remove(filepath.Join(dir, old.Name)); err != nil {
+				return models.BackupInfo{}, fmt.Errorf("failed to remove old backup %s: %w", old.Name, err)
+			}
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return models.BackupInfo{}, fmt.Errorf("failed to stat backup: %w", err)
+	}
+	return models.BackupInfo{Name: name, Size: info.Size(), CreatedAt: info.ModTime()}, nil
+}
+
+// ListBackups returns the rotating backups in dir, newest first
+func ListBackups(dir string) ([]models.BackupInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	var backups []models.BackupInfo
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, models.BackupInfo{Name: name, Size: info.Size(), CreatedAt: info.ModTime()})
+	}
+
+	// Timestamped names sort chronologically
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+// ValidateBackup checks that a file is an intact CCC database whose schema
+// this binary can run, and returns its schema version (0 for a backup from
+// before versioned migrations)
+func ValidateBackup(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, fmt.Errorf("cannot read backup: %w", err)
+	}
+
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer conn.Close()
+
+	var result string
+	if err := conn.QueryRow(`PRAGMA integrity_check`).Scan(&result); err != nil {
+		return 0, fmt.Errorf("backup is not a readable SQLite database: %w", err)
+	}
+	if result != "ok" {
+		return 0, fmt.Errorf("backup failed integrity check: %s", result)
+	}
+
+	var tables int
+	if err := conn.QueryRow(`
+		SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('endpoints', 'settings')
+	`).Scan(&tables); err != nil {
+		return 0, fmt.Errorf("failed to inspect backup: %w", err)
+	}
+	if tables != 2 {
+		return 0, fmt.Errorf("backup is not a CCC database")
+	}
+
+	var hasVersions int
+	conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&hasVersions)
+	if hasVersions == 0 {
+		return 0, nil
+	}
+
+	var version int
+	if err := conn.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read backup schema version: %w", err)
+	}
+	if version > latestVersion() {
+		return version, fmt.Errorf("backup schema version %d is newer than the latest version %d known to this ccc-api", version, latestVersion())
+	}
+	return version, nil
+}
+
+// errDatabaseInUse is returned by Restore when another process, such as a
+// running server, has the database open
+var errDatabaseInUse = errors.New("database is in use; stop the server before restoring")
+
+// Restore replaces the database at dbPath with a validated backup. The
+// current database is first saved next to it as <dbPath>.pre-restore-<time>,
+// whose path is returned. If it is too damaged to snapshot, its raw files
+// are copied there instead. Restore refuses to run while the server has
+// the database open.
+func Restore(backupPath, dbPath string) (string, error) {
+	if _, err := ValidateBackup(backupPath); err != nil {
+		return "", err
+	}
+
+	// Stage the copy beside the target so the final rename is atomic
+	staged := dbPath + ".restore-tmp"
+	if err := copyFile(backupPath, staged); err != nil {
+		os.Remove(staged)
+		return "", fmt.Errorf("failed to stage backup: %w", err)
+	}
+
+	var previous string
+	if _, err := os.Stat(dbPath); err == nil {
+		previous = dbPath + ".pre-restore-" + time.Now().Format(backupTimeLayout)
+
+		// The lock is held until the backup is in place
+		lock, err := lockDatabase(dbPath)
+		if errors.Is(err, errDatabaseInUse) {
+			os.Remove(staged)
+			return "", err
+		}
+		if lock != nil {
+			defer lock.Close()
+		}
+
+		// Keep the current database, including anything still in its WAL
+		if err == nil {
+			err = (&DB{conn: lock}).Backup(previous)
+		}
+		if err != nil {
+			log.Printf("Cannot snapshot current database (%v); keeping its raw files", err)
+			if err := copyDatabaseFiles(dbPath, previous); err != nil {
+				os.Remove(staged)
+				return "", fmt.Errorf("failed to save current database: %w", err)
+			}
+		}
+	}
+
+	// A stale WAL would be replayed onto the restored file
+	os.Remove(dbPath + "-wal")
+	os.Remove(dbPath + "-shm")
+	if err := os.Rename(staged, dbPath); err != nil {
+		os.Remove(staged)
+		return previous, fmt.Errorf("failed to move backup into place: %w", err)
+	}
+	return previous, nil
+}
+
+// lockDatabase opens the database at dbPath holding an exclusive lock
+// until the connection is closed. It returns errDatabaseInUse if another
+// connection has the database open, and an error along with no connection
+// if the file is not a readable database.
+func lockDatabase(dbPath string) (*sql.DB, error) {
+	conn, err := sql.Open("sqlite3", dbPath+"?_locking_mode=EXCLUSIVE&_busy_timeout=1000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open current database: %w", err)
+	}
+	// In exclusive locking mode the lock stays with this one connection
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(`BEGIN EXCLUSIVE`)
+	if err == nil {
+		_, err = conn.Exec(`COMMIT`)
+	}
+	if err != nil {
+		conn.Close()
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked) {
+			return nil, errDatabaseInUse
+		}
+		return nil, fmt.Errorf("failed to lock current database: %w", err)
+	}
+	return conn, nil
+}
+
+// copyDatabaseFiles copies a database file and its WAL and shared memory
+// files, if any, byte for byte
+func copyDatabaseFiles(src, dst string) error {
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if _, err := os.Stat(src + suffix); err != nil {
+			continue
+		}
+		if err := copyFile(src+suffix, dst+suffix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -10,11 +10,29 @@ import (
 
 // DB wraps the SQLite database connection
 type DB struct {
-	conn *sql.DB
+	conn      *sql.DB
+	retention HistoryRetention
 }
 
-// New creates a new database connection and runs migrations
+// New opens the database and applies any pending migrations
 func New(dbPath string) (*DB, error) {
+	db, err := Open(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Migrate(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	log.Printf("Database initialized at %s", dbPath)
+	return db, nil
+}
+
+// Open opens the database without migrating it. It fails if the schema was
+// migrated by a newer version of ccc-api.
+func Open(dbPath string) (*DB, error) {
 	conn, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -26,15 +44,12 @@ func New(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	db := &DB{conn: conn}
+	db := &DB{conn: conn, retention: DefaultHistoryRetention()}
 
-	// Run migrations
-	if err := db.migrate(); err != nil {
+	if _, err := db.checkSchemaVersion(); err != nil {
 		conn.Close()
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
+		return nil, err
 	}
-
-	log.Printf("Database initialized at %s", dbPath)
 	return db, nil
 }
 
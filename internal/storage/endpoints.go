@@ -5,13 +5,27 @@ import (
 	"database/sql"
 	"encoding/hex"
 	"fmt"
+	"net"
 	"time"
 
 	"github.com/jonsson/ccc/internal/models"
 )
 
-// HashIP creates a SHA256 hash of an IP address
+// endpointColumns is the column list read by scanEndpoint
+const endpointColumns = `id, ip, COALESCE(secondary_ip, ''), ip_hash, isp, status, created_at, last_seen, last_ok,
+		       COALESCE(monitored_hop, ''), COALESCE(hop_number, 0), COALESCE(use_hop, 0), COALESCE(paused_until, ''),
+		       COALESCE(probe_strategy, '')`
+
+// notPaused matches endpoints that are being monitored. paused_until is
+// stored in UTC so that it compares with datetime('now').
+const notPaused = `(paused_until IS NULL OR paused_until <= datetime('now'))`
+
+// HashIP creates a SHA256 hash of an IP address.
+// Addresses are canonicalised first so IPv6 spellings hash identically.
 func HashIP(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil {
+		ip = parsed.String()
+	}
 	h := sha256.Sum256([]byte(ip))
 	return hex.EncodeToString(h[:])
 }
@@ -19,27 +33,35 @@ func HashIP(ip string) string {
 // FindByIPHash finds an endpoint by its IP hash
 func (db *DB) FindByIPHash(ipHash string) (*models.Endpoint, error) {
 	row := db.conn.QueryRow(`
-		SELECT id, ipv4, ip_hash, isp, status, created_at, last_seen, last_ok,
-		       COALESCE(monitored_hop, ''), COALESCE(hop_number, 0), COALESCE(use_hop, 0)
-		FROM endpoints WHERE ip_hash = ?
-	`, ipHash)
+		SELECT `+endpointColumns+`
+		FROM endpoints WHERE ip_hash = ? OR secondary_ip_hash = ?
+	`, ipHash, ipHash)
 
-	var e models.Endpoint
-	var lastOK sql.NullTime
-	var useHopInt int
-	err := row.Scan(&e.ID, &e.IPv4, &e.IPHash, &e.ISP, &e.Status, &e.CreatedAt, &e.LastSeen, &lastOK,
-		&e.MonitoredHop, &e.HopNumber, &useHopInt)
+	e, err := scanEndpoint(row)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to find endpoint: %w", err)
 	}
-	if lastOK.Valid {
-		e.LastOK = lastOK.Time
+	return e, nil
+}
+
+// FindByID finds an endpoint by its ID
+func (db *DB) FindByID(id string) (*models.Endpoint, error) {
+	row := db.conn.QueryRow(`
+		SELECT `+endpointColumns+`
+		FROM endpoints WHERE id = ?
+	`, id)
+
+	e, err := scanEndpoint(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
-	e.UseHop = useHopInt != 0
-	return &e, nil
+	if err != nil {
+		return nil, fmt.Errorf("failed to find endpoint: %w", err)
+	}
+	return e, nil
 }
 
 // FindByIP finds an endpoint by its IP address
@@ -49,7 +71,7 @@ func (db *DB) FindByIP(ip string) (*models.Endpoint, error) {
 
 // Create inserts a new endpoint
 func (db *DB) Create(e *models.Endpoint) error {
-	e.IPHash = HashIP(e.IPv4)
+	e.IPHash = HashIP(e.IP)
 	if e.CreatedAt.IsZero() {
 		e.CreatedAt = time.Now()
 	}
@@ -57,7 +79,7 @@ func (db *DB) Create(e *models.Endpoint) error {
 		e.LastSeen = time.Now()
 	}
 	if e.Status == "" {
-		e.Status = "unknown"
+		e.Status = models.StatusUnknown
 	}
 
 	useHopInt := 0
@@ -66,12 +88,13 @@ func (db *DB) Create(e *models.Endpoint) error {
 	}
 
 	_, err := db.conn.Exec(`
-		INSERT INTO endpoints (id, ipv4, ip_hash, isp, status, created_at, last_seen, last_ok, monitored_hop, hop_number, use_hop)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, e.ID, e.IPv4, e.IPHash, e.ISP, e.Status, e.CreatedAt, e.LastSeen,
+		INSERT INTO endpoints (id, ip, ip_hash, secondary_ip, secondary_ip_hash, isp, status, created_at, last_seen, last_ok, monitored_hop, hop_number, use_hop, probe_strategy)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, e.ID, e.IP, e.IPHash, nullString(e.SecondaryIP), nullString(secondaryHash(e.SecondaryIP)),
+		e.ISP, e.Status, e.CreatedAt, e.LastSeen,
 		sql.NullTime{Time: e.LastOK, Valid: !e.LastOK.IsZero()},
 		sql.NullString{String: e.MonitoredHop, Valid: e.MonitoredHop != ""},
-		e.HopNumber, useHopInt)
+		e.HopNumber, useHopInt, nullString(e.ProbeStrategy))
 	if err != nil {
 		return fmt.Errorf("failed to create endpoint: %w", err)
 	}
@@ -79,7 +102,7 @@ func (db *DB) Create(e *models.Endpoint) error {
 }
 
 // UpdateStatus updates the status of an endpoint
-func (db *DB) UpdateStatus(id, status string, lastOK time.Time) error {
+func (db *DB) UpdateStatus(id string, status models.EndpointStatus, lastOK time.Time) error {
 	var lastOKVal sql.NullTime
 	if !lastOK.IsZero() {
 		lastOKVal = sql.NullTime{Time: lastOK, Valid: true}
@@ -109,8 +132,7 @@ func (db *DB) UpdateLastSeen(id string) error {
 // ListByISP returns all endpoints for a given ISP
 func (db *DB) ListByISP(isp string) ([]models.Endpoint, error) {
 	rows, err := db.conn.Query(`
-		SELECT id, ipv4, ip_hash, isp, status, created_at, last_seen, last_ok,
-		       COALESCE(monitored_hop, ''), COALESCE(hop_number, 0), COALESCE(use_hop, 0)
+		SELECT `+endpointColumns+`
 		FROM endpoints WHERE isp = ?
 	`, isp)
 	if err != nil {
@@ -124,8 +146,7 @@ func (db *DB) ListByISP(isp string) ([]models.Endpoint, error) {
 // ListAll returns all endpoints
 func (db *DB) ListAll() ([]models.Endpoint, error) {
 	rows, err := db.conn.Query(`
-		SELECT id, ipv4, ip_hash, isp, status, created_at, last_seen, last_ok,
-		       COALESCE(monitored_hop, ''), COALESCE(hop_number, 0), COALESCE(use_hop, 0)
+		SELECT ` + endpointColumns + `
 		FROM endpoints
 	`)
 	if err != nil {
@@ -136,26 +157,131 @@ func (db *DB) ListAll() ([]models.Endpoint, error) {
 	return scanEndpoints(rows)
 }
 
+// rowScanner is satisfied by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanEndpoint scans one row selected with endpointColumns
+func scanEndpoint(row rowScanner) (*models.Endpoint, error) {
+	var e models.Endpoint
+	var lastOK sql.NullTime
+	var useHopInt int
+	var pausedUntil string
+	if err := row.Scan(&e.ID, &e.IP, &e.SecondaryIP, &e.IPHash, &e.ISP, &e.Status, &e.CreatedAt, &e.LastSeen, &lastOK,
+		&e.MonitoredHop, &e.HopNumber, &useHopInt, &pausedUntil, &e.ProbeStrategy); err != nil {
+		return nil, err
+	}
+	if lastOK.Valid {
+		e.LastOK = lastOK.Time
+	}
+	if pausedUntil != "" {
+		t := parseTime(pausedUntil)
+		e.PausedUntil = &t
+	}
+	e.UseHop = useHopInt != 0
+	return &e, nil
+}
+
 // scanEndpoints is a helper to scan endpoint rows
 func scanEndpoints(rows *sql.Rows) ([]models.Endpoint, error) {
 	var endpoints []models.Endpoint
 	for rows.Next() {
-		var e models.Endpoint
-		var lastOK sql.NullTime
-		var useHopInt int
-		if err := rows.Scan(&e.ID, &e.IPv4, &e.IPHash, &e.ISP, &e.Status, &e.CreatedAt, &e.LastSeen, &lastOK,
-			&e.MonitoredHop, &e.HopNumber, &useHopInt); err != nil {
+		e, err := scanEndpoint(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan endpoint: %w", err)
 		}
-		if lastOK.Valid {
-			e.LastOK = lastOK.Time
-		}
-		e.UseHop = useHopInt != 0
-		endpoints = append(endpoints, e)
+		endpoints = append(endpoints, *e)
 	}
 	return endpoints, nil
 }
 
+// SetSecondaryIP attaches the other address family of a dual-stack resident
+// to an endpoint. An empty ip removes the secondary address.
+func (db *DB) SetSecondaryIP(id, ip string) error {
+	_, err := db.conn.Exec(`
+		UPDATE endpoints SET secondary_ip = ?, secondary_ip_hash = ?
+		WHERE id = ?
+	`, nullString(ip), nullString(secondaryHash(ip)), id)
+	if err != nil {
+		return fmt.Errorf("failed to set secondary address: %w", err)
+	}
+	return nil
+}
+
+// ReassignAddress replaces the primary (or secondary) address of an endpoint
+// after the resident's ISP rotated it, keeping the endpoint ID and history.
+// Status and hop monitoring start over: the path to the new address is unknown.
+func (db *DB) ReassignAddress(id string, secondary bool, ip, isp string) error {
+	ipColumn, hashColumn := "ip", "ip_hash"
+	if secondary {
+		ipColumn, hashColumn = "secondary_ip", "secondary_ip_hash"
+	}
+
+	_, err := db.conn.Exec(`
+		UPDATE endpoints SET `+ipColumn+` = ?, `+hashColumn+` = ?, isp = ?, status = ?,
+			monitored_hop = NULL, hop_number = 0, use_hop = 0, last_seen = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, ip, HashIP(ip), isp, models.StatusUnknown, id)
+	if err != nil {
+		return fmt.Errorf("failed to reassign address: %w", err)
+	}
+	return nil
+}
+
+// SetEndpointISP changes the ISP an endpoint is counted under, e.g. after
+// the ASN mappings changed
+func (db *DB) SetEndpointISP(id, isp string) error {
+	_, err := db.conn.Exec(`UPDATE endpoints SET isp = ? WHERE id = ?`, isp, id)
+	if err != nil {
+		return fmt.Errorf("failed to set endpoint ISP: %w", err)
+	}
+	return nil
+}
+
+// SetProbeStrategy sets the probe specs tried for an endpoint, in the form
+// models.FormatProbeStrategy writes. An empty strategy uses the default.
+func (db *DB) SetProbeStrategy(id, strategy string) (bool, error) {
+	result, err := db.conn.Exec(`UPDATE endpoints SET probe_strategy = ? WHERE id = ?`, nullString(strategy), id)
+	if err != nil {
+		return false, fmt.Errorf("failed to set probe strategy: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// secondaryHash hashes an optional address, returning "" when absent
+func secondaryHash(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return HashIP(ip)
+}
+
+// nullString stores empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// SetPausedUntil pauses monitoring of an endpoint until the given time, or
+// resumes it when until is nil. The status is reset to unknown so the gap
+// is not reported as the endpoint going down or recovering.
+func (db *DB) SetPausedUntil(id string, until *time.Time) error {
+	var untilVal sql.NullString
+	if until != nil {
+		untilVal = sql.NullString{String: until.UTC().Format("2006-01-02 15:04:05"), Valid: true}
+	}
+
+	_, err := db.conn.Exec(`
+		UPDATE endpoints SET paused_until = ?, status = ?
+		WHERE id = ?
+	`, untilVal, models.StatusUnknown, id)
+	if err != nil {
+		return fmt.Errorf("failed to set pause: %w", err)
+	}
+	return nil
+}
+
 // UpdateMonitoredHop updates the hop being monitored for an endpoint
 func (db *DB) UpdateMonitoredHop(id, hopIP string, hopNumber int) error {
 	useHop := 0
@@ -173,31 +299,35 @@ func (db *DB) UpdateMonitoredHop(id, hopIP string, hopNumber int) error {
 	return nil
 }
 
-// GetEndpointsByMonitoredHop returns all endpoints monitoring the same hop
-func (db *DB) GetEndpointsByMonitoredHop(hopIP string) ([]models.Endpoint, error) {
+// DeleteExpired removes endpoints not seen in the specified number of days
+// and returns the removed endpoints. A paused endpoint is not probed, so
+// its days are counted from the end of the pause.
+func (db *DB) DeleteExpired(maxAgeDays int) ([]models.Endpoint, error) {
 	rows, err := db.conn.Query(`
-		SELECT id, ipv4, ip_hash, isp, status, created_at, last_seen, last_ok,
-		       COALESCE(monitored_hop, ''), COALESCE(hop_number, 0), COALESCE(use_hop, 0)
-		FROM endpoints WHERE monitored_hop = ?
-	`, hopIP)
+		SELECT `+endpointColumns+`
+		FROM endpoints WHERE last_seen < datetime('now', '-' || ? || ' days')
+		  AND (paused_until IS NULL OR paused_until < datetime('now', '-' || ? || ' days'))
+	`, maxAgeDays, maxAgeDays)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list endpoints by hop: %w", err)
+		return nil, fmt.Errorf("failed to find expired endpoints: %w", err)
 	}
-	defer rows.Close()
-
-	return scanEndpoints(rows)
-}
-
-// DeleteExpired removes endpoints not seen in the specified number of days
-func (db *DB) DeleteExpired(maxAgeDays int) (int, error) {
-	result, err := db.conn.Exec(`
-		DELETE FROM endpoints WHERE last_seen < datetime('now', '-' || ? || ' days')
-	`, maxAgeDays)
+	expired, err := scanEndpoints(rows)
+	rows.Close()
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete expired endpoints: %w", err)
+		return nil, err
 	}
-	count, _ := result.RowsAffected()
-	return int(count), nil
+
+	var deleted []models.Endpoint
+	for _, e := range expired {
+		ok, err := db.DeleteByID(e.ID)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete expired endpoint %s: %w", e.ID, err)
+		}
+		if ok {
+			deleted = append(deleted, e)
+		}
+	}
+	return deleted, nil
 }
 
 // DeleteByID removes an endpoint by its ID
@@ -207,6 +337,11 @@ func (db *DB) DeleteByID(id string) (bool, error) {
 		return false, fmt.Errorf("failed to delete endpoint: %w", err)
 	}
 	count, _ := result.RowsAffected()
+	if count > 0 {
+		if err := db.DeleteEndpointTokens(id); err != nil {
+			return true, err
+		}
+	}
 	return count > 0, nil
 }
 
@@ -216,13 +351,15 @@ func (db *DB) GetISPStats() ([]models.ISPStatus, error) {
 		SELECT
 			isp,
 			COUNT(*) as total,
-			SUM(CASE WHEN status = 'up' THEN 1 ELSE 0 END) as up_count,
-			SUM(CASE WHEN status = 'down' THEN 1 ELSE 0 END) as down_count,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) as up_count,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) as degraded_count,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) as down_count,
 			MAX(last_seen) as last_updated
 		FROM endpoints
+		WHERE `+notPaused+`
 		GROUP BY isp
 		ORDER BY total DESC
-	`)
+	`, models.StatusUp, models.StatusDegraded, models.StatusDown)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get ISP stats: %w", err)
 	}
@@ -232,7 +369,7 @@ func (db *DB) GetISPStats() ([]models.ISPStatus, error) {
 	for rows.Next() {
 		var s models.ISPStatus
 		var lastUpdatedStr string
-		if err := rows.Scan(&s.Name, &s.TotalCount, &s.UpCount, &s.DownCount, &lastUpdatedStr); err != nil {
+		if err := rows.Scan(&s.Name, &s.TotalCount, &s.UpCount, &s.Degraded, &s.DownCount, &lastUpdatedStr); err != nil {
 			return nil, fmt.Errorf("failed to scan ISP stats: %w", err)
 		}
 		s.LastUpdated = parseTime(lastUpdatedStr)
@@ -247,17 +384,18 @@ func (db *DB) GetISPStatusByName(isp string) (*models.ISPStatus, error) {
 		SELECT
 			isp,
 			COUNT(*) as total,
-			SUM(CASE WHEN status = 'up' THEN 1 ELSE 0 END) as up_count,
-			SUM(CASE WHEN status = 'down' THEN 1 ELSE 0 END) as down_count,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) as up_count,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) as degraded_count,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) as down_count,
 			MAX(last_seen) as last_updated
 		FROM endpoints
-		WHERE isp = ?
+		WHERE isp = ? AND `+notPaused+`
 		GROUP BY isp
-	`, isp)
+	`, models.StatusUp, models.StatusDegraded, models.StatusDown, isp)
 
 	var s models.ISPStatus
 	var lastUpdatedStr string
-	err := row.Scan(&s.Name, &s.TotalCount, &s.UpCount, &s.DownCount, &lastUpdatedStr)
+	err := row.Scan(&s.Name, &s.TotalCount, &s.UpCount, &s.Degraded, &s.DownCount, &lastUpdatedStr)
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// Uptime history tiers. Raw snapshots are compacted into progressively
+// coarser buckets so long windows stay cheap to store and query.
+const (
+	TierRaw    = "raw"
+	Tier5Min   = "5m"
+	TierHourly = "1h"
+	TierDaily  = "1d"
+	overallISP = "" // ISP value used for building-wide snapshots
+)
+
+// rollupTiers lists the bucketed tiers in rollup order; each is built from
+// the one before it (5m from raw, 1h from 5m, 1d from 1h)
+var rollupTiers = []struct {
+	name   string
+	width  time.Duration
+	source string
+}{
+	{Tier5Min, 5 * time.Minute, TierRaw},
+	{TierHourly, time.Hour, Tier5Min},
+	{TierDaily, 24 * time.Hour, TierHourly},
+}
+
+// HistoryRetention configures how long each uptime history tier is kept
+type HistoryRetention struct {
+	Raw     time.Duration
+	FiveMin time.Duration
+	Hourly  time.Duration
+	Daily   time.Duration
+}
+
+// DefaultHistoryRetention returns the default retention per tier
+func DefaultHistoryRetention() HistoryRetention {
+	return HistoryRetention{
+		Raw:     48 * time.Hour,
+		FiveMin: 14 * 24 * time.Hour,
+		Hourly:  90 * 24 * time.Hour,
+		Daily:   2 * 365 * 24 * time.Hour,
+	}
+}
+
+// SetHistoryRetention overrides the retention per tier
+func (db *DB) SetHistoryRetention(r HistoryRetention) {
+	db.retention = r
+}
+
+// historyTierFor picks the finest tier that still covers the window and
+// keeps the number of points reasonable for a chart
+func (db *DB) historyTierFor(window time.Duration) string {
+	switch {
+	case window <= 6*time.Hour && window <= db.retention.Raw:
+		return TierRaw
+	case window <= 3*24*time.Hour && window <= db.retention.FiveMin:
+		return Tier5Min
+	case window <= 90*24*time.Hour && window <= db.retention.Hourly:
+		return TierHourly
+	default:
+		return TierDaily
+	}
+}
+
+// GetISPUptimeHistory returns uptime history for one ISP over the window,
+// read from the tier that best fits its length
+func (db *DB) GetISPUptimeHistory(isp string, since time.Duration) ([]models.UptimePoint, error) {
+	cutoff := time.Now().Add(-since)
+	tier := db.historyTierFor(since)
+	if tier == TierRaw {
+		return db.getRawUptimeHistory(isp, cutoff)
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT bucket, samples, up_sum, down_sum
+		FROM uptime_rollups
+		WHERE tier = ? AND isp = ? AND bucket >= ?
+		ORDER BY bucket ASC
+	`, tier, isp, cutoff.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get uptime history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.UptimePoint
+	for rows.Next() {
+		var p models.UptimePoint
+		var ts string
+		var samples, upSum, downSum int
+		if err := rows.Scan(&ts, &samples, &upSum, &downSum); err != nil {
+			return nil, fmt.Errorf("failed to scan history row: %w", err)
+		}
+		p.Timestamp = parseTime(ts)
+		if samples > 0 {
+			p.Up = int(math.Round(float64(upSum) / float64(samples)))
+			p.Down = int(math.Round(float64(downSum) / float64(samples)))
+		}
+		if upSum+downSum > 0 {
+			p.UptimePct = float64(upSum) / float64(upSum+downSum) * 100
+		}
+		history = append(history, p)
+	}
+	return history, nil
+}
+
+// getRawUptimeHistory returns unaggregated snapshots newer than cutoff
+func (db *DB) getRawUptimeHistory(isp string, cutoff time.Time) ([]models.UptimePoint, error) {
+	rows, err := db.conn.Query(`
+		SELECT timestamp, endpoints_up, endpoints_down
+		FROM uptime_history
+		WHERE isp = ? AND timestamp > ?
+		ORDER BY timestamp ASC
+	`, isp, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get uptime history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []models.UptimePoint
+	for rows.Next() {
+		var p models.UptimePoint
+		var ts string
+		if err := rows.Scan(&ts, &p.Up, &p.Down); err != nil {
+			return nil, fmt.Errorf("failed to scan history row: %w", err)
+		}
+		p.Timestamp = parseTime(ts)
+		total := p.Up + p.Down
+		if total > 0 {
+			p.UptimePct = float64(p.Up) / float64(total) * 100
+		}
+		history = append(history, p)
+	}
+	return history, nil
+}
+
+// rollupKey identifies one bucket of one ISP
+type rollupKey struct {
+	bucket time.Time
+	isp    string
+}
+
+// rollupSums accumulates samples for one bucket
+type rollupSums struct {
+	samples, total, up, down int
+}
+
+// RollupUptimeHistory compacts complete buckets of each tier from the tier
+// below it. Each run continues from the newest bucket already written, so
+// it is cheap to call after every ping cycle.
+func (db *DB) RollupUptimeHistory(now time.Time) error {
+	for _, tier := range rollupTiers {
+		start, err := db.rollupStart(tier.name, tier.source, tier.width)
+		if err != nil {
+			return err
+		}
+		end := now.UTC().Truncate(tier.width)
+		if start.IsZero() || !start.Before(end) {
+			continue
+		}
+
+		sums, err := db.readRollupSource(tier.source, tier.width, start, end)
+		if err != nil {
+			return err
+		}
+		if err := db.writeRollups(tier.name, sums); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollupStart returns the first bucket of a tier that still needs to be
+// built, or the zero time if its source tier has no data
+func (db *DB) rollupStart(tier, source string, width time.Duration) (time.Time, error) {
+	var latest sql.NullString
+	if err := db.conn.QueryRow(`SELECT MAX(bucket) FROM uptime_rollups WHERE tier = ?`, tier).Scan(&latest); err != nil {
+		return time.Time{}, fmt.Errorf("failed to read %s rollup watermark: %w", tier, err)
+	}
+	if latest.Valid {
+		return parseTime(latest.String).UTC().Add(width), nil
+	}
+
+	var earliest sql.NullString
+	var err error
+	if source == TierRaw {
+		err = db.conn.QueryRow(`SELECT MIN(timestamp) FROM uptime_history`).Scan(&earliest)
+	} else {
+		err = db.conn.QueryRow(`SELECT MIN(bucket) FROM uptime_rollups WHERE tier = ?`, source).Scan(&earliest)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read %s history start: %w", source, err)
+	}
+	if !earliest.Valid {
+		return time.Time{}, nil
+	}
+	return parseTime(earliest.String).UTC().Truncate(width), nil
+}
+
+// readRollupSource sums source rows in [start, end) into buckets of width
+func (db *DB) readRollupSource(source string, width time.Duration, start, end time.Time) (map[rollupKey]*rollupSums, error) {
+	var rows *sql.Rows
+	var err error
+	if source == TierRaw {
+		rows, err = db.conn.Query(`
+			SELECT timestamp, isp, 1, total_endpoints, endpoints_up, endpoints_down
+			FROM uptime_history
+			WHERE timestamp >= ? AND timestamp < ?
+		`, start.In(time.Local), end.In(time.Local))
+	} else {
+		rows, err = db.conn.Query(`
+			SELECT bucket, isp, samples, total_sum, up_sum, down_sum
+			FROM uptime_rollups
+			WHERE tier = ? AND bucket >= ? AND bucket < ?
+		`, source, start, end)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s history: %w", source, err)
+	}
+	defer rows.Close()
+
+	sums := make(map[rollupKey]*rollupSums)
+	for rows.Next() {
+		var ts, isp string
+		var r rollupSums
+		if err := rows.Scan(&ts, &isp, &r.samples, &r.total, &r.up, &r.down); err != nil {
+			return nil, fmt.Errorf("failed to scan %s history row: %w", source, err)
+		}
+		key := rollupKey{bucket: parseTime(ts).UTC().Truncate(width), isp: isp}
+		acc, ok := sums[key]
+		if !ok {
+			acc = &rollupSums{}
+			sums[key] = acc
+		}
+		acc.samples += r.samples
+		acc.total += r.total
+		acc.up += r.up
+		acc.down += r.down
+	}
+	return sums, nil
+}
+
+// writeRollups upserts bucket sums for a tier in one transaction
+func (db *DB) writeRollups(tier string, sums map[rollupKey]*rollupSums) error {
+	if len(sums) == 0 {
+		return nil
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin rollup: %w", err)
+	}
+	defer tx.Rollback()
+
+	for key, s := range sums {
+		if _, err := tx.Exec(`
+			INSERT INTO uptime_rollups (tier, bucket, isp, samples, total_sum, up_sum, down_sum)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(tier, isp, bucket) DO UPDATE SET
+				samples = excluded.samples,
+				total_sum = excluded.total_sum,
+				up_sum = excluded.up_sum,
+				down_sum = excluded.down_sum
+		`, tier, key.bucket, key.isp, s.samples, s.total, s.up, s.down); err != nil {
+			return fmt.Errorf("failed to write %s rollup: %w", tier, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit %s rollup: %w", tier, err)
+	}
+	return nil
+}
+
+// CleanupHistoryTiers applies the configured retention to raw snapshots
+// and every rollup tier. Daily availability totals are kept as long as the
+// daily tier. Returns the total number of rows removed.
+func (db *DB) CleanupHistoryTiers() (int, error) {
+	deleted, err := db.CleanupOldHistory(db.retention.Raw)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := db.cleanupOldAvailability(db.retention.Daily)
+	if err != nil {
+		return deleted, err
+	}
+	deleted += count
+
+	retention := map[string]time.Duration{
+		Tier5Min:   db.retention.FiveMin,
+		TierHourly: db.retention.Hourly,
+		TierDaily:  db.retention.Daily,
+	}
+	for tier, maxAge := range retention {
+		cutoff := time.Now().Add(-maxAge).UTC()
+		result, err := db.conn.Exec(`DELETE FROM uptime_rollups WHERE tier = ? AND bucket < ?`, tier, cutoff)
+		if err != nil {
+			return deleted, fmt.Errorf("failed to cleanup %s history: %w", tier, err)
+		}
+		count, _ := result.RowsAffected()
+		deleted += int(count)
+	}
+	return deleted, nil
+}
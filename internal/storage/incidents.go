@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+const incidentColumns = `id, isp, started_at, ended_at, peak_affected, total_endpoints,
+	detection_method, COALESCE(shared_hop, ''), COALESCE(note, '')`
+
+// OpenIncident starts a new incident for an ISP and returns its ID
+func (db *DB) OpenIncident(isp, method, sharedHop string, affected, total int) (int64, error) {
+	result, err := db.conn.Exec(`
+		INSERT INTO incidents (isp, started_at, peak_affected, total_endpoints, detection_method, shared_hop)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, isp, time.Now(), affected, total, method, nullString(sharedHop))
+	if err != nil {
+		return 0, fmt.Errorf("failed to open incident: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// UpdateIncidentPeak raises the peak affected count of an open incident
+func (db *DB) UpdateIncidentPeak(id int64, affected, total int) error {
+	_, err := db.conn.Exec(`
+		UPDATE incidents SET peak_affected = ?, total_endpoints = ?
+		WHERE id = ? AND ended_at IS NULL AND peak_affected < ?
+	`, affected, total, id, affected)
+	if err != nil {
+		return fmt.Errorf("failed to update incident: %w", err)
+	}
+	return nil
+}
+
+// CloseIncident marks an incident as recovered
+func (db *DB) CloseIncident(id int64) error {
+	_, err := db.conn.Exec(`
+		UPDATE incidents SET ended_at = ? WHERE id = ? AND ended_at IS NULL
+	`, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("failed to close incident: %w", err)
+	}
+	return nil
+}
+
+// GetOpenIncidents returns ongoing ISP incidents keyed by ISP. Monitor
+// offline incidents are left out; see GetOpenMonitorIncident.
+func (db *DB) GetOpenIncidents() (map[string]models.Incident, error) {
+	rows, err := db.conn.Query(`SELECT `+incidentColumns+` FROM incidents WHERE ended_at IS NULL AND detection_method != ?`,
+		models.DetectionMonitorOffline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open incidents: %w", err)
+	}
+	defer rows.Close()
+
+	incidents, err := scanIncidents(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	open := make(map[string]models.Incident, len(incidents))
+	for _, inc := range incidents {
+		open[inc.ISP] = inc
+	}
+	return open, nil
+}
+
+// GetOpenMonitorIncident returns the ongoing monitor offline incident, or
+// nil if the monitoring server is online
+func (db *DB) GetOpenMonitorIncident() (*models.Incident, error) {
+	rows, err := db.conn.Query(`SELECT `+incidentColumns+` FROM incidents WHERE ended_at IS NULL AND detection_method = ?`,
+		models.DetectionMonitorOffline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get open monitor incident: %w", err)
+	}
+	defer rows.Close()
+
+	incidents, err := scanIncidents(rows)
+	if err != nil || len(incidents) == 0 {
+		return nil, err
+	}
+	return &incidents[0], nil
+}
+
+// ListIncidents returns the most recent incidents, newest first.
+// An empty isp lists incidents for all ISPs.
+func (db *DB) ListIncidents(isp string, limit int) ([]models.Incident, error) {
+	rows, err := db.conn.Query(`
+		SELECT `+incidentColumns+`
+		FROM incidents
+		WHERE ? = '' OR isp = ?
+		ORDER BY started_at DESC
+		LIMIT ?
+	`, isp, isp, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incidents: %w", err)
+	}
+	defer rows.Close()
+
+	return scanIncidents(rows)
+}
+
+// listIncidentsBetween returns the ISP incidents that overlap from..to.
+// An empty isp lists incidents for all ISPs.
+func (db *DB) listIncidentsBetween(isp string, from, to time.Time) ([]models.Incident, error) {
+	// Stored times may carry another UTC offset, so the query is widened
+	// by a day and the range applied exactly below
+	rows, err := db.conn.Query(`
+		SELECT `+incidentColumns+`
+		FROM incidents
+		WHERE isp != '' AND (? = '' OR isp = ?)
+		  AND started_at < ? AND (ended_at IS NULL OR ended_at > ?)
+		ORDER BY started_at
+	`, isp, isp, to.Add(24*time.Hour), from.Add(-24*time.Hour))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list incidents: %w", err)
+	}
+	defer rows.Close()
+
+	incidents, err := scanIncidents(rows)
+	if err != nil {
+		return nil, err
+	}
+	var overlapping []models.Incident
+	for _, inc := range incidents {
+		if inc.StartedAt.Before(to) && (inc.EndedAt == nil || inc.EndedAt.After(from)) {
+			overlapping = append(overlapping, inc)
+		}
+	}
+	return overlapping, nil
+}
+
+// GetIncident returns a single incident, or nil if it does not exist
+func (db *DB) GetIncident(id int64) (*models.Incident, error) {
+	rows, err := db.conn.Query(`SELECT `+incidentColumns+` FROM incidents WHERE id = ?`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get incident: %w", err)
+	}
+	defer rows.Close()
+
+	incidents, err := scanIncidents(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(incidents) == 0 {
+		return nil, nil
+	}
+	return &incidents[0], nil
+}
+
+// AnnotateIncident sets the operator note on an incident.
+// Returns false if the incident does not exist.
+func (db *DB) AnnotateIncident(id int64, note string) (bool, error) {
+	result, err := db.conn.Exec(`UPDATE incidents SET note = ? WHERE id = ?`, nullString(note), id)
+	if err != nil {
+		return false, fmt.Errorf("failed to annotate incident: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return count > 0, nil
+}
+
+// scanIncidents is a helper to scan incident rows and compute durations
+func scanIncidents(rows *sql.Rows) ([]models.Incident, error) {
+	now := time.Now()
+	var incidents []models.Incident
+	for rows.Next() {
+		var inc models.Incident
+		var startedAt string
+		var endedAt sql.NullString
+		if err := rows.Scan(&inc.ID, &inc.ISP, &startedAt, &endedAt, &inc.PeakAffected,
+			&inc.TotalEndpoints, &inc.DetectionMethod, &inc.SharedHop, &inc.Note); err != nil {
+			return nil, fmt.Errorf("failed to scan incident: %w", err)
+		}
+		inc.StartedAt = parseTime(startedAt)
+
+		end := now
+		if endedAt.Valid {
+			t := parseTime(endedAt.String)
+			inc.EndedAt = &t
+			end = t
+		} else {
+			inc.Ongoing = true
+		}
+		inc.DurationSeconds = int64(end.Sub(inc.StartedAt).Seconds())
+		incidents = append(incidents, inc)
+	}
+	return incidents, rows.Err()
+}
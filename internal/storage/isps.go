@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// ListISPs returns all ISP definitions, ordered by display name
+func (db *DB) ListISPs() ([]models.ISPDefinition, error) {
+	rows, err := db.conn.Query(`
+		SELECT display, primary_asn, asns, allowed, COALESCE(include_prefixes, ''), COALESCE(exclude_prefixes, ''), updated_at
+		FROM isps ORDER BY display
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ISPs: %w", err)
+	}
+	defer rows.Close()
+
+	var isps []models.ISPDefinition
+	for rows.Next() {
+		var def models.ISPDefinition
+		var asns, include, exclude, updatedAt string
+		if err := rows.Scan(&def.Display, &def.PrimaryASN, &asns, &def.Allowed, &include, &exclude, &updatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ISP: %w", err)
+		}
+		if err := json.Unmarshal([]byte(asns), &def.ASNs); err != nil {
+			return nil, fmt.Errorf("failed to parse ASNs of %s: %w", def.Display, err)
+		}
+		for _, p := range []struct {
+			raw string
+			dst *[]string
+		}{{include, &def.IncludePrefixes}, {exclude, &def.ExcludePrefixes}} {
+			if p.raw == "" {
+				continue
+			}
+			if err := json.Unmarshal([]byte(p.raw), p.dst); err != nil {
+				return nil, fmt.Errorf("failed to parse prefixes of %s: %w", def.Display, err)
+			}
+		}
+		t := parseTime(updatedAt)
+		def.UpdatedAt = &t
+		isps = append(isps, def)
+	}
+	return isps, rows.Err()
+}
+
+// SaveISP creates an ISP, or replaces the one currently named display,
+// which lets an ISP be renamed. The caller validates the full set of
+// definitions, since ASNs and prefixes must not overlap between ISPs.
+func (db *DB) SaveISP(display string, def models.ISPDefinition) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if display != "" {
+		if _, err := tx.Exec(`DELETE FROM isps WHERE display = ?`, display); err != nil {
+			return fmt.Errorf("failed to replace ISP: %w", err)
+		}
+	}
+	if err := insertISP(tx, def, time.Now()); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit ISP: %w", err)
+	}
+	return nil
+}
+
+// DeleteISP removes an ISP definition, reporting whether it existed
+func (db *DB) DeleteISP(display string) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM isps WHERE display = ?`, display)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete ISP: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// ReplaceISPs atomically replaces all ISP definitions, as when importing a
+// config file
+func (db *DB) ReplaceISPs(isps []models.ISPDefinition) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM isps`); err != nil {
+		return fmt.Errorf("failed to clear ISPs: %w", err)
+	}
+	now := time.Now()
+	for _, def := range isps {
+		if err := insertISP(tx, def, now); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit ISPs: %w", err)
+	}
+	return nil
+}
+
+func insertISP(tx *sql.Tx, def models.ISPDefinition, now time.Time) error {
+	asns, err := json.Marshal(def.ASNs)
+	if err != nil {
+		return fmt.Errorf("failed to encode ASNs: %w", err)
+	}
+	_, err = tx.Exec(`
+		INSERT INTO isps (display, primary_asn, asns, allowed, include_prefixes, exclude_prefixes, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, def.Display, def.PrimaryASN, string(asns), def.Allowed,
+		jsonList(def.IncludePrefixes), jsonList(def.ExcludePrefixes), now)
+	if err != nil {
+		return fmt.Errorf("failed to save ISP %s: %w", def.Display, err)
+	}
+	return nil
+}
+
+// jsonList encodes an optional list, storing an empty one as NULL
+func jsonList(list []string) sql.NullString {
+	if len(list) == 0 {
+		return sql.NullString{}
+	}
+	data, _ := json.Marshal(list)
+	return sql.NullString{String: string(data), Valid: true}
+}
@@ -8,12 +8,13 @@ import (
 	"github.com/jonsson/ccc/internal/models"
 )
 
-// RecordUptimeSnapshot records the current uptime status for historical tracking
-func (db *DB) RecordUptimeSnapshot(total, up, down int) error {
+// RecordUptimeSnapshot records the current uptime status for historical tracking.
+// An empty isp records the building-wide totals.
+func (db *DB) RecordUptimeSnapshot(isp string, total, up, down int) error {
 	_, err := db.conn.Exec(`
-		INSERT INTO uptime_history (timestamp, total_endpoints, endpoints_up, endpoints_down)
-		VALUES (?, ?, ?, ?)
-	`, time.Now(), total, up, down)
+		INSERT INTO uptime_history (timestamp, isp, total_endpoints, endpoints_up, endpoints_down)
+		VALUES (?, ?, ?, ?, ?)
+	`, time.Now(), isp, total, up, down)
 	if err != nil {
 		return fmt.Errorf("failed to record uptime snapshot: %w", err)
 	}
@@ -31,50 +32,25 @@ func (db *DB) CleanupOldHistory(maxAge time.Duration) (int, error) {
 	return int(count), nil
 }
 
-// GetUptimeHistory returns uptime history for the specified duration
+// GetUptimeHistory returns building-wide uptime history for the specified duration
 func (db *DB) GetUptimeHistory(since time.Duration) ([]models.UptimePoint, error) {
-	cutoff := time.Now().Add(-since)
-	rows, err := db.conn.Query(`
-		SELECT timestamp, endpoints_up, endpoints_down
-		FROM uptime_history
-		WHERE timestamp > ?
-		ORDER BY timestamp ASC
-	`, cutoff)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get uptime history: %w", err)
-	}
-	defer rows.Close()
-
-	var history []models.UptimePoint
-	for rows.Next() {
-		var p models.UptimePoint
-		var ts string
-		if err := rows.Scan(&ts, &p.Up, &p.Down); err != nil {
-			return nil, fmt.Errorf("failed to scan history row: %w", err)
-		}
-		p.Timestamp = parseTime(ts)
-		total := p.Up + p.Down
-		if total > 0 {
-			p.UptimePct = float64(p.Up) / float64(total) * 100
-		}
-		history = append(history, p)
-	}
-	return history, nil
+	return db.GetISPUptimeHistory(overallISP, since)
 }
 
 // GetEndpointMetrics returns aggregated endpoint metrics
-func (db *DB) GetEndpointMetrics() (total, up, down, unknown, direct, hopMonitored int, err error) {
+func (db *DB) GetEndpointMetrics() (total, up, degraded, down, unknown, direct, hopMonitored int, err error) {
 	row := db.conn.QueryRow(`
 		SELECT
 			COUNT(*) as total,
-			COALESCE(SUM(CASE WHEN status = 'up' THEN 1 ELSE 0 END), 0) as up,
-			COALESCE(SUM(CASE WHEN status = 'down' THEN 1 ELSE 0 END), 0) as down,
-			COALESCE(SUM(CASE WHEN status = 'unknown' THEN 1 ELSE 0 END), 0) as unknown,
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) as up,
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) as degraded,
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) as down,
+			COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0) as unknown,
 			COALESCE(SUM(CASE WHEN use_hop = 0 OR use_hop IS NULL THEN 1 ELSE 0 END), 0) as direct,
 			COALESCE(SUM(CASE WHEN use_hop = 1 THEN 1 ELSE 0 END), 0) as hop_monitored
 		FROM endpoints
-	`)
-	err = row.Scan(&total, &up, &down, &unknown, &direct, &hopMonitored)
+	`, models.StatusUp, models.StatusDegraded, models.StatusDown, models.StatusUnknown)
+	err = row.Scan(&total, &up, &degraded, &down, &unknown, &direct, &hopMonitored)
 	if err != nil {
 		err = fmt.Errorf("failed to get endpoint metrics: %w", err)
 	}
@@ -100,19 +76,21 @@ func (db *DB) GetSharedHopCount() (int, error) {
 	return count, nil
 }
 
-// GetISPMetrics returns detailed metrics per ISP
+// GetISPMetrics returns detailed metrics per ISP. LikelyOutage is left for
+// the caller: the scheduler decides it by applying the outage policies.
 func (db *DB) GetISPMetrics() ([]models.ISPMetrics, error) {
 	rows, err := db.conn.Query(`
 		SELECT
 			isp,
 			COUNT(*) as total,
-			SUM(CASE WHEN status = 'up' THEN 1 ELSE 0 END) as up,
-			SUM(CASE WHEN status = 'down' THEN 1 ELSE 0 END) as down,
-			SUM(CASE WHEN status = 'unknown' THEN 1 ELSE 0 END) as unknown
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) as up,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) as degraded,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) as down,
+			SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) as unknown
 		FROM endpoints
 		GROUP BY isp
 		ORDER BY total DESC
-	`)
+	`, models.StatusUp, models.StatusDegraded, models.StatusDown, models.StatusUnknown)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get ISP metrics: %w", err)
 	}
@@ -121,14 +99,12 @@ func (db *DB) GetISPMetrics() ([]models.ISPMetrics, error) {
 	var metrics []models.ISPMetrics
 	for rows.Next() {
 		var m models.ISPMetrics
-		if err := rows.Scan(&m.Name, &m.Total, &m.Up, &m.Down, &m.Unknown); err != nil {
+		if err := rows.Scan(&m.Name, &m.Total, &m.Up, &m.Degraded, &m.Down, &m.Unknown); err != nil {
 			return nil, fmt.Errorf("failed to scan ISP metrics: %w", err)
 		}
 		if m.Total > 0 {
-			m.UptimePct = float64(m.Up) / float64(m.Total) * 100
+			m.UptimePct = float64(m.Up+m.Degraded) / float64(m.Total) * 100
 		}
-		// Mark as likely outage if >50% down
-		m.LikelyOutage = m.Total > 0 && float64(m.Down)/float64(m.Total) > 0.5
 		metrics = append(metrics, m)
 	}
 	return metrics, nil
@@ -150,16 +126,25 @@ func (db *DB) GetHistoryCount() (int64, error) {
 	return count, err
 }
 
-// RecordEvent adds a new event to the events table
-func (db *DB) RecordEvent(eventType, isp, endpointID, message string) error {
-	_, err := db.conn.Exec(`
+// RecordEvent adds a new event to the events table and returns it
+func (db *DB) RecordEvent(eventType, isp, endpointID, message string) (models.Event, error) {
+	event := models.Event{
+		Timestamp:  time.Now(),
+		EventType:  eventType,
+		ISP:        isp,
+		EndpointID: endpointID,
+		Message:    message,
+	}
+
+	result, err := db.conn.Exec(`
 		INSERT INTO events (timestamp, event_type, isp, endpoint_id, message)
 		VALUES (?, ?, ?, ?, ?)
-	`, time.Now(), eventType, isp, endpointID, message)
+	`, event.Timestamp, eventType, isp, endpointID, message)
 	if err != nil {
-		return fmt.Errorf("failed to record event: %w", err)
+		return event, fmt.Errorf("failed to record event: %w", err)
 	}
-	return nil
+	event.ID, _ = result.LastInsertId()
+	return event, nil
 }
 
 // GetRecentEvents returns recent events (last N hours)
@@ -1,12 +1,204 @@
 package storage
 
-import "log"
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
 
-const schema = `
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// migration is one numbered schema change, applied in its own transaction.
+// Migrations are append-only: never edit or reorder a released migration,
+// add a new one instead.
+type migration struct {
+	version int
+	name    string
+	up      func(tx *sql.Tx) error
+}
+
+// migrations lists every schema change in order; versions start at 1 and
+// have no gaps
+var migrations = []migration{
+	{1, "baseline", migrateBaseline},
+	{2, "isp_display_names", migrateISPDisplayNames},
+	{3, "endpoint_status_vocabulary", migrateEndpointStatusVocabulary},
+	{4, "admin_users_from_password", migrateAdminUsersFromPassword},
+	{5, "endpoint_pause", migrateEndpointPause},
+	{6, "outage_policies", migrateOutagePolicies},
+	{7, "isp_asns", migrateISPASNs},
+	{8, "isp_definitions", migrateISPDefinitions},
+	{9, "invites", migrateInvites},
+	{10, "probe_strategies", migrateProbeStrategies},
+	{11, "reference_targets", migrateReferenceTargets},
+	{12, "probe_agents", migrateProbeAgents},
+	{13, "endpoint_availability", migrateEndpointAvailability},
+}
+
+// MigrationStatus describes one known migration
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time // nil if pending
+}
+
+// latestVersion is the newest schema version this binary knows
+func latestVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns the highest applied migration version (0 for a new
+// or pre-versioning database)
+func (db *DB) SchemaVersion() (int, error) {
+	if _, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at DATETIME NOT NULL
+		)
+	`); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	var version int
+	if err := db.conn.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// checkSchemaVersion refuses databases migrated by a newer ccc-api, whose
+// schema this binary would misread or damage
+func (db *DB) checkSchemaVersion() (int, error) {
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if version > latestVersion() {
+		return version, fmt.Errorf("database schema version %d is newer than the latest version %d known to this ccc-api; upgrade ccc-api", version, latestVersion())
+	}
+	return version, nil
+}
+
+// MigrationStatus lists all known migrations and when each was applied
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	if _, err := db.checkSchemaVersion(); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.conn.Query(`SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan migration: %w", err)
+		}
+		applied[version] = parseTime(appliedAt)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		status[i] = MigrationStatus{Version: m.version, Name: m.name}
+		if t, ok := applied[m.version]; ok {
+			status[i].AppliedAt = &t
+		}
+	}
+	return status, nil
+}
+
+// PendingMigrations returns the number of migrations not yet applied
+func (db *DB) PendingMigrations() (int, error) {
+	version, err := db.checkSchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	return latestVersion() - version, nil
+}
+
+// Migrate applies all pending migrations in order, each in its own
+// transaction, and returns how many were applied
+func (db *DB) Migrate() (int, error) {
+	version, err := db.checkSchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if m.version <= version {
+			continue
+		}
+		if err := db.applyMigration(m); err != nil {
+			return applied, err
+		}
+		log.Printf("Applied migration %d (%s)", m.version, m.name)
+		applied++
+	}
+	return applied, nil
+}
+
+func (db *DB) applyMigration(m migration) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin migration %d: %w", m.version, err)
+	}
+	defer tx.Rollback()
+
+	if err := m.up(tx); err != nil {
+		return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+	}
+	if _, err := tx.Exec(`
+		INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)
+	`, m.version, m.name, time.Now()); err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", m.version, err)
+	}
+	return nil
+}
+
+// hasColumn reports whether a table has a column
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	var count int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect %s: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+// addColumn adds a column unless it already exists
+func addColumn(tx *sql.Tx, table, column, definition string) error {
+	exists, err := hasColumn(tx, table, column)
+	if err != nil || exists {
+		return err
+	}
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		return fmt.Errorf("failed to add %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// baselineTables is the schema at the introduction of versioned migrations
+const baselineTables = `
 CREATE TABLE IF NOT EXISTS endpoints (
     id TEXT PRIMARY KEY,
-    ipv4 TEXT NOT NULL,
+    ip TEXT NOT NULL,
     ip_hash TEXT NOT NULL UNIQUE,
+    secondary_ip TEXT,
+    secondary_ip_hash TEXT,
     isp TEXT NOT NULL,
     status TEXT NOT NULL DEFAULT 'unknown',
     created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
@@ -25,11 +217,23 @@ CREATE TABLE IF NOT EXISTS settings (
 CREATE TABLE IF NOT EXISTS uptime_history (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    isp TEXT NOT NULL DEFAULT '',
     total_endpoints INTEGER NOT NULL,
     endpoints_up INTEGER NOT NULL,
     endpoints_down INTEGER NOT NULL
 );
 
+CREATE TABLE IF NOT EXISTS uptime_rollups (
+    tier TEXT NOT NULL,
+    bucket DATETIME NOT NULL,
+    isp TEXT NOT NULL DEFAULT '',
+    samples INTEGER NOT NULL,
+    total_sum INTEGER NOT NULL,
+    up_sum INTEGER NOT NULL,
+    down_sum INTEGER NOT NULL,
+    PRIMARY KEY (tier, isp, bucket)
+);
+
 CREATE TABLE IF NOT EXISTS events (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
@@ -39,6 +243,88 @@ CREATE TABLE IF NOT EXISTS events (
     message TEXT NOT NULL
 );
 
+CREATE TABLE IF NOT EXISTS endpoint_tokens (
+    token_hash TEXT PRIMARY KEY,
+    endpoint_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS probe_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    endpoint_id TEXT NOT NULL,
+    isp TEXT NOT NULL,
+    timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    success INTEGER NOT NULL DEFAULT 0,
+    via_hop INTEGER NOT NULL DEFAULT 0,
+    min_rtt_ms REAL NOT NULL DEFAULT 0,
+    avg_rtt_ms REAL NOT NULL DEFAULT 0,
+    max_rtt_ms REAL NOT NULL DEFAULT 0,
+    jitter_ms REAL NOT NULL DEFAULT 0,
+    loss_pct REAL NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS incidents (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    isp TEXT NOT NULL,
+    started_at DATETIME NOT NULL,
+    ended_at DATETIME,
+    peak_affected INTEGER NOT NULL DEFAULT 0,
+    total_endpoints INTEGER NOT NULL DEFAULT 0,
+    detection_method TEXT NOT NULL,
+    shared_hop TEXT,
+    note TEXT
+);
+
+CREATE TABLE IF NOT EXISTS notifiers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    enabled INTEGER NOT NULL DEFAULT 1,
+    config TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS notification_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    notifier_id INTEGER NOT NULL,
+    timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    event TEXT NOT NULL,
+    incident_id INTEGER NOT NULL DEFAULT 0,
+    attempt INTEGER NOT NULL,
+    success INTEGER NOT NULL DEFAULT 0,
+    error TEXT
+);
+
+CREATE TABLE IF NOT EXISTS admin_users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    password_hash TEXT NOT NULL,
+    role TEXT NOT NULL,
+    disabled INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_login DATETIME
+);
+
+CREATE TABLE IF NOT EXISTS admin_sessions (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    actor TEXT NOT NULL,
+    client_ip TEXT,
+    action TEXT NOT NULL,
+    target TEXT,
+    changes TEXT
+);
+`
+
+const baselineIndexes = `
 CREATE INDEX IF NOT EXISTS idx_endpoints_ip_hash ON endpoints(ip_hash);
 CREATE INDEX IF NOT EXISTS idx_endpoints_isp ON endpoints(isp);
 CREATE INDEX IF NOT EXISTS idx_endpoints_status ON endpoints(status);
@@ -46,59 +332,278 @@ CREATE INDEX IF NOT EXISTS idx_endpoints_last_seen ON endpoints(last_seen);
 CREATE INDEX IF NOT EXISTS idx_endpoints_monitored_hop ON endpoints(monitored_hop);
 CREATE INDEX IF NOT EXISTS idx_uptime_history_timestamp ON uptime_history(timestamp);
 CREATE INDEX IF NOT EXISTS idx_events_timestamp ON events(timestamp);
+CREATE INDEX IF NOT EXISTS idx_endpoint_tokens_endpoint ON endpoint_tokens(endpoint_id);
+CREATE INDEX IF NOT EXISTS idx_probe_results_endpoint_ts ON probe_results(endpoint_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_probe_results_isp_ts ON probe_results(isp, timestamp);
+CREATE INDEX IF NOT EXISTS idx_probe_results_timestamp ON probe_results(timestamp);
+CREATE INDEX IF NOT EXISTS idx_incidents_started_at ON incidents(started_at);
+CREATE INDEX IF NOT EXISTS idx_incidents_isp_started_at ON incidents(isp, started_at);
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_notifier ON notification_deliveries(notifier_id, id);
+CREATE INDEX IF NOT EXISTS idx_notification_deliveries_timestamp ON notification_deliveries(timestamp);
+CREATE INDEX IF NOT EXISTS idx_admin_sessions_user ON admin_sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_admin_sessions_expires_at ON admin_sessions(expires_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_timestamp ON audit_log(timestamp);
+CREATE INDEX IF NOT EXISTS idx_audit_log_target ON audit_log(target);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_endpoints_secondary_ip_hash ON endpoints(secondary_ip_hash);
+CREATE INDEX IF NOT EXISTS idx_uptime_history_isp_ts ON uptime_history(isp, timestamp);
 `
 
-// Migration to add hop columns to existing databases
-const migrationAddHopColumns = `
-ALTER TABLE endpoints ADD COLUMN monitored_hop TEXT;
-ALTER TABLE endpoints ADD COLUMN hop_number INTEGER DEFAULT 0;
-ALTER TABLE endpoints ADD COLUMN use_hop INTEGER DEFAULT 0;
-`
+// migrateBaseline creates the schema on a new database, and brings a
+// database from before versioned migrations up to the same layout
+func migrateBaseline(tx *sql.Tx) error {
+	if _, err := tx.Exec(baselineTables); err != nil {
+		return err
+	}
 
-func (db *DB) migrate() error {
-	_, err := db.conn.Exec(schema)
+	// Endpoints are address-family agnostic: the primary address column was ipv4
+	hasIPv4, err := hasColumn(tx, "endpoints", "ipv4")
 	if err != nil {
 		return err
 	}
+	if hasIPv4 {
+		if _, err := tx.Exec("ALTER TABLE endpoints RENAME COLUMN ipv4 TO ip"); err != nil {
+			return fmt.Errorf("failed to rename endpoints.ipv4: %w", err)
+		}
+	}
+
+	for _, c := range []struct{ table, column, definition string }{
+		{"endpoints", "monitored_hop", "TEXT"},
+		{"endpoints", "hop_number", "INTEGER DEFAULT 0"},
+		{"endpoints", "use_hop", "INTEGER DEFAULT 0"},
+		{"endpoints", "secondary_ip", "TEXT"},
+		{"endpoints", "secondary_ip_hash", "TEXT"},
+		{"uptime_history", "isp", "TEXT NOT NULL DEFAULT ''"},
+	} {
+		if err := addColumn(tx, c.table, c.column, c.definition); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(baselineIndexes)
+	return err
+}
 
-	// Try to add hop columns for existing databases (will fail if already exist)
-	db.conn.Exec("ALTER TABLE endpoints ADD COLUMN monitored_hop TEXT")
-	db.conn.Exec("ALTER TABLE endpoints ADD COLUMN hop_number INTEGER DEFAULT 0")
-	db.conn.Exec("ALTER TABLE endpoints ADD COLUMN use_hop INTEGER DEFAULT 0")
-
-	// Create index if it doesn't exist
-	db.conn.Exec("CREATE INDEX IF NOT EXISTS idx_endpoints_monitored_hop ON endpoints(monitored_hop)")
-
-	// Add events table for existing databases (will fail if already exists via schema)
-	db.conn.Exec(`CREATE TABLE IF NOT EXISTS events (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		timestamp DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
-		event_type TEXT NOT NULL,
-		isp TEXT,
-		endpoint_id TEXT,
-		message TEXT NOT NULL
-	)`)
-	db.conn.Exec("CREATE INDEX IF NOT EXISTS idx_events_timestamp ON events(timestamp)")
-
-	// Migrate old ISP keys to display names
-	ispMigrations := map[string]string{
-		"comcast": "Comcast / Xfinity",
-		"starry":  "Starry",
-		"verizon": "Verizon / Fios",
-		"att":     "AT&T",
-		"spectrum": "Spectrum / Charter",
-		"rcn":     "RCN",
-		"optimum": "Optimum / Cablevision",
-		"cox":     "Cox",
+// migrateISPDisplayNames renames the ISP keys used by early versions to
+// display names
+func migrateISPDisplayNames(tx *sql.Tx) error {
+	renames := map[string]string{
+		"comcast":      "Comcast / Xfinity",
+		"starry":       "Starry",
+		"verizon":      "Verizon / Fios",
+		"att":          "AT&T",
+		"spectrum":     "Spectrum / Charter",
+		"rcn":          "RCN",
+		"optimum":      "Optimum / Cablevision",
+		"cox":          "Cox",
 		"google-fiber": "Google Fiber",
-		"tmobile": "T-Mobile",
-		"unknown": "Unknown",
+		"tmobile":      "T-Mobile",
+		"unknown":      "Unknown",
 	}
-	for oldKey, newName := range ispMigrations {
-		db.conn.Exec("UPDATE endpoints SET isp = ? WHERE isp = ?", newName, oldKey)
-		db.conn.Exec("UPDATE events SET isp = ? WHERE isp = ?", newName, oldKey)
+	for oldKey, newName := range renames {
+		if _, err := tx.Exec("UPDATE endpoints SET isp = ? WHERE isp = ?", newName, oldKey); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("UPDATE events SET isp = ? WHERE isp = ?", newName, oldKey); err != nil {
+			return err
+		}
 	}
-
-	log.Println("Database migrations completed")
 	return nil
 }
+
+// migrateEndpointStatusVocabulary maps the legacy "unreachable" status to down
+func migrateEndpointStatusVocabulary(tx *sql.Tx) error {
+	_, err := tx.Exec("UPDATE endpoints SET status = ? WHERE status = 'unreachable'", string(models.StatusDown))
+	return err
+}
+
+// migrateAdminUsersFromPassword turns the single shared admin password into
+// the owner account "admin"
+func migrateAdminUsersFromPassword(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+		INSERT INTO admin_users (username, password_hash, role)
+		SELECT ?, value, ? FROM settings
+		WHERE key = ? AND NOT EXISTS (SELECT 1 FROM admin_users)
+	`, DefaultAdminUsername, string(models.RoleOwner), settingAdminPasswordHash); err != nil {
+		return err
+	}
+	_, err := tx.Exec("DELETE FROM settings WHERE key = ?", settingAdminPasswordHash)
+	return err
+}
+
+// migrateEndpointPause lets residents pause monitoring of their endpoint
+func migrateEndpointPause(tx *sql.Tx) error {
+	return addColumn(tx, "endpoints", "paused_until", "DATETIME")
+}
+
+// migrateOutagePolicies replaces the single outage_threshold setting with
+// per-ISP outage policies, carrying the threshold over to the default policy
+func migrateOutagePolicies(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS outage_policies (
+			isp TEXT PRIMARY KEY,
+			min_endpoints INTEGER NOT NULL,
+			down_threshold REAL NOT NULL,
+			persist_cycles INTEGER NOT NULL,
+			shared_hop_min_endpoints INTEGER NOT NULL,
+			updated_at DATETIME NOT NULL
+		)
+	`); err != nil {
+		return err
+	}
+
+	def := models.DefaultOutagePolicy()
+	var legacy string
+	err := tx.QueryRow("SELECT value FROM settings WHERE key = ?", settingOutageThreshold).Scan(&legacy)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if threshold, err := strconv.ParseFloat(legacy, 64); err == nil && threshold >= 0 && threshold <= 1 {
+		def.DownThreshold = threshold
+	}
+
+	if _, err := tx.Exec(`
+		INSERT OR IGNORE INTO outage_policies (isp, min_endpoints, down_threshold, persist_cycles, shared_hop_min_endpoints, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, defaultPolicyKey, def.MinEndpoints, def.DownThreshold, def.PersistCycles, def.SharedHopMinEndpoints, time.Now()); err != nil {
+		return err
+	}
+	_, err = tx.Exec("DELETE FROM settings WHERE key = ?", settingOutageThreshold)
+	return err
+}
+
+// migrateISPASNs stores the ASN to ISP mappings previously only read from
+// the --isp-config file
+func migrateISPASNs(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS isp_asns (
+			asn INTEGER PRIMARY KEY,
+			display TEXT NOT NULL,
+			allowed INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME NOT NULL
+		)
+	`)
+	return err
+}
+
+// migrateISPDefinitions groups the per-ASN mappings into ISP definitions
+// that can list several ASNs and prefix rules. ASNs sharing a display name
+// become one ISP whose primary ASN is the lowest, allowed if any ASN was.
+func migrateISPDefinitions(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS isps (
+			display TEXT PRIMARY KEY,
+			primary_asn INTEGER NOT NULL,
+			asns TEXT NOT NULL,
+			allowed INTEGER NOT NULL DEFAULT 0,
+			include_prefixes TEXT,
+			exclude_prefixes TEXT,
+			updated_at DATETIME NOT NULL
+		)
+	`); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO isps (display, primary_asn, asns, allowed, updated_at)
+		SELECT display, MIN(asn), json_group_array(asn), MAX(allowed), MAX(updated_at)
+		FROM (SELECT * FROM isp_asns ORDER BY asn)
+		GROUP BY display
+	`); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`DROP TABLE isp_asns`)
+	return err
+}
+
+// migrateInvites adds one-time invite codes for registration
+func migrateInvites(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS invites (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			code_hash TEXT NOT NULL UNIQUE,
+			note TEXT,
+			created_by TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			expires_at DATETIME,
+			used_at DATETIME,
+			endpoint_id TEXT
+		)
+	`)
+	return err
+}
+
+// migrateProbeStrategies adds per-endpoint probe strategies and records
+// which probe decided each measurement
+func migrateProbeStrategies(tx *sql.Tx) error {
+	if err := addColumn(tx, "endpoints", "probe_strategy", "TEXT"); err != nil {
+		return err
+	}
+	return addColumn(tx, "probe_results", "method", "TEXT")
+}
+
+// migrateReferenceTargets adds the targets probed to check the monitoring
+// server's own connectivity
+func migrateReferenceTargets(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS reference_targets (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL,
+			address TEXT NOT NULL UNIQUE,
+			probe_strategy TEXT,
+			status TEXT NOT NULL DEFAULT 'unknown',
+			last_checked DATETIME,
+			last_ok DATETIME,
+			created_at DATETIME NOT NULL
+		)
+	`)
+	return err
+}
+
+// migrateProbeAgents adds remote probe agents and the latest result each
+// reported per endpoint
+func migrateProbeAgents(tx *sql.Tx) error {
+	if _, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS probe_agents (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name TEXT NOT NULL UNIQUE,
+			key_hash TEXT NOT NULL UNIQUE,
+			isps TEXT,
+			created_at DATETIME NOT NULL,
+			last_seen DATETIME
+		)
+	`); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS agent_observations (
+			agent_id INTEGER NOT NULL,
+			endpoint_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			success INTEGER NOT NULL,
+			rtt_ms REAL,
+			loss_pct REAL NOT NULL,
+			method TEXT,
+			checked_at DATETIME NOT NULL,
+			PRIMARY KEY (agent_id, endpoint_id)
+		)
+	`)
+	return err
+}
+
+// migrateEndpointAvailability adds the daily per-endpoint totals that
+// availability reports are built from
+func migrateEndpointAvailability(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+		CREATE TABLE IF NOT EXISTS endpoint_availability (
+			day TEXT NOT NULL,
+			endpoint_id TEXT NOT NULL,
+			isp TEXT NOT NULL,
+			monitored_seconds REAL NOT NULL DEFAULT 0,
+			down_seconds REAL NOT NULL DEFAULT 0,
+			failures INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (day, endpoint_id)
+		)
+	`)
+	return err
+}
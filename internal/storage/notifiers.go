@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// CreateNotifier stores a new notifier configuration and returns its ID
+func (db *DB) CreateNotifier(n models.NotifierConfig) (int64, error) {
+	result, err := db.conn.Exec(`
+		INSERT INTO notifiers (name, type, enabled, config, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, n.Name, n.Type, boolToInt(n.Enabled), string(n.Config), time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to create notifier: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// UpdateNotifier replaces a notifier configuration.
+// Returns false if the notifier does not exist.
+func (db *DB) UpdateNotifier(n models.NotifierConfig) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE notifiers SET name = ?, type = ?, enabled = ?, config = ? WHERE id = ?
+	`, n.Name, n.Type, boolToInt(n.Enabled), string(n.Config), n.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update notifier: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return count > 0, nil
+}
+
+// DeleteNotifier removes a notifier and its delivery log.
+// Returns false if the notifier does not exist.
+func (db *DB) DeleteNotifier(id int64) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM notifiers WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete notifier: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	if count == 0 {
+		return false, nil
+	}
+
+	if _, err := db.conn.Exec(`DELETE FROM notification_deliveries WHERE notifier_id = ?`, id); err != nil {
+		return true, fmt.Errorf("failed to delete notifier deliveries: %w", err)
+	}
+	return true, nil
+}
+
+// GetNotifier returns a single notifier, or nil if it does not exist
+func (db *DB) GetNotifier(id int64) (*models.NotifierConfig, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, name, type, enabled, config, created_at FROM notifiers WHERE id = ?
+	`, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get notifier: %w", err)
+	}
+	defer rows.Close()
+
+	notifiers, err := scanNotifiers(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(notifiers) == 0 {
+		return nil, nil
+	}
+	return &notifiers[0], nil
+}
+
+// ListNotifiers returns all notifiers, optionally only enabled ones
+func (db *DB) ListNotifiers(enabledOnly bool) ([]models.NotifierConfig, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, name, type, enabled, config, created_at
+		FROM notifiers
+		WHERE ? = 0 OR enabled = 1
+		ORDER BY id ASC
+	`, boolToInt(enabledOnly))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notifiers: %w", err)
+	}
+	defer rows.Close()
+
+	return scanNotifiers(rows)
+}
+
+// RecordNotificationDelivery logs one delivery attempt
+func (db *DB) RecordNotificationDelivery(d models.NotificationDelivery) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO notification_deliveries (notifier_id, timestamp, event, incident_id, attempt, success, error)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, d.NotifierID, time.Now(), d.Event, d.IncidentID, d.Attempt, boolToInt(d.Success), nullString(d.Error))
+	if err != nil {
+		return fmt.Errorf("failed to record notification delivery: %w", err)
+	}
+	return nil
+}
+
+// ListNotificationDeliveries returns the most recent delivery attempts for a notifier
+func (db *DB) ListNotificationDeliveries(notifierID int64, limit int) ([]models.NotificationDelivery, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, notifier_id, timestamp, event, incident_id, attempt, success, COALESCE(error, '')
+		FROM notification_deliveries
+		WHERE notifier_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`, notifierID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list notification deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.NotificationDelivery
+	for rows.Next() {
+		var d models.NotificationDelivery
+		var ts string
+		var success int
+		if err := rows.Scan(&d.ID, &d.NotifierID, &ts, &d.Event, &d.IncidentID, &d.Attempt, &success, &d.Error); err != nil {
+			return nil, fmt.Errorf("failed to scan notification delivery: %w", err)
+		}
+		d.Timestamp = parseTime(ts)
+		d.Success = success != 0
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// CleanupOldNotificationDeliveries removes delivery log entries older than the specified duration
+func (db *DB) CleanupOldNotificationDeliveries(maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	result, err := db.conn.Exec(`DELETE FROM notification_deliveries WHERE timestamp < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup old notification deliveries: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// scanNotifiers is a helper to scan notifier rows
+func scanNotifiers(rows *sql.Rows) ([]models.NotifierConfig, error) {
+	var notifiers []models.NotifierConfig
+	for rows.Next() {
+		var n models.NotifierConfig
+		var enabled int
+		var config, createdAt string
+		if err := rows.Scan(&n.ID, &n.Name, &n.Type, &enabled, &config, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan notifier: %w", err)
+		}
+		n.Enabled = enabled != 0
+		n.Config = []byte(config)
+		n.CreatedAt = parseTime(createdAt)
+		notifiers = append(notifiers, n)
+	}
+	return notifiers, rows.Err()
+}
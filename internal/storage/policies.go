@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// defaultPolicyKey is the outage_policies row that applies to ISPs
+// without a policy of their own
+const defaultPolicyKey = "*"
+
+// OutagePolicies is the default outage policy plus per-ISP overrides keyed
+// by ISP display name
+type OutagePolicies struct {
+	Default models.OutagePolicy
+	ISPs    map[string]models.OutagePolicy
+}
+
+// For returns the policy that applies to an ISP
+func (p OutagePolicies) For(isp string) models.OutagePolicy {
+	if policy, ok := p.ISPs[isp]; ok {
+		return policy
+	}
+	return p.Default
+}
+
+// GetOutagePolicies returns the configured outage policies
+func (db *DB) GetOutagePolicies() (OutagePolicies, error) {
+	policies := OutagePolicies{
+		Default: models.DefaultOutagePolicy(),
+		ISPs:    make(map[string]models.OutagePolicy),
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT isp, min_endpoints, down_threshold, persist_cycles, shared_hop_min_endpoints
+		FROM outage_policies
+	`)
+	if err != nil {
+		return policies, fmt.Errorf("failed to get outage policies: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var isp string
+		var p models.OutagePolicy
+		if err := rows.Scan(&isp, &p.MinEndpoints, &p.DownThreshold, &p.PersistCycles, &p.SharedHopMinEndpoints); err != nil {
+			return policies, fmt.Errorf("failed to scan outage policy: %w", err)
+		}
+		if isp == defaultPolicyKey {
+			policies.Default = p
+		} else {
+			policies.ISPs[isp] = p
+		}
+	}
+	return policies, rows.Err()
+}
+
+// Validate checks the default policy, each ISP policy and the ISP names
+func (p OutagePolicies) Validate() error {
+	if err := p.Default.Validate(); err != nil {
+		return fmt.Errorf("default policy: %w", err)
+	}
+	for isp, policy := range p.ISPs {
+		if strings.TrimSpace(isp) == "" || isp == defaultPolicyKey {
+			return fmt.Errorf("invalid ISP name %q", isp)
+		}
+		if err := policy.Validate(); err != nil {
+			return fmt.Errorf("policy for %s: %w", isp, err)
+		}
+	}
+	return nil
+}
+
+// SetOutagePolicies replaces all outage policies
+func (db *DB) SetOutagePolicies(policies OutagePolicies) error {
+	if err := policies.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM outage_policies`); err != nil {
+		return fmt.Errorf("failed to clear outage policies: %w", err)
+	}
+	now := time.Now()
+	save := func(isp string, p models.OutagePolicy) error {
+		_, err := tx.Exec(`
+			INSERT INTO outage_policies (isp, min_endpoints, down_threshold, persist_cycles, shared_hop_min_endpoints, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, isp, p.MinEndpoints, p.DownThreshold, p.PersistCycles, p.SharedHopMinEndpoints, now)
+		if err != nil {
+			return fmt.Errorf("failed to save outage policy: %w", err)
+		}
+		return nil
+	}
+	if err := save(defaultPolicyKey, policies.Default); err != nil {
+		return err
+	}
+	for isp, p := range policies.ISPs {
+		if err := save(isp, p); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit outage policies: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// RecordProbeResult stores a single probe measurement
+func (db *DB) RecordProbeResult(p models.ProbeResult) error {
+	if p.Timestamp.IsZero() {
+		p.Timestamp = time.Now()
+	}
+
+	_, err := db.conn.Exec(`
+		INSERT INTO probe_results (endpoint_id, isp, timestamp, success, via_hop,
+			min_rtt_ms, avg_rtt_ms, max_rtt_ms, jitter_ms, loss_pct, method)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, p.EndpointID, p.ISP, p.Timestamp, boolToInt(p.Success), boolToInt(p.ViaHop),
+		p.MinRTTMs, p.AvgRTTMs, p.MaxRTTMs, p.JitterMs, p.LossPct, nullString(p.Method))
+	if err != nil {
+		return fmt.Errorf("failed to record probe result: %w", err)
+	}
+	return nil
+}
+
+// GetProbeResults returns probe results for an endpoint within a time range
+func (db *DB) GetProbeResults(endpointID string, from, to time.Time) ([]models.ProbeResult, error) {
+	rows, err := db.conn.Query(`
+		SELECT endpoint_id, isp, timestamp, success, via_hop,
+		       min_rtt_ms, avg_rtt_ms, max_rtt_ms, jitter_ms, loss_pct, COALESCE(method, '')
+		FROM probe_results
+		WHERE endpoint_id = ? AND timestamp >= ? AND timestamp < ?
+		ORDER BY timestamp ASC
+	`, endpointID, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get probe results: %w", err)
+	}
+	defer rows.Close()
+
+	return scanProbeResults(rows)
+}
+
+// GetISPProbeResults returns probe results for all endpoints of an ISP within a time range
+func (db *DB) GetISPProbeResults(isp string, from, to time.Time) ([]models.ProbeResult, error) {
+	rows, err := db.conn.Query(`
+		SELECT endpoint_id, isp, timestamp, success, via_hop,
+		       min_rtt_ms, avg_rtt_ms, max_rtt_ms, jitter_ms, loss_pct, COALESCE(method, '')
+		FROM probe_results
+		WHERE isp = ? AND timestamp >= ? AND timestamp < ?
+		ORDER BY timestamp ASC
+	`, isp, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ISP probe results: %w", err)
+	}
+	defer rows.Close()
+
+	return scanProbeResults(rows)
+}
+
+// CleanupOldProbeResults removes probe results older than the specified duration
+func (db *DB) CleanupOldProbeResults(maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	result, err := db.conn.Exec(`DELETE FROM probe_results WHERE timestamp < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup old probe results: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// scanProbeResults is a helper to scan probe result rows
+func scanProbeResults(rows *sql.Rows) ([]models.ProbeResult, error) {
+	var results []models.ProbeResult
+	for rows.Next() {
+		var p models.ProbeResult
+		var ts string
+		var success, viaHop int
+		if err := rows.Scan(&p.EndpointID, &p.ISP, &ts, &success, &viaHop,
+			&p.MinRTTMs, &p.AvgRTTMs, &p.MaxRTTMs, &p.JitterMs, &p.LossPct, &p.Method); err != nil {
+			return nil, fmt.Errorf("failed to scan probe result: %w", err)
+		}
+		p.Timestamp = parseTime(ts)
+		p.Success = success != 0
+		p.ViaHop = viaHop != 0
+		results = append(results, p)
+	}
+	return results, nil
+}
+
+// boolToInt converts a bool to SQLite's integer representation
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
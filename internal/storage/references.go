@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+const referenceColumns = `id, name, address, COALESCE(probe_strategy, ''), status, last_checked, last_ok, created_at`
+
+// ListReferenceTargets returns all reference targets, oldest first
+func (db *DB) ListReferenceTargets() ([]models.ReferenceTarget, error) {
+	rows, err := db.conn.Query(`SELECT ` + referenceColumns + ` FROM reference_targets ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list reference targets: %w", err)
+	}
+	defer rows.Close()
+
+	var targets []models.ReferenceTarget
+	for rows.Next() {
+		t, err := scanReferenceTarget(rows)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, *t)
+	}
+	return targets, rows.Err()
+}
+
+// GetReferenceTarget returns one reference target, or nil if it does not exist
+func (db *DB) GetReferenceTarget(id int64) (*models.ReferenceTarget, error) {
+	row := db.conn.QueryRow(`SELECT `+referenceColumns+` FROM reference_targets WHERE id = ?`, id)
+	t, err := scanReferenceTarget(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return t, err
+}
+
+// CreateReferenceTarget stores a new reference target and sets its ID
+func (db *DB) CreateReferenceTarget(t *models.ReferenceTarget) error {
+	t.CreatedAt = time.Now()
+	t.Status = models.StatusUnknown
+	result, err := db.conn.Exec(`
+		INSERT INTO reference_targets (name, address, probe_strategy, status, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, t.Name, t.Address, nullString(strings.Join(t.ProbeStrategy, ",")), t.Status, t.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create reference target: %w", err)
+	}
+	t.ID, err = result.LastInsertId()
+	return err
+}
+
+// UpdateReferenceTarget changes the name, address and probe strategy of a
+// reference target. A changed address starts again from unknown.
+// Returns false if the target does not exist.
+func (db *DB) UpdateReferenceTarget(t models.ReferenceTarget) (bool, error) {
+	result, err := db.conn.Exec(`
+		UPDATE reference_targets
+		SET status = CASE WHEN address = ? THEN status ELSE 'unknown' END,
+		    name = ?, address = ?, probe_strategy = ?
+		WHERE id = ?
+	`, t.Address, t.Name, t.Address, nullString(strings.Join(t.ProbeStrategy, ",")), t.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to update reference target: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return count > 0, nil
+}
+
+// DeleteReferenceTarget removes a reference target.
+// Returns false if it does not exist.
+func (db *DB) DeleteReferenceTarget(id int64) (bool, error) {
+	result, err := db.conn.Exec(`DELETE FROM reference_targets WHERE id = ?`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete reference target: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return count > 0, nil
+}
+
+// RecordReferenceCheck stores the outcome of probing a reference target
+func (db *DB) RecordReferenceCheck(id int64, ok bool) error {
+	now := time.Now()
+	status := models.StatusDown
+	var lastOK sql.NullTime
+	if ok {
+		status = models.StatusUp
+		lastOK = sql.NullTime{Time: now, Valid: true}
+	}
+	_, err := db.conn.Exec(`
+		UPDATE reference_targets SET status = ?, last_checked = ?, last_ok = COALESCE(?, last_ok)
+		WHERE id = ?
+	`, status, now, lastOK, id)
+	if err != nil {
+		return fmt.Errorf("failed to record reference check: %w", err)
+	}
+	return nil
+}
+
+func scanReferenceTarget(row rowScanner) (*models.ReferenceTarget, error) {
+	var t models.ReferenceTarget
+	var strategy string
+	var lastChecked, lastOK sql.NullString
+	var createdAt string
+	if err := row.Scan(&t.ID, &t.Name, &t.Address, &strategy, &t.Status, &lastChecked, &lastOK, &createdAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to scan reference target: %w", err)
+	}
+	if strategy != "" {
+		t.ProbeStrategy = strings.Split(strategy, ",")
+	}
+	if lastChecked.Valid {
+		ts := parseTime(lastChecked.String)
+		t.LastChecked = &ts
+	}
+	if lastOK.Valid {
+		ts := parseTime(lastOK.String)
+		t.LastOK = &ts
+	}
+	t.CreatedAt = parseTime(createdAt)
+	return &t, nil
+}
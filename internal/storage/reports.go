@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// dayLayout is the format of the day column of endpoint_availability
+const dayLayout = "2006-01-02"
+
+// RecordAvailability adds seconds of monitored time to each endpoint's
+// daily totals, and to its down time if it is down. Endpoints that have
+// not been probed yet are skipped.
+func (db *DB) RecordAvailability(at time.Time, seconds float64, endpoints []models.Endpoint) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO endpoint_availability (day, endpoint_id, isp, monitored_seconds, down_seconds)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (day, endpoint_id) DO UPDATE SET
+			isp = excluded.isp,
+			monitored_seconds = monitored_seconds + excluded.monitored_seconds,
+			down_seconds = down_seconds + excluded.down_seconds
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare availability: %w", err)
+	}
+	defer stmt.Close()
+
+	day := at.Local().Format(dayLayout)
+	for _, ep := range endpoints {
+		if ep.Status == models.StatusUnknown {
+			continue
+		}
+		down := 0.0
+		if ep.Status == models.StatusDown {
+			down = seconds
+		}
+		if _, err := stmt.Exec(day, ep.ID, ep.ISP, seconds, down); err != nil {
+			return fmt.Errorf("failed to record availability: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// RecordEndpointFailure counts an endpoint going down in its daily totals
+func (db *DB) RecordEndpointFailure(at time.Time, ep models.Endpoint) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO endpoint_availability (day, endpoint_id, isp, failures)
+		VALUES (?, ?, ?, 1)
+		ON CONFLICT (day, endpoint_id) DO UPDATE SET failures = failures + 1
+	`, at.Local().Format(dayLayout), ep.ID, ep.ISP)
+	if err != nil {
+		return fmt.Errorf("failed to record endpoint failure: %w", err)
+	}
+	return nil
+}
+
+// cleanupOldAvailability removes daily totals older than maxAge
+func (db *DB) cleanupOldAvailability(maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge).Local().Format(dayLayout)
+	result, err := db.conn.Exec(`DELETE FROM endpoint_availability WHERE day < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup availability: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// reportPeriod is one row period of an availability report
+type reportPeriod struct {
+	key        string
+	start, end time.Time
+}
+
+// reportPeriods splits the days from..to (inclusive, local midnights) into
+// the periods of a breakdown
+func reportPeriods(from, to time.Time, breakdown string) []reportPeriod {
+	end := to.AddDate(0, 0, 1)
+	switch breakdown {
+	case models.BreakdownDaily:
+		var periods []reportPeriod
+		for day := from; day.Before(end); day = day.AddDate(0, 0, 1) {
+			periods = append(periods, reportPeriod{day.Format(dayLayout), day, day.AddDate(0, 0, 1)})
+		}
+		return periods
+	case models.BreakdownMonthly:
+		var periods []reportPeriod
+		for start := from; start.Before(end); {
+			next := time.Date(start.Year(), start.Month()+1, 1, 0, 0, 0, 0, start.Location())
+			if next.After(end) {
+				next = end
+			}
+			periods = append(periods, reportPeriod{start.Format("2006-01"), start, next})
+			start = next
+		}
+		return periods
+	default:
+		return []reportPeriod{{from.Format(dayLayout) + "/" + to.Format(dayLayout), from, end}}
+	}
+}
+
+// availabilityTotals accumulates seconds for one report row. For ISP rows
+// down and failures come from incidents, and the endpoint totals are kept
+// separately.
+type availabilityTotals struct {
+	monitored         float64
+	down              float64
+	failures          int
+	endpointMonitored float64
+	endpointDown      float64
+}
+
+// reportKey identifies one row of an availability report
+type reportKey struct {
+	period     string
+	isp        string
+	endpointID string
+}
+
+// GetAvailabilityReport computes availability per ISP and per endpoint for
+// the days from..to (inclusive, local midnights), split by breakdown. An
+// empty isp reports every ISP.
+//
+// Endpoint rows come from the daily totals recorded each ping cycle. ISP
+// rows count time covered by outage incidents as outage time and each
+// incident that started in the period as a failure, measured against the
+// time the ISP was monitored.
+func (db *DB) GetAvailabilityReport(from, to time.Time, breakdown, isp string) (*models.AvailabilityReport, error) {
+	periods := reportPeriods(from, to, breakdown)
+	periodOf := make(map[string]string) // Day -> period key
+	for _, p := range periods {
+		for day := p.start; day.Before(p.end); day = day.AddDate(0, 0, 1) {
+			periodOf[day.Format(dayLayout)] = p.key
+		}
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT day, endpoint_id, isp, monitored_seconds, down_seconds, failures
+		FROM endpoint_availability
+		WHERE day >= ? AND day <= ? AND (? = '' OR isp = ?)
+	`, from.Format(dayLayout), to.Format(dayLayout), isp, isp)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get availability: %w", err)
+	}
+	defer rows.Close()
+
+	endpoints := make(map[reportKey]*availabilityTotals)
+	isps := make(map[reportKey]*availabilityTotals)
+	// The ISP is monitored for as long as its most monitored endpoint was
+	ispDays := make(map[[2]string]float64)
+	for rows.Next() {
+		var day, endpointID, rowISP string
+		var monitored, down float64
+		var failures int
+		if err := rows.Scan(&day, &endpointID, &rowISP, &monitored, &down, &failures); err != nil {
+			return nil, fmt.Errorf("failed to scan availability: %w", err)
+		}
+		period := periodOf[day]
+
+		key := reportKey{period, rowISP, endpointID}
+		t, ok := endpoints[key]
+		if !ok {
+			t = &availabilityTotals{}
+			endpoints[key] = t
+		}
+		t.monitored += monitored
+		t.down += down
+		t.failures += failures
+
+		ispKey := reportKey{period: period, isp: rowISP}
+		it, ok := isps[ispKey]
+		if !ok {
+			it = &availabilityTotals{}
+			isps[ispKey] = it
+		}
+		it.endpointMonitored += monitored
+		it.endpointDown += down
+		dayKey := [2]string{day, rowISP}
+		if monitored > ispDays[dayKey] {
+			it.monitored += monitored - ispDays[dayKey]
+			ispDays[dayKey] = monitored
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	incidents, err := db.listIncidentsBetween(isp, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	for _, inc := range incidents {
+		end := now
+		if inc.EndedAt != nil {
+			end = *inc.EndedAt
+		}
+		for _, p := range periods {
+			overlap := minTime(end, p.end).Sub(maxTime(inc.StartedAt, p.start))
+			started := !inc.StartedAt.Before(p.start) && inc.StartedAt.Before(p.end)
+			if overlap <= 0 && !started {
+				continue
+			}
+			key := reportKey{period: p.key, isp: inc.ISP}
+			t, ok := isps[key]
+			if !ok {
+				t = &availabilityTotals{}
+				isps[key] = t
+			}
+			if overlap > 0 {
+				t.down += overlap.Seconds()
+			}
+			if started {
+				t.failures++
+			}
+		}
+	}
+
+	report := &models.AvailabilityReport{
+		From:        from.Format(dayLayout),
+		To:          to.Format(dayLayout),
+		Breakdown:   breakdown,
+		GeneratedAt: now,
+		ISPs:        []models.AvailabilityRow{},
+		Endpoints:   []models.AvailabilityRow{},
+	}
+	for key, t := range isps {
+		row := availabilityRow(key, t.monitored, t.down, t.failures)
+		if t.endpointMonitored > 0 {
+			pct := round(100*(t.endpointMonitored-t.endpointDown)/t.endpointMonitored, 3)
+			row.EndpointAvailabilityPct = &pct
+		}
+		report.ISPs = append(report.ISPs, row)
+	}
+	for key, t := range endpoints {
+		report.Endpoints = append(report.Endpoints, availabilityRow(key, t.monitored, t.down, t.failures))
+	}
+	sortAvailabilityRows(report.ISPs)
+	sortAvailabilityRows(report.Endpoints)
+	return report, nil
+}
+
+// availabilityRow derives the report figures from accumulated seconds.
+// Outage time is capped at the monitored time, as an incident can span
+// time the monitor did not see.
+func availabilityRow(key reportKey, monitored, down float64, failures int) models.AvailabilityRow {
+	down = math.Min(down, monitored)
+	row := models.AvailabilityRow{
+		Period:           key.period,
+		ISP:              key.isp,
+		EndpointID:       key.endpointID,
+		MonitoredMinutes: round(monitored/60, 2),
+		OutageMinutes:    round(down/60, 2),
+		Failures:         failures,
+	}
+	up := monitored - down
+	if monitored > 0 {
+		pct := round(100*up/monitored, 3)
+		row.AvailabilityPct = &pct
+	}
+	if failures > 0 {
+		mtbf := round(up/float64(failures)/3600, 2)
+		mttr := round(down/float64(failures)/60, 2)
+		row.MTBFHours = &mtbf
+		row.MTTRMinutes = &mttr
+	}
+	return row
+}
+
+// sortAvailabilityRows orders rows by period, ISP and endpoint
+func sortAvailabilityRows(rows []models.AvailabilityRow) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Period != rows[j].Period {
+			return rows[i].Period < rows[j].Period
+		}
+		if rows[i].ISP != rows[j].ISP {
+			return rows[i].ISP < rows[j].ISP
+		}
+		return rows[i].EndpointID < rows[j].EndpointID
+	})
+}
+
+func round(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
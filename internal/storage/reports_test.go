@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// reportTestDB seeds two days either side of a month boundary:
+//
+//   - Comcast endpoint a: 31 Jan monitored all day with 1h down and one
+//     failure; 1 Feb up all day.
+//   - Comcast endpoint b: 31 Jan monitored half the day, all up; 1 Feb
+//     monitored all day with 2h down and two failures.
+//   - Starry endpoint s: 1 Feb monitored for 1h, all up.
+//   - Comcast incidents 31 Jan 23:00 - 1 Feb 01:00 and 1 Feb 10:00 - 10:30.
+//   - A Starry incident 1 Feb 12:00 - 14:00, longer than Starry was monitored.
+func reportTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := New(filepath.Join(t.TempDir(), "ccc.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	jan31 := time.Date(2026, 1, 31, 12, 0, 0, 0, time.Local)
+	feb1 := time.Date(2026, 2, 1, 12, 0, 0, 0, time.Local)
+	a := models.Endpoint{ID: "a", ISP: "Comcast"}
+	b := models.Endpoint{ID: "b", ISP: "Comcast"}
+	s := models.Endpoint{ID: "s", ISP: "Starry"}
+	with := func(ep models.Endpoint, status models.EndpointStatus) []models.Endpoint {
+		ep.Status = status
+		return []models.Endpoint{ep}
+	}
+
+	for _, r := range []struct {
+		at        time.Time
+		seconds   float64
+		endpoints []models.Endpoint
+	}{
+		{jan31, 82800, with(a, models.StatusUp)},
+		{jan31, 3600, with(a, models.StatusDown)},
+		{jan31, 43200, with(b, models.StatusUp)},
+		{feb1, 86400, with(a, models.StatusUp)},
+		{feb1, 79200, with(b, models.StatusDegraded)},
+		{feb1, 7200, with(b, models.StatusDown)},
+		{feb1, 3600, with(s, models.StatusUp)},
+		{feb1, 600, with(s, models.StatusUnknown)}, // Not probed yet; not counted
+	} {
+		if err := db.RecordAvailability(r.at, r.seconds, r.endpoints); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, f := range []struct {
+		at time.Time
+		ep models.Endpoint
+	}{{jan31, a}, {feb1, b}, {feb1, b}} {
+		if err := db.RecordEndpointFailure(f.at, f.ep); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, inc := range []struct {
+		isp        string
+		start, end time.Time
+	}{
+		{"Comcast", time.Date(2026, 1, 31, 23, 0, 0, 0, time.Local), time.Date(2026, 2, 1, 1, 0, 0, 0, time.Local)},
+		{"Comcast", time.Date(2026, 2, 1, 10, 0, 0, 0, time.Local), time.Date(2026, 2, 1, 10, 30, 0, 0, time.Local)},
+		{"Starry", time.Date(2026, 2, 1, 12, 0, 0, 0, time.Local), time.Date(2026, 2, 1, 14, 0, 0, 0, time.Local)},
+	} {
+		if _, err := db.conn.Exec(`
+			INSERT INTO incidents (isp, started_at, ended_at, peak_affected, total_endpoints, detection_method)
+			VALUES (?, ?, ?, 2, 2, ?)
+		`, inc.isp, inc.start, inc.end, models.DetectionThreshold); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// wantRow is an expected report row; negative optional figures mean nil
+type wantRow struct {
+	period, isp, endpoint string
+	monitored, outage     float64
+	availability          float64
+	endpointAvailability  float64
+	failures              int
+	mtbf, mttr            float64
+}
+
+func checkReportRows(t *testing.T, kind string, got []models.AvailabilityRow, want []wantRow) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s rows: got %d, want %d: %+v", kind, len(got), len(want), got)
+	}
+	optional := func(v *float64) float64 {
+		if v == nil {
+			return -1
+		}
+		return *v
+	}
+	for i, w := range want {
+		g := got[i]
+		have := wantRow{
+			g.Period, g.ISP, g.EndpointID, g.MonitoredMinutes, g.OutageMinutes,
+			optional(g.AvailabilityPct), optional(g.EndpointAvailabilityPct), g.Failures,
+			optional(g.MTBFHours), optional(g.MTTRMinutes),
+		}
+		if have != w {
+			t.Errorf("%s row %d:\n got %+v\nwant %+v", kind, i, have, w)
+		}
+	}
+}
+
+func TestAvailabilityReportDaily(t *testing.T) {
+	db := reportTestDB(t)
+	from := time.Date(2026, 1, 31, 0, 0, 0, 0, time.Local)
+	to := time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local)
+
+	report, err := db.GetAvailabilityReport(from, to, models.BreakdownDaily, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.From != "2026-01-31" || report.To != "2026-02-01" {
+		t.Errorf("range = %s..%s", report.From, report.To)
+	}
+
+	checkReportRows(t, "ISP", report.ISPs, []wantRow{
+		// The incident crossing midnight counts an hour on each day and a failure on the first
+		{"2026-01-31", "Comcast", "", 1440, 60, 95.833, 97.222, 1, 23, 60},
+		{"2026-02-01", "Comcast", "", 1440, 90, 93.75, 95.833, 1, 22.5, 90},
+		// Outage capped at the hour Starry was monitored
+		{"2026-02-01", "Starry", "", 60, 60, 0, 100, 1, 0, 60},
+	})
+	checkReportRows(t, "endpoint", report.Endpoints, []wantRow{
+		{"2026-01-31", "Comcast", "a", 1440, 60, 95.833, -1, 1, 23, 60},
+		{"2026-01-31", "Comcast", "b", 720, 0, 100, -1, 0, -1, -1},
+		{"2026-02-01", "Comcast", "a", 1440, 0, 100, -1, 0, -1, -1},
+		{"2026-02-01", "Comcast", "b", 1440, 120, 91.667, -1, 2, 11, 60},
+		{"2026-02-01", "Starry", "s", 60, 0, 100, -1, 0, -1, -1},
+	})
+}
+
+func TestAvailabilityReportMonthly(t *testing.T) {
+	db := reportTestDB(t)
+	from := time.Date(2026, 1, 30, 0, 0, 0, 0, time.Local)
+	to := time.Date(2026, 2, 2, 0, 0, 0, 0, time.Local)
+
+	report, err := db.GetAvailabilityReport(from, to, models.BreakdownMonthly, "Comcast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkReportRows(t, "ISP", report.ISPs, []wantRow{
+		{"2026-01", "Comcast", "", 1440, 60, 95.833, 97.222, 1, 23, 60},
+		{"2026-02", "Comcast", "", 1440, 90, 93.75, 95.833, 1, 22.5, 90},
+	})
+	checkReportRows(t, "endpoint", report.Endpoints, []wantRow{
+		{"2026-01", "Comcast", "a", 1440, 60, 95.833, -1, 1, 23, 60},
+		{"2026-01", "Comcast", "b", 720, 0, 100, -1, 0, -1, -1},
+		{"2026-02", "Comcast", "a", 1440, 0, 100, -1, 0, -1, -1},
+		{"2026-02", "Comcast", "b", 1440, 120, 91.667, -1, 2, 11, 60},
+	})
+}
+
+func TestAvailabilityReportWholeRange(t *testing.T) {
+	db := reportTestDB(t)
+	from := time.Date(2026, 1, 31, 0, 0, 0, 0, time.Local)
+	to := time.Date(2026, 2, 1, 0, 0, 0, 0, time.Local)
+
+	report, err := db.GetAvailabilityReport(from, to, models.BreakdownNone, "Comcast")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Monitored time is the most monitored endpoint per day, summed over days
+	checkReportRows(t, "ISP", report.ISPs, []wantRow{
+		{"2026-01-31/2026-02-01", "Comcast", "", 2880, 150, 94.792, 96.429, 2, 22.75, 75},
+	})
+}
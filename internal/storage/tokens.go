@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+)
+
+// Endpoint token kinds
+const (
+	// TokenDevice is held by the resident's browser and proves it registered
+	// the endpoint, e.g. to attach the other address family of a dual-stack
+	// connection
+	TokenDevice = "device"
+
+	// TokenDeletion lets the resident unregister the endpoint from any
+	// network, e.g. after moving out
+	TokenDeletion = "deletion"
+)
+
+// hashToken creates a SHA256 hash of a token; only hashes are stored
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// CreateEndpointToken issues a new random token of the given kind for an
+// endpoint. The plaintext token is returned once and never stored.
+func (db *DB) CreateEndpointToken(endpointID, kind string) (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := hex.EncodeToString(bytes)
+
+	_, err := db.conn.Exec(`
+		INSERT INTO endpoint_tokens (token_hash, endpoint_id, kind, created_at)
+		VALUES (?, ?, ?, ?)
+	`, hashToken(token), endpointID, kind, time.Now())
+	if err != nil {
+		return "", fmt.Errorf("failed to save token: %w", err)
+	}
+	return token, nil
+}
+
+// FindByToken returns the endpoint a token of the given kind belongs to,
+// or nil if the token is unknown
+func (db *DB) FindByToken(kind, token string) (*models.Endpoint, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	var endpointID string
+	err := db.conn.QueryRow(`
+		SELECT endpoint_id FROM endpoint_tokens WHERE token_hash = ? AND kind = ?
+	`, hashToken(token), kind).Scan(&endpointID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up token: %w", err)
+	}
+	return db.FindByID(endpointID)
+}
+
+// DeleteEndpointTokens removes all tokens issued for an endpoint
+func (db *DB) DeleteEndpointTokens(endpointID string) error {
+	if _, err := db.conn.Exec(`DELETE FROM endpoint_tokens WHERE endpoint_id = ?`, endpointID); err != nil {
+		return fmt.Errorf("failed to delete endpoint tokens: %w", err)
+	}
+	return nil
+}
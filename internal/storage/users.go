@@ -0,0 +1,272 @@
+package storage
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jonsson/ccc/internal/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultAdminUsername is the owner account managed by --set-password and
+// created from the legacy single admin password
+const DefaultAdminUsername = "admin"
+
+// dummyPasswordHash is compared against when a username does not exist, so
+// that unknown and known usernames take the same time to reject
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("ccc-dummy-password"), bcrypt.DefaultCost)
+	return hash
+})
+
+// CreateAdminUser creates an admin account with a bcrypt-hashed password
+func (db *DB) CreateAdminUser(username, password string, role models.AdminRole) (*models.AdminUser, error) {
+	if !role.Valid() {
+		return nil, fmt.Errorf("invalid role %q", role)
+	}
+
+	existing, err := db.GetAdminUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("user %q already exists", username)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	_, err = db.conn.Exec(`
+		INSERT INTO admin_users (username, password_hash, role, created_at)
+		VALUES (?, ?, ?, ?)
+	`, username, string(hash), string(role), time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+	return db.GetAdminUser(username)
+}
+
+// GetAdminUser returns an admin account by username (case-insensitive),
+// or nil if it does not exist
+func (db *DB) GetAdminUser(username string) (*models.AdminUser, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, username, role, disabled, created_at, last_login
+		FROM admin_users WHERE username = ?
+	`, username)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	defer rows.Close()
+
+	users, err := scanAdminUsers(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+	return &users[0], nil
+}
+
+// ListAdminUsers returns all admin accounts
+func (db *DB) ListAdminUsers() ([]models.AdminUser, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, username, role, disabled, created_at, last_login
+		FROM admin_users ORDER BY username ASC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+	return scanAdminUsers(rows)
+}
+
+// HasAdminUsers checks if at least one enabled admin account exists
+func (db *DB) HasAdminUsers() (bool, error) {
+	var count int
+	err := db.conn.QueryRow(`SELECT COUNT(*) FROM admin_users WHERE disabled = 0`).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to count users: %w", err)
+	}
+	return count > 0, nil
+}
+
+// SetAdminUserPassword changes a user's password and ends their sessions.
+// Returns false if the user does not exist.
+func (db *DB) SetAdminUserPassword(username, password string) (bool, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return false, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	result, err := db.conn.Exec(`UPDATE admin_users SET password_hash = ? WHERE username = ?`, string(hash), username)
+	if err != nil {
+		return false, fmt.Errorf("failed to save password: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	if count == 0 {
+		return false, nil
+	}
+	return true, db.deleteAdminUserSessions(username)
+}
+
+// SetAdminUserRole changes a user's role.
+// Returns false if the user does not exist.
+func (db *DB) SetAdminUserRole(username string, role models.AdminRole) (bool, error) {
+	if !role.Valid() {
+		return false, fmt.Errorf("invalid role %q", role)
+	}
+
+	result, err := db.conn.Exec(`UPDATE admin_users SET role = ? WHERE username = ?`, string(role), username)
+	if err != nil {
+		return false, fmt.Errorf("failed to update role: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return count > 0, nil
+}
+
+// SetAdminUserDisabled disables or re-enables a user. Disabling also ends
+// the user's sessions. Returns false if the user does not exist.
+func (db *DB) SetAdminUserDisabled(username string, disabled bool) (bool, error) {
+	result, err := db.conn.Exec(`UPDATE admin_users SET disabled = ? WHERE username = ?`, boolToInt(disabled), username)
+	if err != nil {
+		return false, fmt.Errorf("failed to update user: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	if count == 0 {
+		return false, nil
+	}
+	if disabled {
+		return true, db.deleteAdminUserSessions(username)
+	}
+	return true, nil
+}
+
+// AuthenticateAdminUser checks a username and password.
+// Returns nil if the user is unknown, disabled or the password is wrong.
+func (db *DB) AuthenticateAdminUser(username, password string) (*models.AdminUser, error) {
+	var hash string
+	var disabled int
+	err := db.conn.QueryRow(`
+		SELECT password_hash, disabled FROM admin_users WHERE username = ?
+	`, username).Scan(&hash, &disabled)
+	if err == sql.ErrNoRows {
+		bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil || disabled != 0 {
+		return nil, nil
+	}
+	return db.GetAdminUser(username)
+}
+
+// CreateAdminSession starts a session for a user and records the login.
+// The plaintext token is returned once; only its hash is stored.
+func (db *DB) CreateAdminSession(userID int64, ttl time.Duration) (string, time.Time, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate session token: %w", err)
+	}
+	token := hex.EncodeToString(bytes)
+
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	_, err := db.conn.Exec(`
+		INSERT INTO admin_sessions (token_hash, user_id, created_at, expires_at)
+		VALUES (?, ?, ?, ?)
+	`, hashToken(token), userID, now, expiresAt)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to save session: %w", err)
+	}
+
+	db.conn.Exec(`UPDATE admin_users SET last_login = ? WHERE id = ?`, now, userID)
+	return token, expiresAt, nil
+}
+
+// GetAdminSession returns the user a session token belongs to, or nil if
+// the token is unknown, expired or the user has been disabled
+func (db *DB) GetAdminSession(token string) (*models.AdminUser, error) {
+	if token == "" {
+		return nil, nil
+	}
+
+	var username, expiresAt string
+	err := db.conn.QueryRow(`
+		SELECT u.username, s.expires_at
+		FROM admin_sessions s JOIN admin_users u ON u.id = s.user_id
+		WHERE s.token_hash = ? AND u.disabled = 0
+	`, hashToken(token)).Scan(&username, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up session: %w", err)
+	}
+	if time.Now().After(parseTime(expiresAt)) {
+		return nil, nil
+	}
+	return db.GetAdminUser(username)
+}
+
+// DeleteAdminSession ends a session
+func (db *DB) DeleteAdminSession(token string) error {
+	if _, err := db.conn.Exec(`DELETE FROM admin_sessions WHERE token_hash = ?`, hashToken(token)); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
+
+// CleanupExpiredAdminSessions removes sessions past their expiry
+func (db *DB) CleanupExpiredAdminSessions() (int, error) {
+	result, err := db.conn.Exec(`DELETE FROM admin_sessions WHERE expires_at < ?`, time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup expired sessions: %w", err)
+	}
+	count, _ := result.RowsAffected()
+	return int(count), nil
+}
+
+// deleteAdminUserSessions ends all sessions of a user
+func (db *DB) deleteAdminUserSessions(username string) error {
+	_, err := db.conn.Exec(`
+		DELETE FROM admin_sessions
+		WHERE user_id IN (SELECT id FROM admin_users WHERE username = ?)
+	`, username)
+	if err != nil {
+		return fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	return nil
+}
+
+// scanAdminUsers is a helper to scan admin user rows
+func scanAdminUsers(rows *sql.Rows) ([]models.AdminUser, error) {
+	var users []models.AdminUser
+	for rows.Next() {
+		var u models.AdminUser
+		var role, createdAt string
+		var disabled int
+		var lastLogin sql.NullString
+		if err := rows.Scan(&u.ID, &u.Username, &role, &disabled, &createdAt, &lastLogin); err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		u.Role = models.AdminRole(role)
+		u.Disabled = disabled != 0
+		u.CreatedAt = parseTime(createdAt)
+		if lastLogin.Valid {
+			t := parseTime(lastLogin.String)
+			u.LastLogin = &t
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,131 @@
+// Package stream is an in-process pub/sub broker for live updates pushed to
+// dashboard clients over Server-Sent Events.
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Message types
+const (
+	TypeDashboard = "dashboard" // models.DashboardResponse snapshot after a ping cycle
+	TypeEvent     = "event"     // models.Event as it is recorded
+)
+
+const (
+	defaultBacklog   = 256 // Messages kept for Last-Event-ID resume
+	subscriberBuffer = 32  // Messages queued per subscriber before it is dropped
+)
+
+// Message is a published update. IDs increase monotonically.
+type Message struct {
+	ID   uint64
+	Type string
+	Data []byte // JSON payload
+}
+
+// Subscription receives messages published after it was created
+type Subscription struct {
+	C       <-chan Message // Closed if the subscriber falls too far behind
+	Replay  []Message      // Buffered messages after the requested Last-Event-ID
+	Resumed bool           // False if the requested ID was zero or no longer buffered
+	Head    uint64         // ID of the latest message at subscribe time
+
+	ch chan Message
+}
+
+// Broker fans published messages out to subscribers and keeps a short
+// backlog so reconnecting clients can resume without missing events
+type Broker struct {
+	mu      sync.Mutex
+	nextID  uint64
+	backlog []Message
+	size    int
+	subs    map[*Subscription]struct{}
+}
+
+// NewBroker creates a broker that keeps the last backlog messages for resume.
+// A backlog of 0 uses the default.
+func NewBroker(backlog int) *Broker {
+	if backlog <= 0 {
+		backlog = defaultBacklog
+	}
+	return &Broker{
+		nextID: 1,
+		size:   backlog,
+		subs:   make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish encodes v as JSON and delivers it to all subscribers.
+// Subscribers whose buffer is full are disconnected; they can resume with
+// Last-Event-ID once they reconnect.
+func (b *Broker) Publish(msgType string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s message: %w", msgType, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	msg := Message{ID: b.nextID, Type: msgType, Data: data}
+	b.nextID++
+
+	b.backlog = append(b.backlog, msg)
+	if len(b.backlog) > b.size {
+		b.backlog = b.backlog[len(b.backlog)-b.size:]
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- msg:
+		default:
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}
+	return nil
+}
+
+// Subscribe registers a subscriber. If lastID is still in the backlog, the
+// messages after it are returned in Replay.
+func (b *Broker) Subscribe(lastID uint64) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Message, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, Head: b.nextID - 1}
+
+	if lastID > 0 && lastID <= sub.Head && len(b.backlog) > 0 && lastID >= b.backlog[0].ID-1 {
+		sub.Resumed = true
+		for _, msg := range b.backlog {
+			if msg.ID > lastID {
+				sub.Replay = append(sub.Replay, msg)
+			}
+		}
+	}
+
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes a subscriber
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// Subscribers returns the number of connected subscribers
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
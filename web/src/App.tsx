@@ -1,8 +1,9 @@
 import { useEffect, useState } from 'react';
-import { getStatus, getDashboard, getEvents, getSiteConfig } from './api';
+import { getStatus, getDashboard, getEvents, getSiteConfig, getDeviceToken, clearDeviceToken, getDeletionToken, linkAddress, unregister, subscribeToStream } from './api';
 import type { StatusResponse, DashboardResponse, Event, SiteConfig } from './types';
 import Dashboard from './components/Dashboard';
 import OptInPrompt from './components/OptInPrompt';
+import ParticipationControls from './components/ParticipationControls';
 import Admin from './components/Admin';
 import About from './components/About';
 
@@ -74,12 +75,24 @@ function App() {
 
   const fetchData = async () => {
     try {
-      const [statusData, dashboardData, eventsData, siteConfigData] = await Promise.all([
+      const [initialStatus, dashboardData, eventsData, siteConfigData] = await Promise.all([
         getStatus(),
         getDashboard(),
         getEvents(),
         getSiteConfig(),
       ]);
+      let statusData = initialStatus;
+      // Visiting over the other address family of a dual-stack connection:
+      // link this address to the endpoint this browser already registered
+      const deviceToken = getDeviceToken();
+      if (!statusData.registered && statusData.can_register && deviceToken) {
+        try {
+          await linkAddress(deviceToken);
+          statusData = await getStatus();
+        } catch {
+          clearDeviceToken();
+        }
+      }
       setStatus(statusData);
       setDashboard(dashboardData);
       setEvents(eventsData.events || []);
@@ -99,14 +112,45 @@ function App() {
       setShowAbout(true);
     }
     fetchData();
-    const interval = setInterval(fetchData, 30000);
-    return () => clearInterval(interval);
+
+    // With live updates the dashboard and events arrive as they happen;
+    // polling only refreshes the visitor's own status
+    if (typeof EventSource === 'undefined') {
+      const interval = setInterval(fetchData, 30000);
+      return () => clearInterval(interval);
+    }
+    const interval = setInterval(fetchData, 60000);
+    const unsubscribe = subscribeToStream({
+      onDashboard: setDashboard,
+      onEvent: (event) => {
+        setEvents((prev) => [event, ...prev.filter((e) => e.id !== event.id)].slice(0, 50));
+      },
+    });
+    return () => {
+      clearInterval(interval);
+      unsubscribe();
+    };
   }, []);
 
   const handleRegistered = () => {
     fetchData();
   };
 
+  // This browser registered a connection that is not the current one,
+  // e.g. before moving out; its deletion token can still remove it
+  const handleRemovePrevious = async () => {
+    if (!confirm('Delete the registration this browser made from another connection?')) {
+      return;
+    }
+    try {
+      await unregister();
+      setError(null);
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to remove registration');
+    }
+    fetchData();
+  };
+
   const navigateToHome = () => {
     window.history.pushState({}, '', '/');
     setShowAdmin(false);
@@ -260,12 +304,23 @@ function App() {
       )}
 
       {status && !status.registered && status.can_register && (
-        <OptInPrompt isp={status.isp} onRegistered={handleRegistered} colors={colors} />
+        <OptInPrompt isp={status.isp} requires={status.registration_requires} onRegistered={handleRegistered} colors={colors} />
+      )}
+
+      {status && !status.registered && getDeletionToken() && (
+        <div style={styles.registered}>
+          This browser registered another connection for monitoring.{' '}
+          <span style={{ ...styles.navLink, display: 'inline-block' }} onClick={handleRemovePrevious}>
+            Remove it
+          </span>
+        </div>
       )}
 
       {status && status.registered && (
         <div style={styles.registered}>
-          {status.endpoint_status === 'unreachable' ? (
+          {status.paused_until ? (
+            <>You're participating in monitoring ({status.isp})</>
+          ) : status.endpoint_status === 'down' ? (
             <>
               <div style={{ marginBottom: '8px' }}>
                 You've joined monitoring ({status.isp}), but your connection doesn't respond to our checks.
@@ -276,11 +331,12 @@ function App() {
             </>
           ) : status.endpoint_status === 'up' ? (
             <>You're participating in monitoring and online ({status.isp})</>
-          ) : status.endpoint_status === 'down' ? (
-            <>You're participating but currently appear offline ({status.isp})</>
+          ) : status.endpoint_status === 'degraded' ? (
+            <>You're participating and online, but seeing packet loss ({status.isp})</>
           ) : (
             <>You're participating in monitoring ({status.isp})</>
           )}
+          <ParticipationControls status={status} colors={colors} onChanged={fetchData} />
         </div>
       )}
 
@@ -1,4 +1,4 @@
-import type { StatusResponse, RegisterResponse, DashboardResponse, HealthResponse, AdminEndpoint, AdminAddRequest, AdminMetrics, EventsResponse, AdminSettings, SiteConfig } from './types';
+import type { StatusResponse, RegisterResponse, PauseResponse, DashboardResponse, HealthResponse, AdminEndpoint, AdminAddRequest, AdminMetrics, Event, EventsResponse, AdminSettings, SiteConfig, AdminSession } from './types';
 
 const API_BASE = '/api';
 
@@ -22,23 +22,92 @@ async function fetchJSON<T>(url: string, options?: RequestInit): Promise<T> {
   return response.json();
 }
 
-function authHeader(password: string): Record<string, string> {
-  // Basic auth with username "admin" and provided password
-  const credentials = btoa(`admin:${password}`);
-  return { Authorization: `Basic ${credentials}` };
-}
-
 export async function getHealth(): Promise<HealthResponse> {
   return fetchJSON<HealthResponse>(`${API_BASE}/health`);
 }
 
+const DEVICE_TOKEN_KEY = 'ccc_device_token';
+
+// The device token lets the server recognise this browser's endpoint after
+// the ISP changes the connection's address
 export async function getStatus(): Promise<StatusResponse> {
-  return fetchJSON<StatusResponse>(`${API_BASE}/status`);
+  const deviceToken = getDeviceToken();
+  return fetchJSON<StatusResponse>(`${API_BASE}/status`, {
+    headers: deviceToken ? { 'X-Device-Token': deviceToken } : undefined,
+  });
+}
+
+export function getDeviceToken(): string | null {
+  return localStorage.getItem(DEVICE_TOKEN_KEY);
+}
+
+export function clearDeviceToken(): void {
+  localStorage.removeItem(DEVICE_TOKEN_KEY);
+}
+
+// The deletion token lets this browser unregister the endpoint from any
+// network, e.g. after moving out
+const DELETION_TOKEN_KEY = 'ccc_deletion_token';
+
+export function getDeletionToken(): string | null {
+  return localStorage.getItem(DELETION_TOKEN_KEY);
 }
 
-export async function register(): Promise<RegisterResponse> {
+// Invite code and passphrase are only needed when the admission policy
+// requires them (see StatusResponse.registration_requires)
+export async function register(admission?: { invite_code?: string; passphrase?: string }): Promise<RegisterResponse> {
+  const result = await fetchJSON<RegisterResponse>(`${API_BASE}/register`, {
+    method: 'POST',
+    body: admission ? JSON.stringify(admission) : undefined,
+  });
+  if (result.device_token) {
+    localStorage.setItem(DEVICE_TOKEN_KEY, result.device_token);
+  }
+  if (result.deletion_token) {
+    localStorage.setItem(DELETION_TOKEN_KEY, result.deletion_token);
+  }
+  return result;
+}
+
+// Unregister with the stored deletion token if there is one, otherwise the
+// endpoint registered from this connection's address
+export async function unregister(): Promise<void> {
+  const deletionToken = getDeletionToken();
+  if (deletionToken) {
+    try {
+      await fetchJSON<{ message: string }>(`${API_BASE}/register`, {
+        method: 'DELETE',
+        body: JSON.stringify({ deletion_token: deletionToken }),
+      });
+      localStorage.removeItem(DELETION_TOKEN_KEY);
+      clearDeviceToken();
+      return;
+    } catch (err) {
+      // The endpoint may have expired since; fall back to this address
+      if (!(err instanceof Error) || err.message !== 'Unknown deletion token') {
+        throw err;
+      }
+      localStorage.removeItem(DELETION_TOKEN_KEY);
+    }
+  }
+  await fetchJSON<{ message: string }>(`${API_BASE}/register`, { method: 'DELETE' });
+  clearDeviceToken();
+}
+
+// Pause monitoring of this connection for a number of days; 0 resumes it
+export async function pauseMonitoring(days: number): Promise<PauseResponse> {
+  return fetchJSON<PauseResponse>(`${API_BASE}/register/pause`, {
+    method: 'POST',
+    body: JSON.stringify({ days }),
+  });
+}
+
+// Attach this connection's address (e.g. the IPv6 side of a dual-stack
+// connection) to the endpoint this browser registered earlier
+export async function linkAddress(deviceToken: string): Promise<RegisterResponse> {
   return fetchJSON<RegisterResponse>(`${API_BASE}/register`, {
     method: 'POST',
+    body: JSON.stringify({ device_token: deviceToken }),
   });
 }
 
@@ -50,44 +119,75 @@ export async function getEvents(): Promise<EventsResponse> {
   return fetchJSON<EventsResponse>(`${API_BASE}/events`);
 }
 
-// Admin API (requires password)
-export async function adminListEndpoints(password: string): Promise<AdminEndpoint[]> {
-  return fetchJSON<AdminEndpoint[]>(`${API_BASE}/admin/endpoints`, {
-    headers: authHeader(password),
+// Live updates over Server-Sent Events. The browser reconnects on its own
+// and resumes from the last received message. Returns a close function.
+export function subscribeToStream(handlers: {
+  onDashboard: (dashboard: DashboardResponse) => void;
+  onEvent: (event: Event) => void;
+}): () => void {
+  const source = new EventSource(`${API_BASE}/stream`);
+  source.addEventListener('dashboard', (e) => {
+    handlers.onDashboard(JSON.parse((e as MessageEvent).data));
+  });
+  source.addEventListener('event', (e) => {
+    handlers.onEvent(JSON.parse((e as MessageEvent).data));
+  });
+  return () => source.close();
+}
+
+// Admin session (HttpOnly cookie set by the server)
+export async function adminLogin(username: string, password: string): Promise<AdminSession> {
+  return fetchJSON<AdminSession>(`${API_BASE}/admin/login`, {
+    method: 'POST',
+    body: JSON.stringify({ username, password }),
   });
 }
 
-export async function adminAddEndpoint(password: string, data: AdminAddRequest): Promise<AdminEndpoint> {
+export async function adminLogout(): Promise<void> {
+  await fetchJSON<{ message: string }>(`${API_BASE}/admin/logout`, { method: 'POST' });
+}
+
+export async function adminMe(): Promise<AdminSession> {
+  return fetchJSON<AdminSession>(`${API_BASE}/admin/me`);
+}
+
+// Admin API (requires a session)
+export async function adminListEndpoints(): Promise<AdminEndpoint[]> {
+  return fetchJSON<AdminEndpoint[]>(`${API_BASE}/admin/endpoints`);
+}
+
+export async function adminAddEndpoint(data: AdminAddRequest): Promise<AdminEndpoint> {
   return fetchJSON<AdminEndpoint>(`${API_BASE}/admin/endpoints`, {
     method: 'POST',
-    headers: authHeader(password),
     body: JSON.stringify(data),
   });
 }
 
-export async function adminDeleteEndpoint(password: string, id: string): Promise<void> {
+export async function adminDeleteEndpoint(id: string): Promise<void> {
   await fetchJSON<{ message: string }>(`${API_BASE}/admin/endpoints/${encodeURIComponent(id)}`, {
     method: 'DELETE',
-    headers: authHeader(password),
   });
 }
 
-export async function adminGetMetrics(password: string): Promise<AdminMetrics> {
-  return fetchJSON<AdminMetrics>(`${API_BASE}/admin/metrics`, {
-    headers: authHeader(password),
+// An empty strategy returns the endpoint to the server's default
+export async function adminSetProbeStrategy(id: string, strategy: string[]): Promise<AdminEndpoint> {
+  return fetchJSON<AdminEndpoint>(`${API_BASE}/admin/endpoints/${encodeURIComponent(id)}/probes`, {
+    method: 'PUT',
+    body: JSON.stringify({ probe_strategy: strategy }),
   });
 }
 
-export async function adminGetSettings(password: string): Promise<AdminSettings> {
-  return fetchJSON<AdminSettings>(`${API_BASE}/admin/settings`, {
-    headers: authHeader(password),
-  });
+export async function adminGetMetrics(): Promise<AdminMetrics> {
+  return fetchJSON<AdminMetrics>(`${API_BASE}/admin/metrics`);
+}
+
+export async function adminGetSettings(): Promise<AdminSettings> {
+  return fetchJSON<AdminSettings>(`${API_BASE}/admin/settings`);
 }
 
-export async function adminUpdateSettings(password: string, settings: AdminSettings): Promise<AdminSettings> {
+export async function adminUpdateSettings(settings: AdminSettings): Promise<AdminSettings> {
   return fetchJSON<AdminSettings>(`${API_BASE}/admin/settings`, {
     method: 'PUT',
-    headers: authHeader(password),
     body: JSON.stringify(settings),
   });
 }
@@ -98,16 +198,13 @@ export async function getSiteConfig(): Promise<SiteConfig> {
 }
 
 // Admin site config
-export async function adminGetSiteConfig(password: string): Promise<SiteConfig> {
-  return fetchJSON<SiteConfig>(`${API_BASE}/admin/site-config`, {
-    headers: authHeader(password),
-  });
+export async function adminGetSiteConfig(): Promise<SiteConfig> {
+  return fetchJSON<SiteConfig>(`${API_BASE}/admin/site-config`);
 }
 
-export async function adminUpdateSiteConfig(password: string, config: SiteConfig): Promise<SiteConfig> {
+export async function adminUpdateSiteConfig(config: SiteConfig): Promise<SiteConfig> {
   return fetchJSON<SiteConfig>(`${API_BASE}/admin/site-config`, {
     method: 'PUT',
-    headers: authHeader(password),
     body: JSON.stringify(config),
   });
 }
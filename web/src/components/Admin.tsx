@@ -1,8 +1,9 @@
 import { useState, useEffect } from 'react';
-import { adminListEndpoints, adminAddEndpoint, adminDeleteEndpoint, adminGetMetrics, adminGetSettings, adminUpdateSettings, adminGetSiteConfig, adminUpdateSiteConfig } from '../api';
-import type { AdminEndpoint, AdminMetrics, AdminSettings, SiteConfig } from '../types';
+import { adminLogin, adminLogout, adminMe, adminListEndpoints, adminAddEndpoint, adminDeleteEndpoint, adminSetProbeStrategy, adminGetMetrics, adminGetSettings, adminUpdateSettings, adminGetSiteConfig, adminUpdateSiteConfig } from '../api';
+import type { AdminEndpoint, AdminMetrics, AdminSettings, AdminUser, SiteConfig } from '../types';
 import type { ThemeColors } from '../App';
 import SiteConfigEditor from './SiteConfigEditor';
+import OutagePolicyEditor from './OutagePolicyEditor';
 
 interface AdminProps {
   onBack: () => void;
@@ -10,7 +11,7 @@ interface AdminProps {
 }
 
 function Admin({ onBack, colors }: AdminProps) {
-  const [password, setPassword] = useState(() => sessionStorage.getItem('adminPassword') || '');
+  const [user, setUser] = useState<AdminUser | null>(null);
   const [isLoggedIn, setIsLoggedIn] = useState(false);
   const [endpoints, setEndpoints] = useState<AdminEndpoint[]>([]);
   const [metrics, setMetrics] = useState<AdminMetrics | null>(null);
@@ -19,6 +20,7 @@ function Admin({ onBack, colors }: AdminProps) {
   const [newIP, setNewIP] = useState('');
   const [newISP, setNewISP] = useState('');
   const [adding, setAdding] = useState(false);
+  const [loginUsername, setLoginUsername] = useState('');
   const [loginPassword, setLoginPassword] = useState('');
   const [activeTab, setActiveTab] = useState<'metrics' | 'endpoints' | 'settings' | 'site-config'>('metrics');
   const [settings, setSettings] = useState<AdminSettings | null>(null);
@@ -140,6 +142,10 @@ function Admin({ onBack, colors }: AdminProps) {
       color: colors.danger,
       fontWeight: 'bold',
     },
+    statusDegraded: {
+      color: colors.warning,
+      fontWeight: 'bold',
+    },
     statusUnknown: {
       color: colors.textMuted,
     },
@@ -270,14 +276,16 @@ function Admin({ onBack, colors }: AdminProps) {
     },
   };
 
-  const fetchData = async (pwd: string) => {
+  const canOperate = user?.role === 'operator' || user?.role === 'owner';
+
+  const fetchData = async () => {
     setLoading(true);
     try {
       const [endpointsData, metricsData, settingsData, siteConfigData] = await Promise.all([
-        adminListEndpoints(pwd),
-        adminGetMetrics(pwd),
-        adminGetSettings(pwd),
-        adminGetSiteConfig(pwd),
+        adminListEndpoints(),
+        adminGetMetrics(),
+        adminGetSettings(),
+        adminGetSiteConfig(),
       ]);
       setEndpoints(endpointsData || []);
       setMetrics(metricsData);
@@ -285,13 +293,11 @@ function Admin({ onBack, colors }: AdminProps) {
       setSiteConfig(siteConfigData);
       setError(null);
       setIsLoggedIn(true);
-      sessionStorage.setItem('adminPassword', pwd);
-      setPassword(pwd);
     } catch (err) {
       if (err instanceof Error && err.message === 'Authentication required') {
         setIsLoggedIn(false);
-        sessionStorage.removeItem('adminPassword');
-        setError('Invalid password');
+        setUser(null);
+        setError('Session expired, please log in again');
       } else {
         setError(err instanceof Error ? err.message : 'Failed to load data');
       }
@@ -301,26 +307,44 @@ function Admin({ onBack, colors }: AdminProps) {
   };
 
   useEffect(() => {
-    if (password) {
-      fetchData(password);
-    }
+    // Resume an existing session cookie, if any
+    adminMe()
+      .then((session) => {
+        setUser(session.user);
+        fetchData();
+      })
+      .catch(() => {});
   }, []);
 
   useEffect(() => {
     if (!isLoggedIn) return;
-    const interval = setInterval(() => fetchData(password), 10000);
+    const interval = setInterval(() => fetchData(), 10000);
     return () => clearInterval(interval);
-  }, [isLoggedIn, password]);
+  }, [isLoggedIn]);
 
   const handleLogin = async (e: React.FormEvent) => {
     e.preventDefault();
     setError(null);
-    await fetchData(loginPassword);
+    setLoading(true);
+    try {
+      const session = await adminLogin(loginUsername, loginPassword);
+      setUser(session.user);
+      setLoginPassword('');
+    } catch (err) {
+      setLoading(false);
+      if (err instanceof Error && err.message === 'Authentication required') {
+        setError('Invalid username or password');
+      } else {
+        setError(err instanceof Error ? err.message : 'Login failed');
+      }
+      return;
+    }
+    await fetchData();
   };
 
-  const handleLogout = () => {
-    sessionStorage.removeItem('adminPassword');
-    setPassword('');
+  const handleLogout = async () => {
+    await adminLogout().catch(() => {});
+    setUser(null);
     setIsLoggedIn(false);
     setEndpoints([]);
     setMetrics(null);
@@ -328,11 +352,11 @@ function Admin({ onBack, colors }: AdminProps) {
     setSiteConfig(null);
   };
 
-  const handleSaveSettings = async (newThreshold: number) => {
+  const handleSaveSettings = async (newSettings: AdminSettings) => {
     setSavingSettings(true);
     setError(null);
     try {
-      const updated = await adminUpdateSettings(password, { outage_threshold: newThreshold });
+      const updated = await adminUpdateSettings(newSettings);
       setSettings(updated);
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to save settings');
@@ -345,7 +369,7 @@ function Admin({ onBack, colors }: AdminProps) {
     setSavingSiteConfig(true);
     setError(null);
     try {
-      const updated = await adminUpdateSiteConfig(password, config);
+      const updated = await adminUpdateSiteConfig(config);
       setSiteConfig(updated);
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to save site config');
@@ -374,13 +398,14 @@ function Admin({ onBack, colors }: AdminProps) {
     setError(null);
 
     try {
-      await adminAddEndpoint(password, {
-        ipv4: newIP.trim(),
+      const ip = newIP.trim();
+      await adminAddEndpoint({
+        ...(ip.includes(':') ? { ipv6: ip } : { ipv4: ip }),
         isp: newISP.trim() || undefined,
       });
       setNewIP('');
       setNewISP('');
-      await fetchData(password);
+      await fetchData();
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to add endpoint');
     } finally {
@@ -392,13 +417,28 @@ function Admin({ onBack, colors }: AdminProps) {
     if (!confirm(`Delete endpoint ${id}?`)) return;
 
     try {
-      await adminDeleteEndpoint(password, id);
-      await fetchData(password);
+      await adminDeleteEndpoint(id);
+      await fetchData();
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to delete endpoint');
     }
   };
 
+  const handleProbes = async (ep: AdminEndpoint) => {
+    const input = prompt(
+      `Probes for ${ep.id}, tried in order (e.g. tcp:443, udp:53, http, icmp). Leave empty for the default.`,
+      (ep.probe_strategy ?? []).join(', '),
+    );
+    if (input === null) return;
+
+    try {
+      await adminSetProbeStrategy(ep.id, input.split(',').map((s) => s.trim()).filter(Boolean));
+      await fetchData();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Failed to set probes');
+    }
+  };
+
   const formatTime = (isoString?: string) => {
     if (!isoString || isoString.startsWith('0001')) return '-';
     try {
@@ -411,6 +451,7 @@ function Admin({ onBack, colors }: AdminProps) {
   const getStatusStyle = (status: string) => {
     switch (status) {
       case 'up': return styles.statusUp;
+      case 'degraded': return styles.statusDegraded;
       case 'down': return styles.statusDown;
       default: return styles.statusUnknown;
     }
@@ -425,16 +466,25 @@ function Admin({ onBack, colors }: AdminProps) {
         </div>
 
         <div style={styles.loginForm}>
-          <div style={styles.loginTitle}>Enter Admin Password</div>
+          <div style={styles.loginTitle}>Sign in to the admin panel</div>
           {error && <div style={styles.error}>{error}</div>}
           <form onSubmit={handleLogin}>
+            <input
+              type="text"
+              placeholder="Username"
+              autoComplete="username"
+              value={loginUsername}
+              onChange={(e) => setLoginUsername(e.target.value)}
+              style={{ ...styles.input, width: '100%', marginBottom: '10px' }}
+              autoFocus
+            />
             <input
               type="password"
               placeholder="Password"
+              autoComplete="current-password"
               value={loginPassword}
               onChange={(e) => setLoginPassword(e.target.value)}
               style={{ ...styles.input, width: '100%', marginBottom: '10px' }}
-              autoFocus
             />
             <button
               type="submit"
@@ -601,12 +651,12 @@ function Admin({ onBack, colors }: AdminProps) {
 
   const renderEndpoints = () => (
     <>
-      <div style={styles.addForm}>
+      {canOperate && <div style={styles.addForm}>
         <form onSubmit={handleAdd}>
           <div style={styles.formRow}>
             <input
               type="text"
-              placeholder="IP Address (e.g., 1.2.3.4)"
+              placeholder="IP Address (e.g., 1.2.3.4 or 2001:db8::1)"
               value={newIP}
               onChange={(e) => setNewIP(e.target.value)}
               style={styles.input}
@@ -628,7 +678,7 @@ function Admin({ onBack, colors }: AdminProps) {
             </button>
           </div>
         </form>
-      </div>
+      </div>}
 
       {loading && endpoints.length === 0 ? (
         <div style={styles.loading}>Loading...</div>
@@ -652,7 +702,7 @@ function Admin({ onBack, colors }: AdminProps) {
             {endpoints.map((ep) => (
               <tr key={ep.id}>
                 <td style={styles.td}>{ep.id}</td>
-                <td style={styles.td}>{ep.ipv4}</td>
+                <td style={styles.td}>{[ep.ipv4, ep.ipv6].filter(Boolean).join(', ')}</td>
                 <td style={styles.td}>{ep.isp.toUpperCase()}</td>
                 <td style={{ ...styles.td, ...getStatusStyle(ep.status) }}>
                   {ep.status.toUpperCase()}
@@ -661,16 +711,27 @@ function Admin({ onBack, colors }: AdminProps) {
                   {ep.use_hop && ep.monitored_hop
                     ? `Hop ${ep.hop_number}: ${ep.monitored_hop}`
                     : 'Direct'}
+                  {ep.probe_strategy && ` (${ep.probe_strategy.join(', ')})`}
                 </td>
                 <td style={styles.td}>{formatTime(ep.last_seen)}</td>
                 <td style={styles.td}>{formatTime(ep.last_ok)}</td>
                 <td style={styles.td}>
-                  <button
-                    style={{ ...styles.button, ...styles.deleteButton }}
-                    onClick={() => handleDelete(ep.id)}
-                  >
-                    Delete
-                  </button>
+                  {canOperate && (
+                    <>
+                      <button
+                        style={{ ...styles.button, ...styles.addButton }}
+                        onClick={() => handleProbes(ep)}
+                      >
+                        Probes
+                      </button>{' '}
+                      <button
+                        style={{ ...styles.button, ...styles.deleteButton }}
+                        onClick={() => handleDelete(ep.id)}
+                      >
+                        Delete
+                      </button>
+                    </>
+                  )}
                 </td>
               </tr>
             ))}
@@ -680,53 +741,16 @@ function Admin({ onBack, colors }: AdminProps) {
     </>
   );
 
-  const renderSettings = () => {
-    const currentThreshold = settings?.outage_threshold ?? 0.5;
-    const thresholdPercent = Math.round(currentThreshold * 100);
-
-    return (
-      <div style={styles.section}>
-        <div style={styles.sectionTitle}>Outage Detection</div>
-        <p style={{ color: colors.textMuted, marginBottom: '20px', lineHeight: 1.6 }}>
-          Configure the threshold for detecting ISP outages. When the percentage of down endpoints
-          for an ISP exceeds this threshold, the dashboard will show a "likely outage" warning.
-        </p>
-        <div style={{ marginBottom: '20px' }}>
-          <div style={{ marginBottom: '10px', display: 'flex', alignItems: 'center', gap: '15px' }}>
-            <label style={{ color: colors.text, fontWeight: 'bold' }}>
-              Outage Threshold: {thresholdPercent}%
-            </label>
-          </div>
-          <div style={{ display: 'flex', alignItems: 'center', gap: '15px' }}>
-            <span style={{ color: colors.textMuted, fontSize: '0.875rem' }}>10%</span>
-            <input
-              type="range"
-              min="10"
-              max="90"
-              step="5"
-              value={thresholdPercent}
-              onChange={(e) => {
-                const newThreshold = parseInt(e.target.value) / 100;
-                handleSaveSettings(newThreshold);
-              }}
-              disabled={savingSettings}
-              style={{ flex: 1, cursor: savingSettings ? 'not-allowed' : 'pointer' }}
-            />
-            <span style={{ color: colors.textMuted, fontSize: '0.875rem' }}>90%</span>
-          </div>
-          <p style={{ color: colors.textDimmed, fontSize: '0.75rem', marginTop: '10px' }}>
-            {savingSettings ? 'Saving...' : `Outage will be detected when >${thresholdPercent}% of endpoints for an ISP are down.`}
-          </p>
-        </div>
-      </div>
-    );
-  };
-
   return (
     <div style={styles.container}>
       <div style={styles.header}>
         <h1 style={styles.title}>Admin Dashboard</h1>
         <div>
+          {user && (
+            <span style={{ marginRight: '10px', fontSize: '0.875rem' }}>
+              {user.username} ({user.role})
+            </span>
+          )}
           <button
             style={{ ...styles.button, ...styles.logoutButton, marginRight: '10px' }}
             onClick={handleLogout}
@@ -780,7 +804,15 @@ function Admin({ onBack, colors }: AdminProps) {
 
       {activeTab === 'metrics' && renderMetrics()}
       {activeTab === 'endpoints' && renderEndpoints()}
-      {activeTab === 'settings' && renderSettings()}
+      {activeTab === 'settings' && (
+        <OutagePolicyEditor
+          settings={settings}
+          isps={metrics?.isp_stats.map((isp) => isp.name) ?? []}
+          onSave={handleSaveSettings}
+          saving={savingSettings}
+          colors={colors}
+        />
+      )}
       {activeTab === 'site-config' && (
         <SiteConfigEditor
           siteConfig={siteConfig}
@@ -136,6 +136,14 @@ function Dashboard({ data, currentISP, colors, events }: DashboardProps) {
         return { bg: colors.dangerBg, color: colors.danger, icon: '!' };
       case 'recovery':
         return { bg: colors.successBg, color: colors.success, icon: '✓' };
+      case 'monitor_offline':
+        return { bg: colors.border, color: colors.textMuted, icon: '⏸' };
+      case 'monitor_online':
+        return { bg: colors.border, color: colors.textMuted, icon: '▶' };
+      case 'ip_changed':
+        return { bg: colors.border, color: colors.textMuted, icon: '⇄' };
+      case 'disagreement':
+        return { bg: colors.border, color: colors.textMuted, icon: '≠' };
       default:
         return { bg: colors.border, color: colors.textMuted, icon: '•' };
     }
@@ -143,7 +151,16 @@ function Dashboard({ data, currentISP, colors, events }: DashboardProps) {
 
   return (
     <div style={styles.container}>
-      {data.likely_outage && (
+      {data.monitor_offline && (
+        <div style={styles.outageAlert}>
+          <div style={styles.outageTitle}>Monitoring Paused</div>
+          <div style={styles.outageSubtitle}>
+            The monitoring server has lost its own internet connection. Statuses below are from before it went offline.
+          </div>
+        </div>
+      )}
+
+      {!data.monitor_offline && data.likely_outage && (
         <div style={styles.outageAlert}>
           <div style={styles.outageTitle}>Possible Outage Detected</div>
           <div style={styles.outageSubtitle}>
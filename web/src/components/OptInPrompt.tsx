@@ -4,20 +4,28 @@ import type { ThemeColors } from '../App';
 
 interface OptInPromptProps {
   isp: string;
+  requires?: string[];
   onRegistered: () => void;
   colors: ThemeColors;
 }
 
-function OptInPrompt({ isp, onRegistered, colors }: OptInPromptProps) {
+function OptInPrompt({ isp, requires = [], onRegistered, colors }: OptInPromptProps) {
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState<string | null>(null);
+  const [inviteCode, setInviteCode] = useState('');
+  const [passphrase, setPassphrase] = useState('');
+  const needsInvite = requires.includes('invite');
+  const needsPassphrase = requires.includes('passphrase');
 
   const handleRegister = async () => {
     setLoading(true);
     setError(null);
 
     try {
-      await register();
+      await register({
+        invite_code: needsInvite ? inviteCode.trim() : undefined,
+        passphrase: needsPassphrase ? passphrase : undefined,
+      });
       onRegistered();
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to join monitoring');
@@ -55,6 +63,19 @@ function OptInPrompt({ isp, onRegistered, colors }: OptInPromptProps) {
       marginBottom: '15px',
       fontSize: '0.875rem',
     },
+    input: {
+      display: 'block',
+      width: '100%',
+      maxWidth: '280px',
+      margin: '0 auto 12px',
+      padding: '10px',
+      borderRadius: '8px',
+      border: `1px solid ${colors.border}`,
+      background: colors.bgCard,
+      color: colors.text,
+      fontSize: '1rem',
+      boxSizing: 'border-box' as const,
+    },
     button: {
       background: colors.accent,
       color: 'white',
@@ -92,6 +113,24 @@ function OptInPrompt({ isp, onRegistered, colors }: OptInPromptProps) {
         Help monitor connectivity in our building by allowing periodic pings
         to your connection. Your IP is stored securely and never shared.
       </p>
+      {needsInvite && (
+        <input
+          style={styles.input}
+          placeholder="Invite code"
+          value={inviteCode}
+          onChange={(e) => setInviteCode(e.target.value)}
+          autoComplete="off"
+        />
+      )}
+      {needsPassphrase && (
+        <input
+          style={styles.input}
+          type="password"
+          placeholder="Building passphrase"
+          value={passphrase}
+          onChange={(e) => setPassphrase(e.target.value)}
+        />
+      )}
       <button
         style={{
           ...styles.button,
@@ -106,7 +145,7 @@ function OptInPrompt({ isp, onRegistered, colors }: OptInPromptProps) {
         <div style={styles.error}>{error}</div>
       )}
       <p style={styles.privacy}>
-        Inactive connections are automatically removed after 3 days.
+        You can pause or leave at any time. Inactive connections are automatically removed after 3 days.
       </p>
     </div>
   );
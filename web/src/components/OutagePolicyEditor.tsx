@@ -0,0 +1,231 @@
+import { useState, useEffect } from 'react';
+import type { AdminSettings, OutagePolicy } from '../types';
+import type { ThemeColors } from '../App';
+
+interface OutagePolicyEditorProps {
+  settings: AdminSettings | null;
+  isps: string[]; // ISPs with endpoints, offered when adding an override
+  onSave: (settings: AdminSettings) => Promise<void>;
+  saving: boolean;
+  colors: ThemeColors;
+}
+
+const fallbackPolicy: OutagePolicy = {
+  min_endpoints: 2,
+  down_threshold: 0.5,
+  persist_cycles: 1,
+  shared_hop_min_endpoints: 2,
+};
+
+function OutagePolicyEditor({ settings, isps, onSave, saving, colors }: OutagePolicyEditorProps) {
+  const [local, setLocal] = useState<AdminSettings>(settings || {
+    default_outage_policy: fallbackPolicy,
+    outage_policies: {},
+  });
+  const [newISP, setNewISP] = useState('');
+
+  useEffect(() => {
+    if (settings) {
+      setLocal(settings);
+    }
+  }, [settings]);
+
+  const updatePolicy = (isp: string | null, field: keyof OutagePolicy, value: number) => {
+    setLocal(prev => {
+      if (isp === null) {
+        return { ...prev, default_outage_policy: { ...prev.default_outage_policy, [field]: value } };
+      }
+      const policies = prev.outage_policies || {};
+      return { ...prev, outage_policies: { ...policies, [isp]: { ...policies[isp], [field]: value } } };
+    });
+  };
+
+  const handleAddISP = () => {
+    const name = newISP.trim();
+    if (name && !(local.outage_policies || {})[name]) {
+      setLocal(prev => ({
+        ...prev,
+        outage_policies: { ...(prev.outage_policies || {}), [name]: { ...prev.default_outage_policy } },
+      }));
+      setNewISP('');
+    }
+  };
+
+  const handleRemoveISP = (isp: string) => {
+    setLocal(prev => {
+      const policies = { ...(prev.outage_policies || {}) };
+      delete policies[isp];
+      return { ...prev, outage_policies: policies };
+    });
+  };
+
+  const styles = {
+    section: {
+      background: colors.bgCard,
+      padding: '20px',
+      borderRadius: '12px',
+      marginBottom: '20px',
+      border: `1px solid ${colors.border}`,
+    },
+    sectionTitle: {
+      fontSize: '1.125rem',
+      fontWeight: 'bold' as const,
+      marginBottom: '15px',
+      color: colors.text,
+    },
+    table: {
+      width: '100%',
+      borderCollapse: 'collapse' as const,
+      marginBottom: '15px',
+      fontSize: '0.875rem',
+    },
+    th: {
+      textAlign: 'left' as const,
+      padding: '8px',
+      borderBottom: `1px solid ${colors.border}`,
+      color: colors.textMuted,
+      fontWeight: 'normal' as const,
+    },
+    td: {
+      padding: '8px',
+      borderBottom: `1px solid ${colors.border}`,
+      color: colors.text,
+    },
+    input: {
+      width: '70px',
+      padding: '6px',
+      borderRadius: '6px',
+      border: `1px solid ${colors.border}`,
+      background: colors.bg,
+      color: colors.text,
+    },
+    textInput: {
+      flex: 1,
+      padding: '10px',
+      borderRadius: '6px',
+      border: `1px solid ${colors.border}`,
+      background: colors.bg,
+      color: colors.text,
+      fontSize: '1rem',
+    },
+    button: {
+      padding: '10px 20px',
+      borderRadius: '6px',
+      border: 'none',
+      cursor: 'pointer',
+      fontSize: '1rem',
+      fontWeight: 'bold' as const,
+      background: colors.success,
+      color: 'white',
+    },
+    remove: {
+      cursor: 'pointer',
+      color: colors.danger,
+      background: 'transparent',
+      border: 'none',
+    },
+  };
+
+  const renderRow = (label: string, isp: string | null, policy: OutagePolicy) => (
+    <tr key={isp ?? '*'}>
+      <td style={styles.td}>{label}</td>
+      <td style={styles.td}>
+        <input
+          type="number" min={1} style={styles.input}
+          value={policy.min_endpoints}
+          onChange={(e) => updatePolicy(isp, 'min_endpoints', parseInt(e.target.value) || 0)}
+        />
+      </td>
+      <td style={styles.td}>
+        <input
+          type="number" min={0} max={100} step={5} style={styles.input}
+          value={Math.round(policy.down_threshold * 100)}
+          onChange={(e) => updatePolicy(isp, 'down_threshold', (parseInt(e.target.value) || 0) / 100)}
+        />
+      </td>
+      <td style={styles.td}>
+        <input
+          type="number" min={1} style={styles.input}
+          value={policy.persist_cycles}
+          onChange={(e) => updatePolicy(isp, 'persist_cycles', parseInt(e.target.value) || 0)}
+        />
+      </td>
+      <td style={styles.td}>
+        <input
+          type="number" min={0} style={styles.input}
+          value={policy.shared_hop_min_endpoints}
+          onChange={(e) => updatePolicy(isp, 'shared_hop_min_endpoints', parseInt(e.target.value) || 0)}
+        />
+      </td>
+      <td style={styles.td}>
+        {isp !== null && (
+          <button style={styles.remove} onClick={() => handleRemoveISP(isp)} title="Use the default policy">
+            ×
+          </button>
+        )}
+      </td>
+    </tr>
+  );
+
+  const overrides = local.outage_policies || {};
+
+  return (
+    <div style={styles.section}>
+      <div style={styles.sectionTitle}>Outage Detection</div>
+      <p style={{ color: colors.textMuted, marginBottom: '20px', lineHeight: 1.6 }}>
+        An ISP is reported as having a likely outage when more than the threshold of its endpoints are down,
+        or when enough endpoints behind one upstream hop are all down (0 turns this off). Either condition must
+        hold for the given number of consecutive ping cycles. ISPs without their own policy use the default.
+      </p>
+      <table style={styles.table}>
+        <thead>
+          <tr>
+            <th style={styles.th}>ISP</th>
+            <th style={styles.th}>Min endpoints</th>
+            <th style={styles.th}>Down threshold %</th>
+            <th style={styles.th}>Cycles</th>
+            <th style={styles.th}>Shared hop</th>
+            <th style={styles.th}></th>
+          </tr>
+        </thead>
+        <tbody>
+          {renderRow('Default', null, local.default_outage_policy)}
+          {Object.keys(overrides).sort().map((isp) => renderRow(isp, isp, overrides[isp]))}
+        </tbody>
+      </table>
+
+      <div style={{ display: 'flex', gap: '10px', marginBottom: '15px' }}>
+        <input
+          type="text"
+          list="outage-policy-isps"
+          value={newISP}
+          onChange={(e) => setNewISP(e.target.value)}
+          onKeyDown={(e) => e.key === 'Enter' && (e.preventDefault(), handleAddISP())}
+          placeholder="Add a policy for an ISP..."
+          style={styles.textInput}
+        />
+        <datalist id="outage-policy-isps">
+          {isps.filter((isp) => !overrides[isp]).map((isp) => <option key={isp} value={isp} />)}
+        </datalist>
+        <button onClick={handleAddISP} style={styles.button}>
+          Add
+        </button>
+      </div>
+
+      <button
+        onClick={() => onSave(local)}
+        disabled={saving}
+        style={{
+          ...styles.button,
+          width: '100%',
+          opacity: saving ? 0.6 : 1,
+          cursor: saving ? 'not-allowed' : 'pointer',
+        }}
+      >
+        {saving ? 'Saving...' : 'Save Outage Policies'}
+      </button>
+    </div>
+  );
+}
+
+export default OutagePolicyEditor;
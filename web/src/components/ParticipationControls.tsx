@@ -0,0 +1,100 @@
+import { useState } from 'react';
+import { pauseMonitoring, unregister } from '../api';
+import type { StatusResponse } from '../types';
+import type { ThemeColors } from '../App';
+
+interface ParticipationControlsProps {
+  status: StatusResponse;
+  colors: ThemeColors;
+  onChanged: () => void;
+}
+
+const PAUSE_DAYS = 7;
+
+// Lets a resident pause monitoring of their connection or leave entirely.
+// Leaving uses the deletion token saved at registration, so it also works
+// from another network, e.g. after moving out.
+function ParticipationControls({ status, colors, onChanged }: ParticipationControlsProps) {
+  const [busy, setBusy] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  const run = async (action: () => Promise<unknown>) => {
+    setBusy(true);
+    setError(null);
+    try {
+      await action();
+      onChanged();
+    } catch (err) {
+      setError(err instanceof Error ? err.message : 'Request failed');
+    } finally {
+      setBusy(false);
+    }
+  };
+
+  const handleLeave = () => {
+    if (!confirm('Stop monitoring your connection and delete your registration? You can join again at any time.')) {
+      return;
+    }
+    run(unregister);
+  };
+
+  const styles = {
+    row: {
+      display: 'flex',
+      justifyContent: 'center',
+      gap: '10px',
+      marginTop: '12px',
+      flexWrap: 'wrap' as const,
+    },
+    button: {
+      background: 'transparent',
+      border: `1px solid ${colors.border}`,
+      borderRadius: '6px',
+      padding: '6px 12px',
+      cursor: busy ? 'not-allowed' : 'pointer',
+      color: colors.text,
+      fontSize: '0.875rem',
+      opacity: busy ? 0.6 : 1,
+    },
+    leave: {
+      color: colors.danger,
+    },
+    note: {
+      fontSize: '0.875rem',
+      color: colors.textMuted,
+      marginTop: '8px',
+    },
+    error: {
+      color: colors.errorText,
+      marginTop: '8px',
+      fontSize: '0.875rem',
+    },
+  };
+
+  return (
+    <>
+      {status.paused_until && (
+        <div style={styles.note}>
+          Monitoring is paused until {new Date(status.paused_until).toLocaleDateString()}.
+        </div>
+      )}
+      <div style={styles.row}>
+        {status.paused_until ? (
+          <button style={styles.button} disabled={busy} onClick={() => run(() => pauseMonitoring(0))}>
+            Resume monitoring
+          </button>
+        ) : (
+          <button style={styles.button} disabled={busy} onClick={() => run(() => pauseMonitoring(PAUSE_DAYS))}>
+            Pause for {PAUSE_DAYS} days
+          </button>
+        )}
+        <button style={{ ...styles.button, ...styles.leave }} disabled={busy} onClick={handleLeave}>
+          Leave monitoring
+        </button>
+      </div>
+      {error && <div style={styles.error}>{error}</div>}
+    </>
+  );
+}
+
+export default ParticipationControls;
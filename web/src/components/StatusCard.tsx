@@ -8,7 +8,8 @@ interface StatusCardProps {
 }
 
 function StatusCard({ status, isCurrentISP, colors }: StatusCardProps) {
-  const upPercent = status.total > 0 ? (status.up / status.total) * 100 : 0;
+  const online = status.up + status.degraded;
+  const upPercent = status.total > 0 ? (online / status.total) * 100 : 0;
 
   let statusColor: string;
   let statusBg: string;
@@ -122,7 +123,7 @@ function StatusCard({ status, isCurrentISP, colors }: StatusCardProps) {
 
       <div style={styles.stats}>
         <div style={styles.stat}>
-          <div style={{ ...styles.statValue, color: colors.success }}>{status.up}</div>
+          <div style={{ ...styles.statValue, color: colors.success }}>{online}</div>
           <div style={styles.statLabel}>Online</div>
         </div>
         <div style={styles.stat}>
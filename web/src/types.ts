@@ -3,6 +3,7 @@ export interface ISPStatus {
   asn?: number;
   total: number;
   up: number;
+  degraded: number;
   down: number;
   last_updated: string;
 }
@@ -12,19 +13,31 @@ export interface StatusResponse {
   registered: boolean;
   can_register: boolean;
   endpoint_id: string | null;
-  endpoint_status?: string; // "up", "down", "unreachable", "unknown"
+  endpoint_status?: string; // "up", "degraded", "down", "unknown"
+  paused_until?: string; // Set while monitoring is paused
   isp_status?: ISPStatus;
+  reject_reason?: string; // Why can_register is false: "isp_not_allowed", "network_not_allowed"
+  registration_requires?: string[]; // "invite", "passphrase"
 }
 
 export interface RegisterResponse {
   endpoint_id: string;
   isp: string;
   message: string;
+  device_token?: string;
+  deletion_token?: string;
+}
+
+export interface PauseResponse {
+  endpoint_id: string;
+  paused_until: string | null;
+  message: string;
 }
 
 export interface DashboardResponse {
   isps: ISPStatus[];
   likely_outage: boolean;
+  monitor_offline: boolean; // The monitoring server lost its own connectivity
   last_updated: string;
 }
 
@@ -35,7 +48,8 @@ export interface HealthResponse {
 
 export interface AdminEndpoint {
   id: string;
-  ipv4: string;
+  ipv4?: string;
+  ipv6?: string;
   isp: string;
   status: string;
   created_at: string;
@@ -44,17 +58,21 @@ export interface AdminEndpoint {
   monitored_hop?: string;
   hop_number?: number;
   use_hop: boolean;
+  probe_strategy?: string[]; // e.g. ["tcp:443", "icmp"]; absent = server default
 }
 
 export interface AdminAddRequest {
-  ipv4: string;
+  ipv4?: string;
+  ipv6?: string;
   isp?: string;
+  probe_strategy?: string[];
 }
 
 export interface ISPMetrics {
   name: string;
   total: number;
   up: number;
+  degraded: number;
   down: number;
   unknown: number;
   uptime_pct: number;
@@ -71,7 +89,7 @@ export interface UptimePoint {
 export interface Event {
   id: number;
   timestamp: string;
-  event_type: string;  // "down", "up", "outage", "recovery"
+  event_type: string;  // "down", "up", "outage", "recovery", "hop_changed"
   isp?: string;
   endpoint_id?: string;
   message: string;
@@ -84,6 +102,7 @@ export interface EventsResponse {
 export interface AdminMetrics {
   total_endpoints: number;
   endpoints_up: number;
+  endpoints_degraded: number;
   endpoints_down: number;
   endpoints_unknown: number;
   overall_uptime_pct: number;
@@ -103,8 +122,32 @@ export interface AdminMetrics {
   uptime_history: UptimePoint[];
 }
 
+export type AdminRole = 'viewer' | 'operator' | 'owner';
+
+export interface AdminUser {
+  id: number;
+  username: string;
+  role: AdminRole;
+  disabled: boolean;
+  created_at: string;
+  last_login?: string;
+}
+
+export interface AdminSession {
+  user: AdminUser;
+  expires_at?: string;
+}
+
+export interface OutagePolicy {
+  min_endpoints: number;
+  down_threshold: number; // 0-1
+  persist_cycles: number;
+  shared_hop_min_endpoints: number; // 0 = off
+}
+
 export interface AdminSettings {
-  outage_threshold: number;
+  default_outage_policy: OutagePolicy;
+  outage_policies: Record<string, OutagePolicy>; // Per-ISP overrides
 }
 
 export interface SiteConfig {